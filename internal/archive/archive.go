@@ -0,0 +1,248 @@
+// Package archive writes and reads the zip files users export their data to
+// and import it from. An archive holds one JSON file per kind of data and a
+// manifest that lists them with their checksums, so a file that was changed
+// or cut short is caught before anything is imported.
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"slices"
+	"time"
+)
+
+// Version is the archive format Write produces. Read accepts it and every
+// earlier version.
+const Version = 1
+
+const (
+	ManifestFile = "manifest.json"
+	ProfileFile  = "profile.json"
+	ChirpsFile   = "chirps.json"
+	SessionsFile = "sessions.json"
+)
+
+// ErrInvalid is returned by Read for anything that isn't an archive Write
+// could have produced.
+var ErrInvalid = errors.New("not a valid Chirpy export")
+
+type Profile struct {
+	ID          uuid.UUID `json:"id"`
+	Email       string    `json:"email"`
+	Handle      *string   `json:"handle"`
+	DisplayName string    `json:"display_name"`
+	Bio         string    `json:"bio"`
+	IsChirpyRed bool      `json:"is_chirpy_red"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Chirp is a chirp as it was when exported. Chirps in the trash are
+// included, with DeletedAt set.
+type Chirp struct {
+	ID        uuid.UUID     `json:"id"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+	Body      string        `json:"body"`
+	DeletedAt *time.Time    `json:"deleted_at"`
+	InReplyTo uuid.NullUUID `json:"in_reply_to"`
+	RechirpOf uuid.NullUUID `json:"rechirp_of"`
+	QuoteOf   uuid.NullUUID `json:"quote_of"`
+}
+
+// Session is a login, without the tokens it was given. Archives written
+// before sessions were recorded have no user agent, IP or last use.
+type Session struct {
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt time.Time  `json:"last_used_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+type Archive struct {
+	Profile  Profile
+	Chirps   []Chirp
+	Sessions []Session
+}
+
+type Manifest struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	UserID    uuid.UUID `json:"user_id"`
+	Files     []File    `json:"files"`
+}
+
+type File struct {
+	Name   string `json:"name"`
+	Size   int    `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Write zips a into w along with its manifest.
+func Write(w io.Writer, a Archive, createdAt time.Time) error {
+	if a.Chirps == nil {
+		a.Chirps = []Chirp{}
+	}
+	if a.Sessions == nil {
+		a.Sessions = []Session{}
+	}
+	manifest := Manifest{
+		Version:   Version,
+		CreatedAt: createdAt,
+		UserID:    a.Profile.ID,
+	}
+
+	z := zip.NewWriter(w)
+	for _, file := range []struct {
+		name string
+		data any
+	}{
+		{ProfileFile, a.Profile},
+		{ChirpsFile, a.Chirps},
+		{SessionsFile, a.Sessions},
+	} {
+		data, err := json.MarshalIndent(file.data, "", "  ")
+		if err != nil {
+			return err
+		}
+		err = writeFile(z, file.name, data)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		manifest.Files = append(manifest.Files, File{
+			Name:   file.name,
+			Size:   len(data),
+			SHA256: hex.EncodeToString(sum[:]),
+		})
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = writeFile(z, ManifestFile, data)
+	if err != nil {
+		return err
+	}
+	return z.Close()
+}
+
+func writeFile(z *zip.Writer, name string, data []byte) error {
+	f, err := z.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	return err
+}
+
+// Read unzips an archive, checking every file against the manifest.
+func Read(data []byte) (Archive, Manifest, error) {
+	z, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return Archive{}, Manifest{}, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	files := map[string][]byte{}
+	remaining := int64(maxSize)
+	for _, f := range z.File {
+		if !slices.Contains(knownFiles, f.Name) {
+			return Archive{}, Manifest{}, fmt.Errorf("%w: unexpected file %s", ErrInvalid, f.Name)
+		}
+		if _, ok := files[f.Name]; ok {
+			return Archive{}, Manifest{}, fmt.Errorf("%w: %s appears more than once", ErrInvalid, f.Name)
+		}
+		data, err := readFile(f, remaining)
+		if err != nil {
+			return Archive{}, Manifest{}, fmt.Errorf("%w: %s: %w", ErrInvalid, f.Name, err)
+		}
+		remaining -= int64(len(data))
+		files[f.Name] = data
+	}
+
+	var manifest Manifest
+	err = decode(files, ManifestFile, &manifest)
+	if err != nil {
+		return Archive{}, Manifest{}, err
+	}
+	if manifest.Version < 1 || manifest.Version > Version {
+		return Archive{}, Manifest{}, fmt.Errorf("%w: unsupported version %d", ErrInvalid, manifest.Version)
+	}
+	for _, file := range manifest.Files {
+		data, ok := files[file.Name]
+		if !ok {
+			return Archive{}, Manifest{}, fmt.Errorf("%w: %s is missing", ErrInvalid, file.Name)
+		}
+		sum := sha256.Sum256(data)
+		if len(data) != file.Size || hex.EncodeToString(sum[:]) != file.SHA256 {
+			return Archive{}, Manifest{}, fmt.Errorf("%w: %s doesn't match the manifest", ErrInvalid, file.Name)
+		}
+	}
+
+	var a Archive
+	err = decode(files, ProfileFile, &a.Profile)
+	if err != nil {
+		return Archive{}, Manifest{}, err
+	}
+	err = decode(files, ChirpsFile, &a.Chirps)
+	if err != nil {
+		return Archive{}, Manifest{}, err
+	}
+	err = decode(files, SessionsFile, &a.Sessions)
+	if err != nil {
+		return Archive{}, Manifest{}, err
+	}
+	return a, manifest, nil
+}
+
+// knownFiles are the files Read opens. Anything else in an archive makes it
+// invalid rather than being unzipped for nothing.
+var knownFiles = []string{ManifestFile, ProfileFile, ChirpsFile, SessionsFile}
+
+// maxSize bounds how much all the files in an archive can unzip to
+// together, so a small upload can't expand into gigabytes.
+const maxSize = 64 << 20
+
+// readFile unzips f, failing once it passes limit bytes. The size in f's
+// header is checked first but not trusted.
+func readFile(f *zip.File, limit int64) ([]byte, error) {
+	if f.UncompressedSize64 > uint64(limit) {
+		return nil, errTooLarge
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, errTooLarge
+	}
+	return data, nil
+}
+
+var errTooLarge = errors.New("archive is too large once unzipped")
+
+func decode(files map[string][]byte, name string, v any) error {
+	data, ok := files[name]
+	if !ok {
+		return fmt.Errorf("%w: %s is missing", ErrInvalid, name)
+	}
+	err := json.Unmarshal(data, v)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalid, name, err)
+	}
+	return nil
+}
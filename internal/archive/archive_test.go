@@ -0,0 +1,145 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestWriteRead(t *testing.T) {
+	createdAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	original := uuid.New()
+	handle := "heisenberg"
+	a := Archive{
+		Profile: Profile{ID: uuid.New(), Email: "walt@example.com", Handle: &handle, CreatedAt: createdAt, UpdatedAt: createdAt},
+		Chirps: []Chirp{
+			{ID: original, CreatedAt: createdAt, UpdatedAt: createdAt, Body: "say my name"},
+			{ID: uuid.New(), CreatedAt: createdAt.Add(time.Hour), UpdatedAt: createdAt.Add(time.Hour), Body: "you're goddamn right", InReplyTo: uuid.NullUUID{UUID: original, Valid: true}},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, a, createdAt); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	got, manifest, err := Read(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if manifest.Version != Version || manifest.UserID != a.Profile.ID || !manifest.CreatedAt.Equal(createdAt) || len(manifest.Files) != 3 {
+		t.Errorf("Read() manifest = %+v", manifest)
+	}
+	if got.Profile.Email != a.Profile.Email || *got.Profile.Handle != handle {
+		t.Errorf("Read() profile = %+v, want %+v", got.Profile, a.Profile)
+	}
+	if len(got.Chirps) != 2 || got.Chirps[1].InReplyTo != a.Chirps[1].InReplyTo || !got.Chirps[1].CreatedAt.Equal(a.Chirps[1].CreatedAt) {
+		t.Errorf("Read() chirps = %+v, want %+v", got.Chirps, a.Chirps)
+	}
+	if got.Sessions == nil || len(got.Sessions) != 0 {
+		t.Errorf("Read() sessions = %#v, want an empty list", got.Sessions)
+	}
+}
+
+func TestReadInvalid(t *testing.T) {
+	var valid bytes.Buffer
+	if err := Write(&valid, Archive{}, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	files, err := unzip(valid.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		change func(files map[string][]byte)
+		// want is the error Read should give, when it's more specific than
+		// ErrInvalid.
+		want error
+	}{
+		{name: "Tampered file", change: func(files map[string][]byte) { files[ChirpsFile] = []byte(`[{"body": "I am the one who knocks"}]`) }},
+		{name: "Missing file", change: func(files map[string][]byte) { delete(files, ProfileFile) }},
+		{name: "Missing manifest", change: func(files map[string][]byte) { delete(files, ManifestFile) }},
+		{name: "Future version", change: func(files map[string][]byte) {
+			files[ManifestFile] = bytes.Replace(files[ManifestFile], []byte(`"version": 1`), []byte(`"version": 99`), 1)
+		}},
+		{name: "Unknown file", change: func(files map[string][]byte) { files["notes.txt"] = []byte("tread lightly") }},
+		{name: "Oversized file", change: func(files map[string][]byte) { files[ChirpsFile] = bytes.Repeat([]byte(" "), maxSize+1) }, want: errTooLarge},
+		{name: "Oversized together", change: func(files map[string][]byte) {
+			files[ChirpsFile] = bytes.Repeat([]byte(" "), maxSize/2+1)
+			files[SessionsFile] = bytes.Repeat([]byte(" "), maxSize/2+1)
+		}, want: errTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changed := map[string][]byte{}
+			for name, data := range files {
+				changed[name] = data
+			}
+			tt.change(changed)
+			want := ErrInvalid
+			if tt.want != nil {
+				want = tt.want
+			}
+			if _, _, err := Read(rezip(t, changed)); !errors.Is(err, ErrInvalid) || !errors.Is(err, want) {
+				t.Errorf("Read() error = %v, want %v", err, want)
+			}
+		})
+	}
+
+	var duplicated bytes.Buffer
+	z := zip.NewWriter(&duplicated)
+	for name, data := range files {
+		if err := writeFile(z, name, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writeFile(z, ChirpsFile, files[ChirpsFile]); err != nil {
+		t.Fatal(err)
+	}
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := Read(duplicated.Bytes()); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Read() with a file in it twice error = %v, want ErrInvalid", err)
+	}
+
+	if _, _, err := Read([]byte("not a zip")); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Read() of a non-zip error = %v, want ErrInvalid", err)
+	}
+}
+
+func unzip(data []byte) (map[string][]byte, error) {
+	z, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	files := map[string][]byte{}
+	for _, f := range z.File {
+		data, err := readFile(f, maxSize)
+		if err != nil {
+			return nil, err
+		}
+		files[f.Name] = data
+	}
+	return files, nil
+}
+
+func rezip(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	z := zip.NewWriter(&buf)
+	for name, data := range files {
+		if err := writeFile(z, name, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
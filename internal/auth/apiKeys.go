@@ -1,11 +1,20 @@
 package auth
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"github.com/google/uuid"
 	"net/http"
+	"slices"
 	"strings"
 )
 
+// apiKeyPrefix starts every personal API key, so that people and secret
+// scanners can tell one apart from other credentials.
+const apiKeyPrefix = "chirpy_"
+
 func GetAPIKey(headers http.Header) (string, error) {
 	token := headers.Get("Authorization")
 	if token == "" || !strings.HasPrefix(token, "ApiKey ") || len(token) <= 7 {
@@ -13,3 +22,62 @@ func GetAPIKey(headers http.Header) (string, error) {
 	}
 	return strings.TrimPrefix(token, "ApiKey "), nil
 }
+
+// MakeAPIKey returns a new personal API key, "chirpy_<id>_<secret>", and
+// its prefix, "chirpy_<id>", which is kept in the clear so its owner can
+// tell their keys apart.
+func MakeAPIKey() (key, prefix string, err error) {
+	id := make([]byte, 4)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return "", "", err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	prefix = apiKeyPrefix + hex.EncodeToString(id)
+	return prefix + "_" + hex.EncodeToString(secret), prefix, nil
+}
+
+// IsPersonalAPIKey reports whether key looks like one MakeAPIKey made,
+// rather than a key shared with another service.
+func IsPersonalAPIKey(key string) bool {
+	return strings.HasPrefix(key, apiKeyPrefix)
+}
+
+// HashAPIKey returns the form a personal API key is stored and looked up
+// in, keyed with key like HashRefreshToken.
+func HashAPIKey(apiKey, key string) (string, error) {
+	if key == "" {
+		return "", errors.New("no API key hash key configured")
+	}
+	return keyedHash(apiKey, key), nil
+}
+
+// CheckAPIKeyScopes checks that a user with role may give an API key
+// scopes. A key can have any scope the role grants except ScopeAccount, so
+// a leaked key can't change the password or make more keys.
+func CheckAPIKeyScopes(role Role, scopes []string) error {
+	if len(scopes) == 0 {
+		return errors.New("an API key needs at least one scope")
+	}
+	for _, scope := range scopes {
+		if scope == ScopeAccount || !slices.Contains(roleScopes[role], scope) {
+			return fmt.Errorf("an API key can't have the %q scope", scope)
+		}
+	}
+	return nil
+}
+
+// NewAPIKeyPrincipal returns the principal of an API key with scopes
+// belonging to a user with role. The key loses any scope the role no
+// longer grants.
+func NewAPIKeyPrincipal(userID uuid.UUID, role Role, scopes []string) Principal {
+	return Principal{
+		UserID: userID,
+		Role:   role,
+		Scopes: slices.DeleteFunc(slices.Clone(scopes), func(scope string) bool {
+			return scope == ScopeAccount || !slices.Contains(roleScopes[role], scope)
+		}),
+	}
+}
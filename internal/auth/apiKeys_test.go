@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestMakeAPIKey(t *testing.T) {
+	key, prefix, err := MakeAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(key, prefix+"_") || len(prefix) != len("chirpy_")+8 || len(key) != len(prefix)+1+64 {
+		t.Errorf("MakeAPIKey() = %q, %q, want chirpy_<8 hex>_<64 hex> and its prefix", key, prefix)
+	}
+	if !IsPersonalAPIKey(key) || IsPersonalAPIKey("polka-key") {
+		t.Error("IsPersonalAPIKey() should tell personal keys from others")
+	}
+	other, _, _ := MakeAPIKey()
+	if other == key {
+		t.Error("MakeAPIKey() twice gave the same key")
+	}
+
+	hash, err := HashAPIKey(key, "key")
+	if err != nil || hash == key || len(hash) != 64 {
+		t.Errorf("HashAPIKey() = %q, %v, want a hex SHA-256 that isn't the key", hash, err)
+	}
+	if again, _ := HashAPIKey(key, "key"); again != hash {
+		t.Errorf("HashAPIKey() again = %q, want %q", again, hash)
+	}
+	if _, err := HashAPIKey(key, ""); err == nil {
+		t.Error("HashAPIKey() without a key should fail")
+	}
+}
+
+func TestCheckAPIKeyScopes(t *testing.T) {
+	tests := []struct {
+		name    string
+		role    Role
+		scopes  []string
+		wantErr bool
+	}{
+		{name: "Read and write", role: RoleUser, scopes: []string{ScopeChirpsRead, ScopeChirpsWrite}},
+		{name: "No scopes", role: RoleUser, scopes: nil, wantErr: true},
+		{name: "Account", role: RoleUser, scopes: []string{ScopeChirpsRead, ScopeAccount}, wantErr: true},
+		{name: "Admin as a user", role: RoleUser, scopes: []string{ScopeAdmin}, wantErr: true},
+		{name: "Admin as an admin", role: RoleAdmin, scopes: []string{ScopeAdmin}},
+		{name: "Unknown scope", role: RoleAdmin, scopes: []string{"chirps:delete-everything"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := CheckAPIKeyScopes(tt.role, tt.scopes); (err != nil) != tt.wantErr {
+				t.Errorf("CheckAPIKeyScopes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewAPIKeyPrincipal(t *testing.T) {
+	userID := uuid.New()
+	scopes := []string{ScopeChirpsRead, ScopeAdmin}
+
+	admin := NewAPIKeyPrincipal(userID, RoleAdmin, scopes)
+	if admin.UserID != userID || admin.SessionID != uuid.Nil || !slices.Equal(admin.Scopes, scopes) {
+		t.Errorf("NewAPIKeyPrincipal() for an admin = %+v, want every scope of the key", admin)
+	}
+	demoted := NewAPIKeyPrincipal(userID, RoleUser, scopes)
+	if demoted.HasScope(ScopeAdmin) || !demoted.HasScope(ScopeChirpsRead) {
+		t.Errorf("NewAPIKeyPrincipal() for a demoted admin = %+v, want the admin scope dropped", demoted)
+	}
+	if !slices.Equal(scopes, []string{ScopeChirpsRead, ScopeAdmin}) {
+		t.Error("NewAPIKeyPrincipal() changed the scopes it was given")
+	}
+}
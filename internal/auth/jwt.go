@@ -1,41 +1,177 @@
 package auth
 
 import (
+	"context"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
 )
 
-func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
-		Subject:   userID.String(),
-		Issuer:    "chirpy",
-		IssuedAt:  jwt.NewNumericDate(time.Now()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
-	})
+// AccessToken is the principal an access token was issued to. ID is its
+// jti, which revoking it records.
+type AccessToken struct {
+	Principal
+	ID        uuid.UUID
+	ExpiresAt time.Time
+}
+
+// Validation is what access tokens are issued with and what ParseJWT
+// demands of them beyond a good signature.
+type Validation struct {
+	// Issuer is the iss MakeJWT sets and ParseJWT requires.
+	Issuer string
+	// Audiences are the aud MakeJWT sets. ParseJWT requires a token to name
+	// at least one of them.
+	Audiences []string
+	// Algorithms are the signing algorithms ParseJWT accepts.
+	Algorithms []string
+	// Leeway is how far exp, nbf and iat can be off to allow for clocks
+	// that disagree.
+	Leeway time.Duration
+}
+
+var (
+	// ErrInvalidToken is returned by ValidateJWT for any token it rejects
+	// other than a revoked one. The errors below wrap it with the reason.
+	ErrInvalidToken     = errors.New("access token not valid")
+	ErrTokenExpired     = fmt.Errorf("%w: expired", ErrInvalidToken)
+	ErrTokenNotYetValid = fmt.Errorf("%w: not valid yet", ErrInvalidToken)
+	ErrBadSignature     = fmt.Errorf("%w: bad signature", ErrInvalidToken)
+	ErrWrongIssuer      = fmt.Errorf("%w: wrong issuer", ErrInvalidToken)
+	ErrWrongAudience    = fmt.Errorf("%w: wrong audience", ErrInvalidToken)
+	// ErrTokenRevoked is returned by ValidateJWT for a token on the denylist.
+	ErrTokenRevoked = errors.New("access token has been revoked")
+)
+
+// Denylist reports whether an access token was revoked before it expired.
+// database.Store satisfies it.
+type Denylist interface {
+	IsAccessTokenRevoked(ctx context.Context, jti uuid.UUID) (bool, error)
+}
 
-	return token.SignedString([]byte(tokenSecret))
+// claims are the registered claims plus the session ID, as "sid", and the
+// role and its scopes, the scopes space separated as in RFC 9068.
+type claims struct {
+	jwt.RegisteredClaims
+	SessionID uuid.UUID `json:"sid"`
+	Role      string    `json:"role"`
+	Scope     string    `json:"scope"`
 }
 
-func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(tokenSecret), nil
+// MakeJWT issues an access token for p signed with the signing key of keys
+// and naming it in the kid header, with the issuer and audiences of v.
+func MakeJWT(p Principal, keys *KeySet, v Validation, expiresIn time.Duration) (string, error) {
+	if !slices.Contains(v.Algorithms, keys.signing.method.Alg()) {
+		return "", fmt.Errorf("signing key %q uses %s, which isn't an allowed algorithm", keys.signing.ID, keys.signing.method.Alg())
+	}
+	now := time.Now()
+	token := jwt.NewWithClaims(keys.signing.method, claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Subject:   p.UserID.String(),
+			Issuer:    v.Issuer,
+			Audience:  v.Audiences,
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(expiresIn)),
+		},
+		SessionID: p.SessionID,
+		Role:      string(p.Role),
+		Scope:     strings.Join(p.Scopes, " "),
 	})
+	token.Header["kid"] = keys.signing.ID
+
+	return token.SignedString(keys.signing.private)
+}
+
+// ParseJWT checks an access token's signature against the key in keys it
+// names and its claims against v, and returns what it was issued for,
+// without checking whether it was revoked. Its errors wrap ErrInvalidToken,
+// and the more specific errors above where one applies.
+func ParseJWT(tokenString string, keys *KeySet, v Validation) (AccessToken, error) {
+	var c claims
+	_, err := jwt.ParseWithClaims(tokenString, &c, keys.keyFunc,
+		jwt.WithValidMethods(v.Algorithms),
+		jwt.WithIssuer(v.Issuer),
+		jwt.WithLeeway(v.Leeway),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
 	if err != nil {
-		return uuid.Nil, err
+		return AccessToken{}, tokenError(err)
 	}
 
-	userID, err := token.Claims.GetSubject()
+	if !slices.ContainsFunc(c.Audience, func(aud string) bool {
+		return slices.Contains(v.Audiences, aud)
+	}) {
+		return AccessToken{}, fmt.Errorf("%w: %v", ErrWrongAudience, c.Audience)
+	}
+	userID, err := uuid.Parse(c.Subject)
+	if err != nil {
+		return AccessToken{}, fmt.Errorf("%w: subject: %v", ErrInvalidToken, err)
+	}
+	jti, err := uuid.Parse(c.ID)
 	if err != nil {
-		return uuid.Nil, err
+		return AccessToken{}, fmt.Errorf("%w: jti: %v", ErrInvalidToken, err)
 	}
+	role, err := ParseRole(c.Role)
+	if err != nil {
+		return AccessToken{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	return AccessToken{
+		Principal: Principal{
+			UserID:    userID,
+			SessionID: c.SessionID,
+			Role:      role,
+			Scopes:    strings.Fields(c.Scope),
+		},
+		ID:        jti,
+		ExpiresAt: c.ExpiresAt.Time,
+	}, nil
+}
 
-	return uuid.Parse(userID)
+// tokenError translates an error from the jwt package into ours.
+func tokenError(err error) error {
+	var reason error
+	switch {
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid), errors.Is(err, jwt.ErrTokenUnverifiable):
+		reason = ErrBadSignature
+	case errors.Is(err, jwt.ErrTokenExpired):
+		reason = ErrTokenExpired
+	case errors.Is(err, jwt.ErrTokenNotValidYet), errors.Is(err, jwt.ErrTokenUsedBeforeIssued):
+		reason = ErrTokenNotYetValid
+	case errors.Is(err, jwt.ErrTokenInvalidIssuer):
+		reason = ErrWrongIssuer
+	default:
+		reason = ErrInvalidToken
+	}
+	return fmt.Errorf("%w: %v", reason, err)
+}
+
+// ValidateJWT is ParseJWT followed by a check of denylist, so a revoked
+// token stops working before it expires.
+func ValidateJWT(ctx context.Context, tokenString string, keys *KeySet, v Validation, denylist Denylist) (AccessToken, error) {
+	token, err := ParseJWT(tokenString, keys, v)
+	if err != nil {
+		return AccessToken{}, err
+	}
+	revoked, err := denylist.IsAccessTokenRevoked(ctx, token.ID)
+	if err != nil {
+		return AccessToken{}, fmt.Errorf("couldn't check access token: %w", err)
+	}
+	if revoked {
+		return AccessToken{}, ErrTokenRevoked
+	}
+	return token, nil
 }
 
 func GetBearerToken(headers http.Header) (string, error) {
@@ -54,3 +190,20 @@ func MakeRefreshToken() (string, error) {
 	}
 	return hex.EncodeToString(tokenBytes), nil
 }
+
+// HashRefreshToken returns the form a refresh token is stored and looked up
+// in: an HMAC-SHA256 keyed with key. Without the key, a leaked hash can't be
+// turned back into a token or matched against guesses.
+func HashRefreshToken(token, key string) (string, error) {
+	if key == "" {
+		return "", errors.New("no refresh token key configured")
+	}
+	return keyedHash(token, key), nil
+}
+
+// keyedHash is the hex HMAC-SHA256 of secret keyed with key.
+func keyedHash(secret, key string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(secret))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -1,56 +1,213 @@
 package auth
 
 import (
+	"context"
+	"errors"
 	"testing"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
+// denylist is a Denylist over a fixed set of jtis.
+type denylist map[uuid.UUID]bool
+
+func (d denylist) IsAccessTokenRevoked(_ context.Context, jti uuid.UUID) (bool, error) {
+	return d[jti], nil
+}
+
+var testValidation = Validation{
+	Issuer:     "chirpy",
+	Audiences:  []string{"chirpy", "chirpy-admin"},
+	Algorithms: []string{"EdDSA", "RS256"},
+	Leeway:     time.Minute,
+}
+
+// testKeys returns a KeySet with one new Ed25519 key, kid.
+func testKeys(t *testing.T, kid string) *KeySet {
+	t.Helper()
+	key, err := GenerateKey(kid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := NewKeySet(kid, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
 func TestValidateJWT(t *testing.T) {
+	keys := testKeys(t, "current")
 	userID := uuid.New()
-	validToken, _ := MakeJWT(userID, "secret", time.Hour)
+	validToken, _ := MakeJWT(NewPrincipal(userID, uuid.New(), RoleUser), keys, testValidation, time.Hour)
+	revokedToken, _ := MakeJWT(NewPrincipal(userID, uuid.New(), RoleUser), keys, testValidation, time.Hour)
+	revoked, err := ParseJWT(revokedToken, keys, testValidation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revokedJTIs := denylist{revoked.ID: true}
 
 	tests := []struct {
 		name        string
 		tokenString string
-		tokenSecret string
+		keys        *KeySet
 		wantUserID  uuid.UUID
-		wantErr     bool
+		wantErr     error
 	}{
 		{
 			name:        "Valid token",
 			tokenString: validToken,
-			tokenSecret: "secret",
+			keys:        keys,
 			wantUserID:  userID,
-			wantErr:     false,
 		},
 		{
 			name:        "Invalid token",
 			tokenString: "invalid.token.string",
-			tokenSecret: "secret",
+			keys:        keys,
 			wantUserID:  uuid.Nil,
-			wantErr:     true,
+			wantErr:     ErrInvalidToken,
 		},
 		{
-			name:        "Wrong secret",
+			name:        "Wrong key",
 			tokenString: validToken,
-			tokenSecret: "wrong_secret",
+			keys:        testKeys(t, "current"),
+			wantUserID:  uuid.Nil,
+			wantErr:     ErrInvalidToken,
+		},
+		{
+			name:        "Revoked token",
+			tokenString: revokedToken,
+			keys:        keys,
 			wantUserID:  uuid.Nil,
-			wantErr:     true,
+			wantErr:     ErrTokenRevoked,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotUserID, err := ValidateJWT(tt.tokenString, tt.tokenSecret)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("ValidateJWT() error = %v, wantErr %v", err, tt.wantErr)
+			got, err := ValidateJWT(context.Background(), tt.tokenString, tt.keys, testValidation, revokedJTIs)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ValidateJWT() error = %v, want %v", err, tt.wantErr)
 				return
 			}
-			if gotUserID != tt.wantUserID {
-				t.Errorf("ValidateJWT() gotUserID = %v, want %v", gotUserID, tt.wantUserID)
+			if got.UserID != tt.wantUserID {
+				t.Errorf("ValidateJWT() gotUserID = %v, want %v", got.UserID, tt.wantUserID)
 			}
 		})
 	}
 }
+
+func TestParseJWT(t *testing.T) {
+	keys := testKeys(t, "current")
+	userID, sessionID := uuid.New(), uuid.New()
+	token, err := MakeJWT(NewPrincipal(userID, sessionID, RoleUser), keys, testValidation, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ParseJWT(token, keys, testValidation)
+	if err != nil {
+		t.Fatalf("ParseJWT() error = %v", err)
+	}
+	if got.UserID != userID || got.SessionID != sessionID || got.ID == uuid.Nil {
+		t.Errorf("ParseJWT() = %+v, want user %v and session %v", got, userID, sessionID)
+	}
+
+	admin, _ := MakeJWT(NewPrincipal(userID, sessionID, RoleAdmin), keys, testValidation, time.Hour)
+	got, err = ParseJWT(admin, keys, testValidation)
+	if err != nil || got.Role != RoleAdmin || !got.HasScope(ScopeAdmin) || !got.HasScope(ScopeChirpsWrite) {
+		t.Errorf("ParseJWT() of an admin's token = %+v, %v, want the admin role and its scopes", got.Principal, err)
+	}
+
+	expired, _ := MakeJWT(NewPrincipal(userID, sessionID, RoleUser), keys, testValidation, -time.Minute)
+	if _, err := ParseJWT(expired, keys, testValidation); err == nil {
+		t.Error("ParseJWT() of an expired token should fail")
+	}
+}
+
+func TestParseJWTClaims(t *testing.T) {
+	keys := testKeys(t, "current")
+	now := time.Now()
+	valid := func() claims {
+		return claims{RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Subject:   uuid.NewString(),
+			Issuer:    "chirpy",
+			Audience:  jwt.ClaimStrings{"chirpy-admin"},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		}, Role: "user"}
+	}
+
+	tests := []struct {
+		name    string
+		change  func(c *claims)
+		v       func(v *Validation)
+		wantErr error
+	}{
+		{name: "Valid", change: func(c *claims) {}},
+		{name: "Expired within leeway", change: func(c *claims) { c.ExpiresAt = jwt.NewNumericDate(now.Add(-30 * time.Second)) }},
+		{name: "Expired", change: func(c *claims) { c.ExpiresAt = jwt.NewNumericDate(now.Add(-2 * time.Minute)) }, wantErr: ErrTokenExpired},
+		{name: "No expiry", change: func(c *claims) { c.ExpiresAt = nil }, wantErr: ErrInvalidToken},
+		{name: "Not yet valid within leeway", change: func(c *claims) { c.NotBefore = jwt.NewNumericDate(now.Add(30 * time.Second)) }},
+		{name: "Not yet valid", change: func(c *claims) { c.NotBefore = jwt.NewNumericDate(now.Add(2 * time.Minute)) }, wantErr: ErrTokenNotYetValid},
+		{name: "Issued in the future", change: func(c *claims) { c.IssuedAt = jwt.NewNumericDate(now.Add(2 * time.Minute)) }, wantErr: ErrTokenNotYetValid},
+		{name: "Wrong issuer", change: func(c *claims) { c.Issuer = "heisenberg" }, wantErr: ErrWrongIssuer},
+		{name: "Wrong audience", change: func(c *claims) { c.Audience = jwt.ClaimStrings{"los-pollos"} }, wantErr: ErrWrongAudience},
+		{name: "No audience", change: func(c *claims) { c.Audience = nil }, wantErr: ErrWrongAudience},
+		{name: "Unknown role", change: func(c *claims) { c.Role = "kingpin" }, wantErr: ErrInvalidToken},
+		{name: "Algorithm not allowed", change: func(c *claims) {}, v: func(v *Validation) { v.Algorithms = []string{"RS256"} }, wantErr: ErrBadSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := valid()
+			tt.change(&c)
+			token := jwt.NewWithClaims(keys.signing.method, c)
+			token.Header["kid"] = keys.signing.ID
+			signed, err := token.SignedString(keys.signing.private)
+			if err != nil {
+				t.Fatal(err)
+			}
+			v := testValidation
+			if tt.v != nil {
+				tt.v(&v)
+			}
+
+			_, err = ParseJWT(signed, keys, v)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr != nil && !errors.Is(err, ErrInvalidToken)) {
+				t.Errorf("ParseJWT() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := MakeJWT(NewPrincipal(uuid.New(), uuid.New(), RoleUser), keys, Validation{Algorithms: []string{"RS256"}}, time.Hour); err == nil {
+		t.Error("MakeJWT() with a signing key whose algorithm isn't allowed should fail")
+	}
+}
+
+func TestHashRefreshToken(t *testing.T) {
+	token, err := MakeRefreshToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hash, err := HashRefreshToken(token, "key")
+	if err != nil {
+		t.Fatalf("HashRefreshToken() error = %v", err)
+	}
+	if hash == token || len(hash) != 64 {
+		t.Errorf("HashRefreshToken() = %q, want a hex SHA-256 that isn't the token", hash)
+	}
+	if again, _ := HashRefreshToken(token, "key"); again != hash {
+		t.Errorf("HashRefreshToken() again = %q, want %q", again, hash)
+	}
+	if otherKey, _ := HashRefreshToken(token, "other-key"); otherKey == hash {
+		t.Error("HashRefreshToken() with another key should give another hash")
+	}
+	if _, err := HashRefreshToken(token, ""); err == nil {
+		t.Error("HashRefreshToken() without a key should fail")
+	}
+}
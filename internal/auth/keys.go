@@ -0,0 +1,213 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// minRSABits is the smallest RSA key LoadKeys accepts.
+const minRSABits = 2048
+
+// Key is a key access tokens are signed or verified with, identified by its
+// kid. A key loaded from a public key file can only verify.
+type Key struct {
+	ID      string
+	method  jwt.SigningMethod
+	private crypto.Signer
+	public  crypto.PublicKey
+}
+
+// KeySet is the key new access tokens are signed with and every key tokens
+// are still accepted from. Rotating keys is done in three steps, each a
+// restart with a changed key directory or signing kid:
+//
+//  1. Add the new private key. It is published in the JWKS but not used,
+//     so other services can pick it up before any token needs it.
+//  2. Point the signing kid at the new key. Tokens signed with the old key
+//     keep working until they expire.
+//  3. Once the access token lifetime has passed, remove the old key.
+type KeySet struct {
+	signing *Key
+	keys    map[string]*Key
+}
+
+// NewKeySet returns a KeySet that signs with the key whose ID is signingKID
+// and verifies with all of keys.
+func NewKeySet(signingKID string, keys ...*Key) (*KeySet, error) {
+	ks := &KeySet{keys: map[string]*Key{}}
+	for _, key := range keys {
+		if _, exists := ks.keys[key.ID]; exists {
+			return nil, fmt.Errorf("duplicate key ID %q", key.ID)
+		}
+		ks.keys[key.ID] = key
+	}
+	signing, ok := ks.keys[signingKID]
+	if !ok {
+		return nil, fmt.Errorf("no key with ID %q to sign with", signingKID)
+	}
+	if signing.private == nil {
+		return nil, fmt.Errorf("key %q is a public key and can't sign", signingKID)
+	}
+	ks.signing = signing
+	return ks, nil
+}
+
+// LoadKeys reads every .pem file in dir as a key whose ID is the file name
+// without the extension. A file can hold an RSA or Ed25519 private key, in
+// PKCS #8 or, for RSA, PKCS #1 form, or a public key to keep accepting
+// tokens from a key that no longer signs. If signingKID is empty, dir must
+// hold exactly one private key, which signs.
+func LoadKeys(dir, signingKID string) (*KeySet, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no .pem files in %s", dir)
+	}
+
+	var keys []*Key
+	var private []string
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		key, err := ParseKey(strings.TrimSuffix(filepath.Base(path), ".pem"), data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		keys = append(keys, key)
+		if key.private != nil {
+			private = append(private, key.ID)
+		}
+	}
+	if signingKID == "" {
+		if len(private) != 1 {
+			return nil, fmt.Errorf("%d private keys in %s; set which one signs", len(private), dir)
+		}
+		signingKID = private[0]
+	}
+	return NewKeySet(signingKID, keys...)
+}
+
+// ParseKey parses a PEM encoded private or public key.
+func ParseKey(kid string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data")
+	}
+
+	var parsed any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newKey(kid, parsed)
+}
+
+// GenerateKey returns a new Ed25519 key. Tokens signed with it stop
+// verifying once the process exits, so it's for tests and development.
+func GenerateKey(kid string) (*Key, error) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return newKey(kid, private)
+}
+
+func newKey(kid string, parsed any) (*Key, error) {
+	if kid == "" {
+		return nil, errors.New("key has no ID")
+	}
+	key := &Key{ID: kid}
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.method, key.private, key.public = jwt.SigningMethodRS256, k, k.Public()
+	case *rsa.PublicKey:
+		key.method, key.public = jwt.SigningMethodRS256, k
+	case ed25519.PrivateKey:
+		key.method, key.private, key.public = jwt.SigningMethodEdDSA, k, k.Public()
+	case ed25519.PublicKey:
+		key.method, key.public = jwt.SigningMethodEdDSA, k
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+	if rsaKey, ok := key.public.(*rsa.PublicKey); ok && rsaKey.N.BitLen() < minRSABits {
+		return nil, fmt.Errorf("RSA key is %d bits, want at least %d", rsaKey.N.BitLen(), minRSABits)
+	}
+	return key, nil
+}
+
+// keyFunc looks up the key a token names in its kid header, refusing a
+// token whose algorithm isn't that key's.
+func (ks *KeySet) keyFunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key ID %q", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("key %q is for %s, not %s", kid, key.method.Alg(), token.Method.Alg())
+	}
+	return key.public, nil
+}
+
+// JWK is a public key in the JSON Web Key format of RFC 7517.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+}
+
+// JWKS is the public half of every key in the set, in kid order, for other
+// services to verify tokens with.
+func (ks *KeySet) JWKS() []JWK {
+	jwks := make([]JWK, 0, len(ks.keys))
+	for _, key := range ks.keys {
+		jwk := JWK{KeyID: key.ID, Use: "sig", Algorithm: key.method.Alg()}
+		switch public := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		}
+		jwks = append(jwks, jwk)
+	}
+	slices.SortFunc(jwks, func(a, b JWK) int {
+		return strings.Compare(a.KeyID, b.KeyID)
+	})
+	return jwks
+}
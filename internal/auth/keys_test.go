@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func writePEM(t *testing.T, dir, name, blockType string, der []byte) {
+	t.Helper()
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadKeysRotation(t *testing.T) {
+	dir := t.TempDir()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, dir, "2024-01.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))
+
+	old, err := LoadKeys(dir, "")
+	if err != nil {
+		t.Fatalf("LoadKeys() with one key error = %v", err)
+	}
+	oldToken, err := MakeJWT(NewPrincipal(uuid.New(), uuid.New(), RoleUser), old, testValidation, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(edKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, dir, "2024-06.pem", "PRIVATE KEY", der)
+	if _, err := LoadKeys(dir, ""); err == nil {
+		t.Error("LoadKeys() with two private keys and no signing key should fail")
+	}
+
+	rotated, err := LoadKeys(dir, "2024-06")
+	if err != nil {
+		t.Fatalf("LoadKeys() error = %v", err)
+	}
+	newToken, _ := MakeJWT(NewPrincipal(uuid.New(), uuid.New(), RoleUser), rotated, testValidation, time.Hour)
+	if _, err := ParseJWT(oldToken, rotated, testValidation); err != nil {
+		t.Errorf("ParseJWT() of a token from the old key during the overlap error = %v", err)
+	}
+	if _, err := ParseJWT(newToken, rotated, testValidation); err != nil {
+		t.Errorf("ParseJWT() of a token from the new key error = %v", err)
+	}
+	if _, err := ParseJWT(newToken, old, testValidation); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("ParseJWT() with a key set that lacks the new key error = %v, want ErrInvalidToken", err)
+	}
+
+	pub, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, dir, "2024-01.pem", "PUBLIC KEY", pub)
+	retired, err := LoadKeys(dir, "")
+	if err != nil {
+		t.Fatalf("LoadKeys() with the old key public only error = %v", err)
+	}
+	if _, err := ParseJWT(oldToken, retired, testValidation); err != nil {
+		t.Errorf("ParseJWT() with the old public key error = %v", err)
+	}
+	if _, err := LoadKeys(dir, "2024-01"); err == nil {
+		t.Error("LoadKeys() signing with a public key should fail")
+	}
+
+	jwks := retired.JWKS()
+	if len(jwks) != 2 || jwks[0].KeyID != "2024-01" || jwks[0].KeyType != "RSA" || jwks[0].Algorithm != "RS256" || jwks[0].E != "AQAB" ||
+		jwks[1].KeyID != "2024-06" || jwks[1].KeyType != "OKP" || jwks[1].Curve != "Ed25519" || jwks[1].Algorithm != "EdDSA" || jwks[1].X == "" {
+		t.Errorf("JWKS() = %+v", jwks)
+	}
+}
+
+func TestParseJWTRejectsForgedAlgorithms(t *testing.T) {
+	keys := testKeys(t, "current")
+	claims := jwt.RegisteredClaims{
+		ID:        uuid.NewString(),
+		Subject:   uuid.NewString(),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}
+
+	hmac := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	hmac.Header["kid"] = "current"
+	forged, err := hmac.SignedString([]byte(keys.signing.public.(ed25519.PublicKey)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseJWT(forged, keys, testValidation); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("ParseJWT() of an HS256 token keyed with the public key error = %v, want ErrInvalidToken", err)
+	}
+
+	unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseJWT(unsigned, keys, testValidation); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("ParseJWT() of an unsigned token error = %v, want ErrInvalidToken", err)
+	}
+}
@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"slices"
+)
+
+// Role is what a user is trusted with, as stored in users.role.
+type Role string
+
+const (
+	RoleUser  Role = "user"
+	RoleAdmin Role = "admin"
+)
+
+// Scopes are what a route asks of whoever calls it. Every route that needs
+// a login asks for one.
+const (
+	ScopeChirpsRead   = "chirps:read"
+	ScopeChirpsWrite  = "chirps:write"
+	ScopeFollowsWrite = "follows:write"
+	// ScopeAccount covers the account itself: credentials, sessions,
+	// exports and deletion.
+	ScopeAccount = "account"
+	ScopeAdmin   = "admin"
+)
+
+// roleScopes are the scopes each role grants.
+var roleScopes = map[Role][]string{
+	RoleUser:  {ScopeChirpsRead, ScopeChirpsWrite, ScopeFollowsWrite, ScopeAccount},
+	RoleAdmin: {ScopeChirpsRead, ScopeChirpsWrite, ScopeFollowsWrite, ScopeAccount, ScopeAdmin},
+}
+
+// ParseRole returns the role named role, or an error if there's no such
+// role.
+func ParseRole(role string) (Role, error) {
+	if _, ok := roleScopes[Role(role)]; !ok {
+		return "", fmt.Errorf("unknown role %q", role)
+	}
+	return Role(role), nil
+}
+
+// Scopes returns the scopes role grants.
+func (role Role) Scopes() []string {
+	return slices.Clone(roleScopes[role])
+}
+
+// Principal is who a request is made by and what they may do.
+type Principal struct {
+	UserID    uuid.UUID
+	SessionID uuid.UUID
+	Role      Role
+	Scopes    []string
+}
+
+// NewPrincipal returns the principal of a session of a user with role,
+// which has every scope the role grants.
+func NewPrincipal(userID, sessionID uuid.UUID, role Role) Principal {
+	return Principal{
+		UserID:    userID,
+		SessionID: sessionID,
+		Role:      role,
+		Scopes:    role.Scopes(),
+	}
+}
+
+// HasScope reports whether p may do what scope covers.
+func (p Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope)
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx that carries p.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom returns the principal ctx carries, if any.
+func PrincipalFrom(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
@@ -4,16 +4,30 @@ import (
 	"github.com/joho/godotenv"
 	"log"
 	"os"
+	"strings"
 	"time"
 )
 
 const Port = "8080"
 
 type ApiConfig struct {
-	Platform      string
-	JWTSecret     string
-	TokenDuration map[string]time.Duration
-	PolkaKey      string
+	Platform         string
+	DBURL            string
+	MigrateOnStartup bool
+	JWTKeysDir       string
+	JWTSigningKey    string
+	JWTIssuer        string
+	JWTAudiences     []string
+	JWTAlgorithms    []string
+	JWTLeeway        time.Duration
+	RefreshTokenKey  string
+	APIKeyHashKey    string
+	TokenDuration    map[string]time.Duration
+	PolkaKey         string
+	TrashRetention   time.Duration
+	HandleReserve    time.Duration
+	DeletionGrace    time.Duration
+	ExportRetention  time.Duration
 }
 
 var api ApiConfig
@@ -21,20 +35,71 @@ var api ApiConfig
 func init() {
 	err := godotenv.Load()
 	if err != nil {
-		log.Fatal("Error loading .env file")
+		log.Println("No .env file loaded, reading configuration from the environment")
 	}
 
 	api = ApiConfig{
-		Platform:  os.Getenv("PLATFORM"),
-		JWTSecret: os.Getenv("JWT_SECRET"),
+		Platform:         os.Getenv("PLATFORM"),
+		DBURL:            os.Getenv("DB_URL"),
+		MigrateOnStartup: os.Getenv("MIGRATE_ON_STARTUP") == "true",
+		JWTKeysDir:       os.Getenv("JWT_KEYS_DIR"),
+		JWTSigningKey:    os.Getenv("JWT_SIGNING_KEY"),
+		JWTIssuer:        stringFromEnv("JWT_ISSUER", "chirpy"),
+		JWTAudiences:     listFromEnv("JWT_AUDIENCES", []string{"chirpy"}),
+		JWTAlgorithms:    listFromEnv("JWT_ALGORITHMS", []string{"EdDSA", "RS256"}),
+		JWTLeeway:        durationFromEnv("JWT_LEEWAY", 30*time.Second),
+		RefreshTokenKey:  os.Getenv("REFRESH_TOKEN_KEY"),
+		APIKeyHashKey:    os.Getenv("API_KEY_HASH_KEY"),
 		TokenDuration: map[string]time.Duration{
 			"access":  time.Hour,
 			"refresh": time.Hour * 24 * 60,
 		},
-		PolkaKey: os.Getenv("POLKA_KEY"),
+		PolkaKey:        os.Getenv("POLKA_KEY"),
+		TrashRetention:  durationFromEnv("TRASH_RETENTION", time.Hour*24*30),
+		HandleReserve:   durationFromEnv("HANDLE_RESERVE", time.Hour*24*30),
+		DeletionGrace:   durationFromEnv("DELETION_GRACE", time.Hour*24*14),
+		ExportRetention: durationFromEnv("EXPORT_RETENTION", time.Hour*24*7),
 	}
 }
 
+// durationFromEnv parses key as a time.Duration, falling back to def when
+// it is unset.
+func durationFromEnv(key string, def time.Duration) time.Duration {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return def
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", key, err)
+	}
+	return d
+}
+
+// stringFromEnv is os.Getenv(key), falling back to def when it is unset.
+func stringFromEnv(key, def string) string {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return def
+	}
+	return raw
+}
+
+// listFromEnv splits key on commas, falling back to def when it is unset.
+func listFromEnv(key string, def []string) []string {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return def
+	}
+	var list []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
 func APIConfig() *ApiConfig {
 	return &api
 }
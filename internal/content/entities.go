@@ -0,0 +1,98 @@
+package content
+
+import (
+	"golang.org/x/text/unicode/norm"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// EntityType is the kind of thing an Entity marks in a chirp body.
+type EntityType string
+
+const (
+	EntityHashtag EntityType = "hashtag"
+	EntityMention EntityType = "mention"
+	EntityLink    EntityType = "link"
+)
+
+// Entity is a hashtag, mention or link in a chirp body. Start and End are
+// offsets in Unicode code points, End exclusive, and cover the # or @ too.
+// Text is the tag or name in normalized form, or the URL of a link.
+type Entity struct {
+	Type  EntityType
+	Text  string
+	Start int
+	End   int
+}
+
+// MaxHashtagLength is the longest tag, in characters, that counts as a
+// hashtag.
+const MaxHashtagLength = 50
+
+var hashtagPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{M}\p{N}_#&/])#([\p{L}\p{M}\p{N}_]+)`)
+
+// NormalizeTag puts a hashtag in the form it is stored and looked up in, so
+// #Go, #GO and #ｇｏ are all the same tag. A leading # is dropped. It returns
+// false for anything that is not a valid tag.
+func NormalizeTag(tag string) (string, bool) {
+	tag = strings.ToLower(norm.NFKC.String(strings.TrimPrefix(tag, "#")))
+	match := hashtagPattern.FindStringSubmatch("#" + tag)
+	if match == nil || match[1] != tag || !isHashtag(tag) {
+		return "", false
+	}
+	return tag, true
+}
+
+// isHashtag rules out tags that are too long or have no letter in them, so
+// that "#1" is just a number.
+func isHashtag(tag string) bool {
+	return utf8.RuneCountInString(tag) <= MaxHashtagLength &&
+		strings.IndexFunc(tag, func(r rune) bool { return !strings.ContainsRune("0123456789_", r) }) >= 0
+}
+
+// FindEntities returns every hashtag, mention and link in body, in the
+// order they appear. A # or @ inside a link is part of the link.
+func FindEntities(body string) []Entity {
+	var entities []Entity
+	var links [][2]int
+	for _, loc := range linkPattern.FindAllStringIndex(body, -1) {
+		link := trimLink(body[loc[0]:loc[1]])
+		links = append(links, [2]int{loc[0], loc[0] + len(link)})
+		entities = append(entities, entityAt(body, EntityLink, link, loc[0], loc[0]+len(link)))
+	}
+	inLink := func(i int) bool {
+		return slices.ContainsFunc(links, func(link [2]int) bool { return i >= link[0] && i < link[1] })
+	}
+
+	for _, loc := range hashtagPattern.FindAllStringSubmatchIndex(body, -1) {
+		tag, ok := NormalizeTag(body[loc[2]:loc[3]])
+		if !ok || inLink(loc[2]) {
+			continue
+		}
+		entities = append(entities, entityAt(body, EntityHashtag, tag, loc[2]-1, loc[3]))
+	}
+	for _, loc := range mentionPattern.FindAllStringSubmatchIndex(body, -1) {
+		if inLink(loc[2]) {
+			continue
+		}
+		entities = append(entities, entityAt(body, EntityMention, strings.ToLower(body[loc[2]:loc[3]]), loc[2]-1, loc[3]))
+	}
+
+	slices.SortFunc(entities, func(a, b Entity) int {
+		return a.Start - b.Start
+	})
+	return entities
+}
+
+// entityAt converts the byte offsets start and end in body to code points.
+func entityAt(body string, kind EntityType, text string, start, end int) Entity {
+	runeStart := utf8.RuneCountInString(body[:start])
+	return Entity{
+		Type:  kind,
+		Text:  text,
+		Start: runeStart,
+		End:   runeStart + utf8.RuneCountInString(body[start:end]),
+	}
+}
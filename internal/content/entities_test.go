@@ -0,0 +1,74 @@
+package content
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestFindEntities(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []Entity
+	}{
+		{
+			name: "Offsets are in code points",
+			body: "café #Go @Walt",
+			want: []Entity{
+				{Type: EntityHashtag, Text: "go", Start: 5, End: 8},
+				{Type: EntityMention, Text: "walt", Start: 9, End: 14},
+			},
+		},
+		{
+			name: "Links keep what is inside them",
+			body: "🐦 https://example.com/@walt#top.",
+			want: []Entity{
+				{Type: EntityLink, Text: "https://example.com/@walt#top", Start: 2, End: 31},
+			},
+		},
+		{
+			name: "Not entities",
+			body: "me@example.com #1 a#b",
+		},
+		{
+			name: "Unicode hashtags",
+			body: "#日本語 #Ünïcode",
+			want: []Entity{
+				{Type: EntityHashtag, Text: "日本語", Start: 0, End: 4},
+				{Type: EntityHashtag, Text: "ünïcode", Start: 5, End: 13},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FindEntities(tt.body); !slices.Equal(got, tt.want) {
+				t.Errorf("FindEntities(%q) = %+v, want %+v", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeTag(t *testing.T) {
+	tests := []struct {
+		tag    string
+		want   string
+		wantOK bool
+	}{
+		{tag: "#Go", want: "go", wantOK: true},
+		{tag: "ＧＯ", want: "go", wantOK: true},
+		{tag: "2024_recap", want: "2024_recap", wantOK: true},
+		{tag: "123", wantOK: false},
+		{tag: "two words", wantOK: false},
+		{tag: "", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			got, ok := NormalizeTag(tt.tag)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("NormalizeTag(%q) = %q, %v, want %q, %v", tt.tag, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package content
+
+import (
+	"errors"
+	"golang.org/x/text/unicode/norm"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Action is what a Filter does to a chirp that contains one of its terms.
+type Action string
+
+const (
+	// ActionMask replaces the word with ****.
+	ActionMask Action = "mask"
+	// ActionReject refuses the chirp with a "profanity" violation.
+	ActionReject Action = "reject"
+	// ActionFlag lets the chirp through as written and records the term in
+	// Chirp.Flags so a moderator can review it.
+	ActionFlag Action = "flag"
+)
+
+func (a Action) Valid() bool {
+	return a == ActionMask || a == ActionReject || a == ActionFlag
+}
+
+// Term is a word a Filter looks for, in folded form, and what it does when
+// it finds it.
+type Term struct {
+	Term   string
+	Action Action
+}
+
+// DefaultTerms are what a Filter starts with before any have been loaded.
+var DefaultTerms = []Term{
+	{Term: "kerfuffle", Action: ActionMask},
+	{Term: "sharbert", Action: ActionMask},
+	{Term: "fornax", Action: ActionMask},
+}
+
+// leet maps the digits and symbols commonly typed in place of a letter back
+// to that letter.
+var leet = map[rune]rune{
+	'0': 'o', '1': 'i', '!': 'i', '3': 'e', '4': 'a',
+	'@': 'a', '5': 's', '$': 's', '7': 't', '+': 't',
+}
+
+// Fold puts s in the form terms are compared in: compatibility decomposed,
+// without accents, lower case and with leetspeak spelled out. "Fòrnax",
+// "ｆｏｒｎａｘ" and "f0rn4x" all fold to "fornax".
+func Fold(s string) string {
+	var b strings.Builder
+	for _, r := range norm.NFKD.String(s) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		if letter, ok := leet[r]; ok {
+			r = letter
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// NewTerm folds term and checks that it is a single word, which is all a
+// Filter can match.
+func NewTerm(term string, action Action) (Term, error) {
+	if !action.Valid() {
+		return Term{}, errors.New("action must be 'mask', 'reject' or 'flag'")
+	}
+	folded := Fold(strings.TrimSpace(term))
+	if folded == "" {
+		return Term{}, errors.New("term is empty")
+	}
+	if strings.IndexFunc(folded, func(r rune) bool { return !isWordRune(r) }) >= 0 {
+		return Term{}, errors.New("term must be a single word")
+	}
+	return Term{Term: folded, Action: action}, nil
+}
+
+// isWordRune reports whether r can be part of a word, counting the symbols
+// in leet as letters.
+func isWordRune(r rune) bool {
+	_, isLeet := leet[r]
+	return isLeet || unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r)
+}
+
+func isLetterOrDigit(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r)
+}
+
+// Filter finds terms in a chirp however they are written. Its terms can be
+// replaced while chirps are being checked.
+type Filter struct {
+	mu    sync.RWMutex
+	terms map[string]Action
+}
+
+func NewFilter(terms []Term) *Filter {
+	f := &Filter{}
+	f.Replace(terms)
+	return f
+}
+
+// Replace swaps every term the filter looks for for terms.
+func (f *Filter) Replace(terms []Term) {
+	byTerm := make(map[string]Action, len(terms))
+	for _, term := range terms {
+		byTerm[Fold(term.Term)] = term.Action
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.terms = byTerm
+}
+
+// Rule checks every word of the body against the filter's terms. A word
+// matches if it folds to a term, either as written or without the
+// punctuation around it, so "Kerfuffle!" and "$harbert" both match. Words
+// that are part of a link are skipped, since masking one would break it.
+func (f *Filter) Rule() Rule {
+	return Rule{
+		Name: "profanity",
+		Apply: func(c *Chirp) string {
+			f.mu.RLock()
+			terms := f.terms
+			f.mu.RUnlock()
+
+			var body strings.Builder
+			rejected := false
+			last := 0
+			links := linkSpans(c.Body)
+			for _, w := range words(c.Body) {
+				if w.overlaps(links) {
+					continue
+				}
+				term, action, start, end, ok := match(terms, c.Body, w)
+				if !ok {
+					continue
+				}
+				switch action {
+				case ActionMask:
+					body.WriteString(c.Body[last:start])
+					body.WriteString("****")
+					last = end
+				case ActionReject:
+					rejected = true
+				case ActionFlag:
+					c.Flags = appendUnique(c.Flags, term)
+				}
+			}
+			body.WriteString(c.Body[last:])
+			c.Body = body.String()
+
+			if rejected {
+				return "Chirp contains a blocked word"
+			}
+			return ""
+		},
+	}
+}
+
+type span struct {
+	start, end int
+}
+
+// overlaps reports whether s shares any bytes with one of spans.
+func (s span) overlaps(spans []span) bool {
+	for _, other := range spans {
+		if s.start < other.end && other.start < s.end {
+			return true
+		}
+	}
+	return false
+}
+
+// words splits s into runs of word runes, by byte offset.
+func words(s string) []span {
+	var spans []span
+	start := -1
+	for i, r := range s {
+		switch {
+		case isWordRune(r) && start < 0:
+			start = i
+		case !isWordRune(r) && start >= 0:
+			spans = append(spans, span{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, span{start, len(s)})
+	}
+	return spans
+}
+
+// match looks w up in terms, first as written and then with leading and
+// trailing symbols trimmed, and returns the part of s that matched.
+func match(terms map[string]Action, s string, w span) (term string, action Action, start, end int, ok bool) {
+	word := s[w.start:w.end]
+	if action, ok := terms[Fold(word)]; ok {
+		return Fold(word), action, w.start, w.end, true
+	}
+
+	trimmed := strings.TrimLeftFunc(word, func(r rune) bool { return !isLetterOrDigit(r) })
+	start = w.start + len(word) - len(trimmed)
+	trimmed = strings.TrimRightFunc(trimmed, func(r rune) bool { return !isLetterOrDigit(r) })
+	end = start + len(trimmed)
+	if trimmed == "" || trimmed == word {
+		return "", "", 0, 0, false
+	}
+	if action, ok := terms[Fold(trimmed)]; ok {
+		return Fold(trimmed), action, start, end, true
+	}
+	return "", "", 0, 0, false
+}
@@ -0,0 +1,116 @@
+package content
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestFold(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "Fornax", want: "fornax"},
+		{in: "fòrnax", want: "fornax"},
+		{in: "ｆｏｒｎａｘ", want: "fornax"},
+		{in: "sh4rb3rt", want: "sharbert"},
+		{in: "$h@rb3r7", want: "sharbert"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := Fold(tt.in); got != tt.want {
+				t.Errorf("Fold(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilter(t *testing.T) {
+	filter := NewFilter([]Term{
+		{Term: "fornax", Action: ActionMask},
+		{Term: "kerfuffle", Action: ActionMask},
+		{Term: "sharbert", Action: ActionMask},
+		{Term: "crikey", Action: ActionReject},
+		{Term: "blimey", Action: ActionFlag},
+	})
+	tests := []struct {
+		name      string
+		body      string
+		want      string
+		wantFlags []string
+		wantError bool
+	}{
+		{name: "Trailing punctuation", body: "What a Kerfuffle!", want: "What a ****!"},
+		{name: "Surrounding punctuation", body: "fornax, (sharbert).", want: "****, (****)."},
+		{name: "Case", body: "FORNAX", want: "****"},
+		{name: "Accents", body: "fòrnax", want: "****"},
+		{name: "Fullwidth", body: "ｆｏｒｎａｘ today", want: "**** today"},
+		{name: "Leetspeak", body: "such a sh4rb3rt", want: "such a ****"},
+		{name: "Leading symbol", body: "$harbert", want: "****"},
+		{name: "Part of a longer word", body: "fornaxes", want: "fornaxes"},
+		{name: "Inside a link", body: "see https://kerfuffle.com/fornax, fornax", want: "see https://kerfuffle.com/fornax, ****"},
+		{name: "Rejected term inside a link", body: "https://crikey.example", want: "https://crikey.example"},
+		{name: "Rejected", body: "well Crikey", wantError: true},
+		{name: "Flagged", body: "Bl1mey, again blimey", want: "Bl1mey, again blimey", wantFlags: []string{"blimey"}},
+	}
+
+	pipeline := NewPipeline(Normalize(), filter.Rule())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := pipeline.Run(tt.body)
+			var validationErr *ValidationError
+			if tt.wantError {
+				if !errors.As(err, &validationErr) || validationErr.Violations[0].Rule != "profanity" {
+					t.Errorf("Run(%q) error = %v, want a profanity violation", tt.body, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Run(%q) error = %v", tt.body, err)
+			}
+			if got.Body != tt.want || !slices.Equal(got.Flags, tt.wantFlags) {
+				t.Errorf("Run(%q) = %+v, want body %q and flags %v", tt.body, got, tt.want, tt.wantFlags)
+			}
+		})
+	}
+}
+
+func TestFilterReplace(t *testing.T) {
+	filter := NewFilter(DefaultTerms)
+	rule := filter.Rule()
+
+	filter.Replace([]Term{{Term: "Cr1key", Action: ActionMask}})
+	c := Chirp{Body: "crikey, fornax"}
+	rule.Apply(&c)
+	if c.Body != "****, fornax" {
+		t.Errorf("Body after Replace() = %q, want only the new term masked", c.Body)
+	}
+}
+
+func TestNewTerm(t *testing.T) {
+	tests := []struct {
+		term    string
+		action  Action
+		want    string
+		wantErr bool
+	}{
+		{term: " Sh4rbert ", action: ActionMask, want: "sharbert"},
+		{term: "crikey", action: "delete", wantErr: true},
+		{term: "  ", action: ActionReject, wantErr: true},
+		{term: "two words", action: ActionFlag, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.term, func(t *testing.T) {
+			got, err := NewTerm(tt.term, tt.action)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewTerm(%q, %q) error = %v, wantErr %v", tt.term, tt.action, err, tt.wantErr)
+			}
+			if got.Term != tt.want {
+				t.Errorf("NewTerm(%q, %q) = %q, want %q", tt.term, tt.action, got.Term, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+// Package content turns the body a user submits into the body Chirpy stores.
+// Every endpoint that accepts a chirp body runs it through the same Pipeline,
+// so the rules are enforced rather than advisory.
+package content
+
+import (
+	"strings"
+)
+
+// Chirp is a chirp body on its way through a Pipeline, along with what the
+// rules found in it so far.
+type Chirp struct {
+	Body     string
+	Links    []string
+	Mentions []string
+	Hashtags []string
+	// Flags are the filter terms found in the chirp that call for review.
+	Flags []string
+}
+
+// Violation is a rule a chirp broke.
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// ValidationError is returned by Pipeline.Run when a chirp breaks one or
+// more rules. It lists every violation, not just the first.
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		messages = append(messages, v.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Rule is one step of a Pipeline. Apply may rewrite the chirp or record what
+// it found, and returns a message if the chirp breaks the rule.
+type Rule struct {
+	Name  string
+	Apply func(c *Chirp) (violation string)
+}
+
+type Pipeline struct {
+	rules []Rule
+}
+
+// NewPipeline returns a Pipeline that applies rules in order.
+func NewPipeline(rules ...Rule) *Pipeline {
+	return &Pipeline{rules: rules}
+}
+
+// Default is the pipeline every chirp body goes through, checking it against
+// the terms in filter and allowing up to limit characters. Links are
+// extracted before the filter runs, and the filter leaves them as written.
+func Default(filter *Filter, limit int) *Pipeline {
+	return NewPipeline(
+		Normalize(),
+		NotEmpty(),
+		MaxLength(limit),
+		ExtractLinks(),
+		filter.Rule(),
+		ExtractMentions(),
+		ExtractHashtags(),
+	)
+}
+
+// Run applies every rule to body. Later rules still run after one fails, so
+// the error describes everything wrong with the chirp at once.
+func (p *Pipeline) Run(body string) (Chirp, error) {
+	c := Chirp{Body: body}
+	var violations []Violation
+	for _, rule := range p.rules {
+		if message := rule.Apply(&c); message != "" {
+			violations = append(violations, Violation{Rule: rule.Name, Message: message})
+		}
+	}
+	if len(violations) > 0 {
+		return Chirp{}, &ValidationError{Violations: violations}
+	}
+	return c, nil
+}
@@ -0,0 +1,165 @@
+package content
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestPipeline(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		want         Chirp
+		wantRules    []string
+		wantErrorMsg string
+	}{
+		{
+			name: "Clean chirp",
+			body: "I had something interesting for breakfast",
+			want: Chirp{Body: "I had something interesting for breakfast"},
+		},
+		{
+			name: "Profanity is masked",
+			body: "I hear Mastodon is better than Chirpy. sharbert I need to migrate",
+			want: Chirp{Body: "I hear Mastodon is better than Chirpy. **** I need to migrate"},
+		},
+		{
+			name: "Profanity is masked regardless of case",
+			body: "Kerfuffle fornax",
+			want: Chirp{Body: "**** ****"},
+		},
+		{
+			name: "Links are left unmasked",
+			body: "kerfuffle at https://kerfuffle.com",
+			want: Chirp{
+				Body:  "**** at https://kerfuffle.com",
+				Links: []string{"https://kerfuffle.com"},
+			},
+		},
+		{
+			name: "Whitespace and line breaks are normalized",
+			body: "  first line\r\nsecond line \n",
+			want: Chirp{Body: "first line\nsecond line"},
+		},
+		{
+			name: "Unicode is normalized to NFC",
+			body: "café",
+			want: Chirp{Body: "café"},
+		},
+		{
+			name: "Links and mentions are extracted",
+			body: "@walt read https://example.com/blue-sky. cc @Jesse, not me@example.com",
+			want: Chirp{
+				Body:     "@walt read https://example.com/blue-sky. cc @Jesse, not me@example.com",
+				Links:    []string{"https://example.com/blue-sky"},
+				Mentions: []string{"walt", "jesse"},
+			},
+		},
+		{
+			name: "Hashtags are extracted",
+			body: "#Breaking news, #breaking again and #ｇｏ but not #1 or https://example.com/#anchor",
+			want: Chirp{
+				Body:     "#Breaking news, #breaking again and #ｇｏ but not #1 or https://example.com/#anchor",
+				Links:    []string{"https://example.com/#anchor"},
+				Hashtags: []string{"breaking", "go"},
+			},
+		},
+		{
+			name:         "Empty",
+			body:         " \n ",
+			wantRules:    []string{"not_empty"},
+			wantErrorMsg: "Chirp is empty",
+		},
+		{
+			name:         "Too long",
+			body:         strings.Repeat("a", MaxChirpLength+1),
+			wantRules:    []string{"max_length"},
+			wantErrorMsg: "Chirp is longer than 140 characters",
+		},
+		{
+			name: "Exactly the limit",
+			body: strings.Repeat("a", MaxChirpLength),
+			want: Chirp{Body: strings.Repeat("a", MaxChirpLength)},
+		},
+		{
+			name: "Emoji count as one character each",
+			body: strings.Repeat("👍🏽", 70),
+			want: Chirp{Body: strings.Repeat("👍🏽", 70)},
+		},
+	}
+
+	pipeline := Default(NewFilter(DefaultTerms), MaxChirpLength)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := pipeline.Run(tt.body)
+
+			var validationErr *ValidationError
+			if tt.wantRules == nil {
+				if err != nil {
+					t.Fatalf("Run() error = %v", err)
+				}
+			} else if !errors.As(err, &validationErr) {
+				t.Fatalf("Run() error = %v, want a *ValidationError", err)
+			} else {
+				var rules []string
+				for _, v := range validationErr.Violations {
+					rules = append(rules, v.Rule)
+				}
+				if !slices.Equal(rules, tt.wantRules) || err.Error() != tt.wantErrorMsg {
+					t.Errorf("Run() violations = %+v, want rules %v", validationErr.Violations, tt.wantRules)
+				}
+				return
+			}
+
+			if got.Body != tt.want.Body || !slices.Equal(got.Links, tt.want.Links) || !slices.Equal(got.Mentions, tt.want.Mentions) || !slices.Equal(got.Hashtags, tt.want.Hashtags) {
+				t.Errorf("Run() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPipelineReportsEveryViolation(t *testing.T) {
+	_, err := NewPipeline(MaxLength(1), MaxLength(2)).Run("abc")
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || len(validationErr.Violations) != 2 {
+		t.Errorf("Run() error = %v, want two violations", err)
+	}
+}
+
+func TestLength(t *testing.T) {
+	longURL := "https://example.com/" + strings.Repeat("a", 200)
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{name: "ASCII", body: "hello", want: 5},
+		{name: "Accents", body: "cafe\u0301", want: 4},
+		{name: "Emoji with skin tone", body: "👍🏽👍🏽", want: 2},
+		{name: "Family emoji", body: "👨‍👩‍👧", want: 1},
+		{name: "Flags", body: "🇳🇿🇯🇵", want: 2},
+		{name: "Links count at a fixed length", body: "see " + longURL, want: 4 + LinkLength},
+		{name: "Short links too", body: "http://a.co", want: LinkLength},
+		{name: "Punctuation after a link", body: longURL + ".", want: LinkLength + 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Length(tt.body); got != tt.want {
+				t.Errorf("Length(%q) = %d, want %d", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLengthLimit(t *testing.T) {
+	body := strings.Repeat("a", MaxChirpLength+1)
+	if _, err := Default(NewFilter(nil), LengthLimit(false)).Run(body); err == nil {
+		t.Errorf("Run() of %d characters on the standard tier should fail", len(body))
+	}
+	if _, err := Default(NewFilter(nil), LengthLimit(true)).Run(body); err != nil {
+		t.Errorf("Run() of %d characters on Chirpy Red error = %v", len(body), err)
+	}
+}
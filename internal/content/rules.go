@@ -0,0 +1,160 @@
+package content
+
+import (
+	"fmt"
+	"github.com/rivo/uniseg"
+	"golang.org/x/text/unicode/norm"
+	"regexp"
+	"strings"
+)
+
+const (
+	// MaxChirpLength is how long a chirp can be, as measured by Length.
+	MaxChirpLength = 140
+	// MaxChirpyRedChirpLength is the longer limit Chirpy Red members get.
+	MaxChirpyRedChirpLength = 280
+	// LinkLength is how much every link counts towards the limit, however
+	// long its URL is.
+	LinkLength = 23
+)
+
+// LengthLimit is the longest chirp a user on the given tier can post.
+func LengthLimit(isChirpyRed bool) int {
+	if isChirpyRed {
+		return MaxChirpyRedChirpLength
+	}
+	return MaxChirpLength
+}
+
+// Length measures body the way a reader would: in user-perceived characters
+// (grapheme clusters), so an emoji or an accented letter counts as one, with
+// each link counting as LinkLength.
+func Length(body string) int {
+	n, last := 0, 0
+	for _, link := range linkSpans(body) {
+		n += uniseg.GraphemeClusterCount(body[last:link.start]) + LinkLength
+		last = link.end
+	}
+	return n + uniseg.GraphemeClusterCount(body[last:])
+}
+
+// Normalize trims surrounding whitespace, uses \n for every line break and
+// puts the body in Unicode NFC form, so that the same text is always stored
+// the same way.
+func Normalize() Rule {
+	return Rule{
+		Name: "normalize",
+		Apply: func(c *Chirp) string {
+			body := strings.ReplaceAll(c.Body, "\r\n", "\n")
+			body = strings.ReplaceAll(body, "\r", "\n")
+			c.Body = norm.NFC.String(strings.TrimSpace(body))
+			return ""
+		},
+	}
+}
+
+func NotEmpty() Rule {
+	return Rule{
+		Name: "not_empty",
+		Apply: func(c *Chirp) string {
+			if c.Body == "" {
+				return "Chirp is empty"
+			}
+			return ""
+		},
+	}
+}
+
+// MaxLength rejects chirps longer than limit, as measured by Length.
+func MaxLength(limit int) Rule {
+	return Rule{
+		Name: "max_length",
+		Apply: func(c *Chirp) string {
+			if Length(c.Body) > limit {
+				return fmt.Sprintf("Chirp is longer than %d characters", limit)
+			}
+			return ""
+		},
+	}
+}
+
+var linkPattern = regexp.MustCompile(`https?://[^\s<>"]+`)
+
+// ExtractLinks records every http and https URL in the body. Punctuation
+// that ends a sentence is not taken to be part of the URL.
+func ExtractLinks() Rule {
+	return Rule{
+		Name: "links",
+		Apply: func(c *Chirp) string {
+			c.Links = nil
+			for _, link := range linkSpans(c.Body) {
+				c.Links = appendUnique(c.Links, c.Body[link.start:link.end])
+			}
+			return ""
+		},
+	}
+}
+
+// linkSpans finds every link in body, by byte offset, without the
+// punctuation trimLink drops.
+func linkSpans(body string) []span {
+	var spans []span
+	for _, loc := range linkPattern.FindAllStringIndex(body, -1) {
+		link := trimLink(body[loc[0]:loc[1]])
+		spans = append(spans, span{loc[0], loc[0] + len(link)})
+	}
+	return spans
+}
+
+// trimLink drops punctuation that ends the sentence a link is in.
+func trimLink(link string) string {
+	return strings.TrimRight(link, ".,;:!?)]}'\"")
+}
+
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@/])@(\w{1,30})\b`)
+
+// ExtractMentions records every @name in the body, in lower case and
+// without the @. An @ in the middle of a word, as in an email address, is not
+// a mention.
+func ExtractMentions() Rule {
+	return Rule{
+		Name: "mentions",
+		Apply: func(c *Chirp) string {
+			c.Mentions = entityTexts(c.Body, EntityMention)
+			return ""
+		},
+	}
+}
+
+// ExtractHashtags records every #tag in the body, normalized by
+// NormalizeTag.
+func ExtractHashtags() Rule {
+	return Rule{
+		Name: "hashtags",
+		Apply: func(c *Chirp) string {
+			c.Hashtags = entityTexts(c.Body, EntityHashtag)
+			return ""
+		},
+	}
+}
+
+// entityTexts lists the text of every entity of the given type in body,
+// each once.
+func entityTexts(body string, kind EntityType) []string {
+	var texts []string
+	for _, entity := range FindEntities(body) {
+		if entity.Type == kind {
+			texts = appendUnique(texts, entity.Text)
+		}
+	}
+	return texts
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, existing := range list {
+		if strings.EqualFold(existing, s) {
+			return list
+		}
+	}
+	return append(list, s)
+}
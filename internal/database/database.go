@@ -1,29 +1,179 @@
 package database
 
 import (
+	"context"
 	"database/sql"
-	"github.com/joho/godotenv"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/pcauce/chirpy/internal/sqlc"
-	"log"
-	"os"
+	"time"
 )
 
-var queries *sqlc.Queries
+// Store is everything the handlers need from persistence. Apart from WithTx
+// its method set mirrors the sqlc generated queries.
+type Store interface {
+	// WithTx calls fn with a Store whose writes are kept only if fn returns
+	// nil. Calling WithTx on that Store runs fn in the same transaction.
+	WithTx(ctx context.Context, fn func(Store) error) error
 
-func init() {
-	err := godotenv.Load()
-	if err != nil {
-		log.Fatal("Error loading .env file")
+	CreateUser(ctx context.Context, arg sqlc.CreateUserParams) (sqlc.User, error)
+	GetUserByEmail(ctx context.Context, email string) (sqlc.User, error)
+	GetUserByID(ctx context.Context, id uuid.UUID) (sqlc.User, error)
+	UpdateUserEmail(ctx context.Context, arg sqlc.UpdateUserEmailParams) (sqlc.UpdateUserEmailRow, error)
+	UpdateUserPassword(ctx context.Context, arg sqlc.UpdateUserPasswordParams) (sqlc.UpdateUserPasswordRow, error)
+	UpgradeChirpyRed(ctx context.Context, id uuid.UUID) error
+	SetUserRole(ctx context.Context, arg sqlc.SetUserRoleParams) (sqlc.User, error)
+	GetUserByHandle(ctx context.Context, handle string) (sqlc.User, error)
+	UpdateUserProfile(ctx context.Context, arg sqlc.UpdateUserProfileParams) (sqlc.User, error)
+	GetUserStats(ctx context.Context, userID uuid.UUID) (sqlc.GetUserStatsRow, error)
+	ReleaseHandle(ctx context.Context, arg sqlc.ReleaseHandleParams) error
+	GetReleasedHandle(ctx context.Context, handle string) (sqlc.ReleasedHandle, error)
+	DeleteReleasedHandle(ctx context.Context, handle string) error
+	RequestUserDeletion(ctx context.Context, id uuid.UUID) (sqlc.User, error)
+	CancelUserDeletion(ctx context.Context, id uuid.UUID) error
+	PurgeDeletedUsers(ctx context.Context, deletionRequestedAt sql.NullTime) ([]sqlc.User, error)
+
+	CreateChirp(ctx context.Context, arg sqlc.CreateChirpParams) (sqlc.Chirp, error)
+	GetChirpByID(ctx context.Context, id uuid.UUID) (sqlc.Chirp, error)
+	ListChirpsAscending(ctx context.Context, arg sqlc.ListChirpsAscendingParams) ([]sqlc.Chirp, error)
+	ListChirpsDescending(ctx context.Context, arg sqlc.ListChirpsDescendingParams) ([]sqlc.Chirp, error)
+	SearchChirpsAscending(ctx context.Context, arg sqlc.SearchChirpsAscendingParams) ([]sqlc.SearchChirpsAscendingRow, error)
+	SearchChirpsDescending(ctx context.Context, arg sqlc.SearchChirpsDescendingParams) ([]sqlc.SearchChirpsDescendingRow, error)
+
+	CountReplies(ctx context.Context, chirpIds []uuid.UUID) ([]sqlc.CountRepliesRow, error)
+	GetChirpAncestors(ctx context.Context, id uuid.UUID) ([]sqlc.Chirp, error)
+	ListRepliesAscending(ctx context.Context, arg sqlc.ListRepliesAscendingParams) ([]sqlc.Chirp, error)
+	ListRepliesDescending(ctx context.Context, arg sqlc.ListRepliesDescendingParams) ([]sqlc.Chirp, error)
+	GetChirpDescendants(ctx context.Context, arg sqlc.GetChirpDescendantsParams) ([]sqlc.Chirp, error)
+
+	SetChirpHashtags(ctx context.Context, arg sqlc.SetChirpHashtagsParams) error
+	DeleteChirpHashtags(ctx context.Context, chirpID uuid.UUID) error
+	SetChirpMentions(ctx context.Context, arg sqlc.SetChirpMentionsParams) error
+	DeleteChirpMentions(ctx context.Context, chirpID uuid.UUID) error
+	ListHashtagChirpsAscending(ctx context.Context, arg sqlc.ListHashtagChirpsAscendingParams) ([]sqlc.Chirp, error)
+	ListHashtagChirpsDescending(ctx context.Context, arg sqlc.ListHashtagChirpsDescendingParams) ([]sqlc.Chirp, error)
+
+	CreateRechirp(ctx context.Context, arg sqlc.CreateRechirpParams) (sqlc.Chirp, error)
+	DeleteRechirp(ctx context.Context, arg sqlc.DeleteRechirpParams) error
+	CountShares(ctx context.Context, chirpIds []uuid.UUID) ([]sqlc.CountSharesRow, error)
+
+	UpdateChirpBody(ctx context.Context, arg sqlc.UpdateChirpBodyParams) (sqlc.Chirp, error)
+	ListChirpRevisions(ctx context.Context, chirpID uuid.UUID) ([]sqlc.ChirpRevision, error)
+
+	LikeChirp(ctx context.Context, arg sqlc.LikeChirpParams) error
+	UnlikeChirp(ctx context.Context, arg sqlc.UnlikeChirpParams) error
+	CountLikes(ctx context.Context, chirpIds []uuid.UUID) ([]sqlc.CountLikesRow, error)
+	ListLikedChirpIDs(ctx context.Context, arg sqlc.ListLikedChirpIDsParams) ([]uuid.UUID, error)
+	ListUserLikesAscending(ctx context.Context, arg sqlc.ListUserLikesAscendingParams) ([]sqlc.ListUserLikesAscendingRow, error)
+	ListUserLikesDescending(ctx context.Context, arg sqlc.ListUserLikesDescendingParams) ([]sqlc.ListUserLikesDescendingRow, error)
+
+	FollowUser(ctx context.Context, arg sqlc.FollowUserParams) error
+	UnfollowUser(ctx context.Context, arg sqlc.UnfollowUserParams) error
+	ListFollowersAscending(ctx context.Context, arg sqlc.ListFollowersAscendingParams) ([]sqlc.Follow, error)
+	ListFollowersDescending(ctx context.Context, arg sqlc.ListFollowersDescendingParams) ([]sqlc.Follow, error)
+	ListFollowingAscending(ctx context.Context, arg sqlc.ListFollowingAscendingParams) ([]sqlc.Follow, error)
+	ListFollowingDescending(ctx context.Context, arg sqlc.ListFollowingDescendingParams) ([]sqlc.Follow, error)
+	ListTimelineAscending(ctx context.Context, arg sqlc.ListTimelineAscendingParams) ([]sqlc.Chirp, error)
+	ListTimelineDescending(ctx context.Context, arg sqlc.ListTimelineDescendingParams) ([]sqlc.Chirp, error)
+
+	ListFilterTerms(ctx context.Context) ([]sqlc.FilterTerm, error)
+	CreateFilterTerm(ctx context.Context, arg sqlc.CreateFilterTermParams) (sqlc.FilterTerm, error)
+	UpdateFilterTerm(ctx context.Context, arg sqlc.UpdateFilterTermParams) (sqlc.FilterTerm, error)
+	DeleteFilterTerm(ctx context.Context, id uuid.UUID) (int64, error)
+	FlagChirp(ctx context.Context, arg sqlc.FlagChirpParams) error
+	ClearChirpFlags(ctx context.Context, chirpID uuid.UUID) (int64, error)
+	ListFlaggedChirps(ctx context.Context) ([]sqlc.ListFlaggedChirpsRow, error)
+
+	TrashChirp(ctx context.Context, arg sqlc.TrashChirpParams) error
+	ListTrashedChirps(ctx context.Context, arg sqlc.ListTrashedChirpsParams) ([]sqlc.Chirp, error)
+	RestoreChirp(ctx context.Context, arg sqlc.RestoreChirpParams) (sqlc.Chirp, error)
+	PurgeTrashedChirps(ctx context.Context, deletedAt sql.NullTime) (int64, error)
+
+	CreateExport(ctx context.Context, userID uuid.UUID) (sqlc.Export, error)
+	ClaimExport(ctx context.Context, claimedAt sql.NullTime) (sqlc.Export, error)
+	CompleteExport(ctx context.Context, arg sqlc.CompleteExportParams) error
+	GetExport(ctx context.Context, arg sqlc.GetExportParams) (sqlc.Export, error)
+	GetLatestExport(ctx context.Context, userID uuid.UUID) (sqlc.Export, error)
+	PurgeExports(ctx context.Context, createdAt time.Time) (int64, error)
+	ListUserChirps(ctx context.Context, userID uuid.NullUUID) ([]sqlc.Chirp, error)
+	ImportChirp(ctx context.Context, arg sqlc.ImportChirpParams) (sqlc.Chirp, error)
+	ImportRechirp(ctx context.Context, arg sqlc.ImportRechirpParams) (sqlc.Chirp, error)
+
+	StoreRefresh(ctx context.Context, arg sqlc.StoreRefreshParams) error
+	RevokeRefresh(ctx context.Context, arg sqlc.RevokeRefreshParams) error
+	RevokeUserRefreshTokens(ctx context.Context, userID uuid.NullUUID) error
+	GetRefreshToken(ctx context.Context, token string) (sqlc.RefreshToken, error)
+	RotateRefresh(ctx context.Context, token string) (int64, error)
+	RevokeRefreshFamily(ctx context.Context, familyID uuid.UUID) error
+	RevokeAccessToken(ctx context.Context, arg sqlc.RevokeAccessTokenParams) error
+	IsAccessTokenRevoked(ctx context.Context, jti uuid.UUID) (bool, error)
+	PurgeRevokedAccessTokens(ctx context.Context, expiresAt time.Time) (int64, error)
+
+	CreateSession(ctx context.Context, arg sqlc.CreateSessionParams) (sqlc.Session, error)
+	GetSession(ctx context.Context, id uuid.UUID) (sqlc.Session, error)
+	ListActiveSessions(ctx context.Context, userID uuid.UUID) ([]sqlc.Session, error)
+	ListUserSessions(ctx context.Context, userID uuid.UUID) ([]sqlc.Session, error)
+	TouchSession(ctx context.Context, arg sqlc.TouchSessionParams) error
+	RenewSession(ctx context.Context, arg sqlc.RenewSessionParams) error
+	RevokeSession(ctx context.Context, arg sqlc.RevokeSessionParams) (int64, error)
+	RevokeUserSessions(ctx context.Context, userID uuid.UUID) error
+
+	CreateAPIKey(ctx context.Context, arg sqlc.CreateAPIKeyParams) (sqlc.APIKey, error)
+	GetAPIKeyByHash(ctx context.Context, keyHash string) (sqlc.APIKey, error)
+	ListActiveAPIKeys(ctx context.Context, userID uuid.UUID) ([]sqlc.APIKey, error)
+	TouchAPIKey(ctx context.Context, id uuid.UUID) error
+	RevokeAPIKey(ctx context.Context, arg sqlc.RevokeAPIKeyParams) (int64, error)
+
+	DeleteAllUsers(ctx context.Context) error
+}
+
+// IsUniqueViolation reports whether err is a Store refusing a write that
+// would break a unique constraint.
+func IsUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23505"
 	}
+	return errors.Is(err, errUniqueViolation)
+}
 
-	dbURL := os.Getenv("DB_URL")
+// Open connects to the Postgres database at dbURL. The caller is expected
+// to have registered the "postgres" driver.
+func Open(dbURL string) (*sql.DB, error) {
 	db, err := sql.Open("postgres", dbURL)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-	queries = sqlc.New(db)
+	return db, nil
+}
+
+// NewPostgres returns a Store backed by the sqlc queries on db.
+func NewPostgres(db *sql.DB) Store {
+	return &postgres{Queries: sqlc.New(db), db: db}
+}
+
+// postgres adds transactions to the sqlc queries. Inside one db is nil and
+// Queries runs on the transaction.
+type postgres struct {
+	*sqlc.Queries
+	db *sql.DB
 }
 
-func Queries() *sqlc.Queries {
-	return queries
+var _ Store = (*postgres)(nil)
+
+func (p *postgres) WithTx(ctx context.Context, fn func(Store) error) error {
+	if p.db == nil {
+		return fn(p)
+	}
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	err = fn(&postgres{Queries: p.Queries.WithTx(tx)})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
 }
@@ -0,0 +1,355 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/pcauce/chirpy/internal/sqlc"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+var (
+	errUniqueViolation     = errors.New("duplicate key value violates unique constraint")
+	errForeignKeyViolation = errors.New("insert or update violates foreign key constraint")
+	errCheckViolation      = errors.New("new row violates check constraint")
+)
+
+// Memory is an in-memory Store. It enforces the same constraints as the
+// Postgres schema so handlers behave identically against either backend.
+type Memory struct {
+	// mu is held by every method, and by WithTx for the whole transaction.
+	// The Store WithTx hands to fn shares the tables but has a no-op mu.
+	mu   sync.Locker
+	inTx bool
+	*tables
+}
+
+// tables holds a Memory's rows, so a transaction can work on them directly
+// and put back a copy if it fails.
+type tables struct {
+	users         map[uuid.UUID]sqlc.User
+	chirps        []sqlc.Chirp
+	revisions     []sqlc.ChirpRevision
+	likes         []sqlc.Like
+	follows       []sqlc.Follow
+	filterTerms   []sqlc.FilterTerm
+	chirpFlags    []sqlc.ChirpFlag
+	hashtags      []sqlc.ChirpHashtag
+	mentions      []sqlc.ChirpMention
+	exports       []sqlc.Export
+	sessions      map[uuid.UUID]sqlc.Session
+	refreshTokens map[string]sqlc.RefreshToken // keyed by token_hash
+	revokedAccess map[uuid.UUID]sqlc.RevokedAccessToken
+	apiKeys       map[uuid.UUID]sqlc.APIKey
+	// releasedHandles is keyed by the lower case handle, like its table.
+	releasedHandles map[string]sqlc.ReleasedHandle
+}
+
+var _ Store = (*Memory)(nil)
+
+func NewMemory() *Memory {
+	m := &Memory{mu: &sync.Mutex{}, tables: &tables{
+		users:           map[uuid.UUID]sqlc.User{},
+		sessions:        map[uuid.UUID]sqlc.Session{},
+		refreshTokens:   map[string]sqlc.RefreshToken{},
+		revokedAccess:   map[uuid.UUID]sqlc.RevokedAccessToken{},
+		apiKeys:         map[uuid.UUID]sqlc.APIKey{},
+		releasedHandles: map[string]sqlc.ReleasedHandle{},
+	}}
+	// The same terms migration 014 seeds filter_terms with.
+	for _, term := range []string{"kerfuffle", "sharbert", "fornax"} {
+		m.filterTerms = append(m.filterTerms, sqlc.FilterTerm{
+			ID:        uuid.New(),
+			Term:      term,
+			Action:    "mask",
+			CreatedAt: now(),
+			UpdatedAt: now(),
+		})
+	}
+	return m
+}
+
+func (m *Memory) WithTx(ctx context.Context, fn func(Store) error) error {
+	if m.inTx {
+		return fn(m)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	saved := m.tables.clone()
+	err := fn(&Memory{mu: noLock{}, inTx: true, tables: m.tables})
+	if err != nil {
+		*m.tables = saved
+	}
+	return err
+}
+
+// clone copies every table, so changing a row in one doesn't change it in
+// the other.
+func (t *tables) clone() tables {
+	return tables{
+		users:           maps.Clone(t.users),
+		chirps:          slices.Clone(t.chirps),
+		revisions:       slices.Clone(t.revisions),
+		likes:           slices.Clone(t.likes),
+		follows:         slices.Clone(t.follows),
+		filterTerms:     slices.Clone(t.filterTerms),
+		chirpFlags:      slices.Clone(t.chirpFlags),
+		hashtags:        slices.Clone(t.hashtags),
+		mentions:        slices.Clone(t.mentions),
+		exports:         slices.Clone(t.exports),
+		sessions:        maps.Clone(t.sessions),
+		refreshTokens:   maps.Clone(t.refreshTokens),
+		revokedAccess:   maps.Clone(t.revokedAccess),
+		apiKeys:         maps.Clone(t.apiKeys),
+		releasedHandles: maps.Clone(t.releasedHandles),
+	}
+}
+
+// noLock is the mu of the Store inside a transaction, whose WithTx already
+// holds the real one.
+type noLock struct{}
+
+func (noLock) Lock()   {}
+func (noLock) Unlock() {}
+
+// now matches the microsecond precision of a Postgres TIMESTAMP column.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+func (m *Memory) userExists(id uuid.NullUUID) bool {
+	if !id.Valid {
+		return true
+	}
+	_, ok := m.users[id.UUID]
+	return ok
+}
+
+func (m *Memory) CreateUser(_ context.Context, arg sqlc.CreateUserParams) (sqlc.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, user := range m.users {
+		if user.Email == arg.Email {
+			return sqlc.User{}, errUniqueViolation
+		}
+	}
+
+	createdAt := now()
+	user := sqlc.User{
+		ID:             uuid.New(),
+		CreatedAt:      createdAt,
+		UpdatedAt:      createdAt,
+		Email:          arg.Email,
+		HashedPassword: arg.HashedPassword,
+		Role:           "user",
+	}
+	m.users[user.ID] = user
+	return user, nil
+}
+
+func (m *Memory) GetUserByEmail(_ context.Context, email string) (sqlc.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, user := range m.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return sqlc.User{}, sql.ErrNoRows
+}
+
+func (m *Memory) GetUserByID(_ context.Context, id uuid.UUID) (sqlc.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if user, ok := m.users[id]; ok {
+		return user, nil
+	}
+	return sqlc.User{}, sql.ErrNoRows
+}
+
+func (m *Memory) UpdateUserEmail(_ context.Context, arg sqlc.UpdateUserEmailParams) (sqlc.UpdateUserEmailRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[arg.ID]
+	if !ok {
+		return sqlc.UpdateUserEmailRow{}, sql.ErrNoRows
+	}
+	for _, other := range m.users {
+		if other.ID != arg.ID && other.Email == arg.Email {
+			return sqlc.UpdateUserEmailRow{}, errUniqueViolation
+		}
+	}
+
+	user.Email = arg.Email
+	m.users[user.ID] = user
+	return sqlc.UpdateUserEmailRow{
+		ID:          user.ID,
+		CreatedAt:   user.CreatedAt,
+		UpdatedAt:   user.UpdatedAt,
+		Email:       user.Email,
+		IsChirpyRed: user.IsChirpyRed,
+	}, nil
+}
+
+func (m *Memory) UpdateUserPassword(_ context.Context, arg sqlc.UpdateUserPasswordParams) (sqlc.UpdateUserPasswordRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[arg.ID]
+	if !ok {
+		return sqlc.UpdateUserPasswordRow{}, sql.ErrNoRows
+	}
+
+	user.HashedPassword = arg.HashedPassword
+	m.users[user.ID] = user
+	return sqlc.UpdateUserPasswordRow{
+		ID:          user.ID,
+		CreatedAt:   user.CreatedAt,
+		UpdatedAt:   user.UpdatedAt,
+		Email:       user.Email,
+		IsChirpyRed: user.IsChirpyRed,
+	}, nil
+}
+
+func (m *Memory) UpgradeChirpyRed(_ context.Context, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if user, ok := m.users[id]; ok {
+		user.IsChirpyRed = true
+		m.users[id] = user
+	}
+	return nil
+}
+
+// userRoles is the CHECK constraint on users.role.
+var userRoles = []string{"user", "admin"}
+
+func (m *Memory) SetUserRole(_ context.Context, arg sqlc.SetUserRoleParams) (sqlc.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[arg.ID]
+	if !ok {
+		return sqlc.User{}, sql.ErrNoRows
+	}
+	if !slices.Contains(userRoles, arg.Role) {
+		return sqlc.User{}, errCheckViolation
+	}
+	user.Role = arg.Role
+	user.UpdatedAt = now()
+	m.users[user.ID] = user
+	return user, nil
+}
+
+func (m *Memory) RequestUserDeletion(_ context.Context, id uuid.UUID) (sqlc.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[id]
+	if !ok {
+		return sqlc.User{}, sql.ErrNoRows
+	}
+	if !user.DeletionRequestedAt.Valid {
+		user.DeletionRequestedAt = sql.NullTime{Time: now(), Valid: true}
+		m.users[id] = user
+	}
+	return user, nil
+}
+
+func (m *Memory) CancelUserDeletion(_ context.Context, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if user, ok := m.users[id]; ok {
+		user.DeletionRequestedAt = sql.NullTime{}
+		m.users[id] = user
+	}
+	return nil
+}
+
+func (m *Memory) PurgeDeletedUsers(_ context.Context, deletionRequestedAt sql.NullTime) ([]sqlc.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !deletionRequestedAt.Valid {
+		return nil, nil
+	}
+	return m.deleteUsersWhere(func(user sqlc.User) bool {
+		return user.DeletionRequestedAt.Valid && !user.DeletionRequestedAt.Time.After(deletionRequestedAt.Time)
+	}), nil
+}
+
+func (m *Memory) DeleteAllUsers(_ context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.deleteUsersWhere(func(sqlc.User) bool {
+		return true
+	})
+	return nil
+}
+
+// deleteUsersWhere removes the users del matches, cascading to their chirps,
+// likes, follows, exports, sessions and refresh and revoked access tokens
+// the way the foreign keys on users do, and returns them.
+func (m *Memory) deleteUsersWhere(del func(sqlc.User) bool) []sqlc.User {
+	var deleted []sqlc.User
+	for id, user := range m.users {
+		if del(user) {
+			deleted = append(deleted, user)
+			delete(m.users, id)
+		}
+	}
+	gone := func(id uuid.NullUUID) bool {
+		return id.Valid && !m.userExists(id)
+	}
+
+	m.deleteChirpsWhere(func(chirp sqlc.Chirp) bool {
+		return gone(chirp.UserID)
+	})
+	m.likes = slices.DeleteFunc(m.likes, func(like sqlc.Like) bool {
+		return gone(uuid.NullUUID{UUID: like.UserID, Valid: true})
+	})
+	m.follows = slices.DeleteFunc(m.follows, func(follow sqlc.Follow) bool {
+		return gone(uuid.NullUUID{UUID: follow.FollowerID, Valid: true}) || gone(uuid.NullUUID{UUID: follow.FolloweeID, Valid: true})
+	})
+	m.exports = slices.DeleteFunc(m.exports, func(export sqlc.Export) bool {
+		return gone(uuid.NullUUID{UUID: export.UserID, Valid: true})
+	})
+	for id, session := range m.sessions {
+		if gone(uuid.NullUUID{UUID: session.UserID, Valid: true}) {
+			delete(m.sessions, id)
+		}
+	}
+	for token, refresh := range m.refreshTokens {
+		if _, ok := m.sessions[refresh.FamilyID]; gone(refresh.UserID) || !ok {
+			delete(m.refreshTokens, token)
+		}
+	}
+	for jti, revoked := range m.revokedAccess {
+		if gone(uuid.NullUUID{UUID: revoked.UserID, Valid: true}) {
+			delete(m.revokedAccess, jti)
+		}
+	}
+	for id, key := range m.apiKeys {
+		if gone(uuid.NullUUID{UUID: key.UserID, Valid: true}) {
+			delete(m.apiKeys, id)
+		}
+	}
+	for handle, released := range m.releasedHandles {
+		if gone(released.UserID) {
+			released.UserID = uuid.NullUUID{}
+			m.releasedHandles[handle] = released
+		}
+	}
+	return deleted
+}
@@ -0,0 +1,88 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/pcauce/chirpy/internal/sqlc"
+	"slices"
+)
+
+func (m *Memory) CreateAPIKey(_ context.Context, arg sqlc.CreateAPIKeyParams) (sqlc.APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.userExists(uuid.NullUUID{UUID: arg.UserID, Valid: true}) {
+		return sqlc.APIKey{}, errForeignKeyViolation
+	}
+	for _, key := range m.apiKeys {
+		if key.KeyHash == arg.KeyHash {
+			return sqlc.APIKey{}, errUniqueViolation
+		}
+	}
+	key := sqlc.APIKey{
+		ID:        uuid.New(),
+		UserID:    arg.UserID,
+		Name:      arg.Name,
+		Prefix:    arg.Prefix,
+		KeyHash:   arg.KeyHash,
+		Scopes:    slices.Clone(arg.Scopes),
+		CreatedAt: now(),
+	}
+	m.apiKeys[key.ID] = key
+	return key, nil
+}
+
+func (m *Memory) GetAPIKeyByHash(_ context.Context, keyHash string) (sqlc.APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, key := range m.apiKeys {
+		if key.KeyHash == keyHash {
+			key.Scopes = slices.Clone(key.Scopes)
+			return key, nil
+		}
+	}
+	return sqlc.APIKey{}, sql.ErrNoRows
+}
+
+func (m *Memory) ListActiveAPIKeys(_ context.Context, userID uuid.UUID) ([]sqlc.APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var keys []sqlc.APIKey
+	for _, key := range m.apiKeys {
+		if key.UserID == userID && !key.RevokedAt.Valid {
+			key.Scopes = slices.Clone(key.Scopes)
+			keys = append(keys, key)
+		}
+	}
+	slices.SortFunc(keys, func(a, b sqlc.APIKey) int {
+		return keyset{createdAt: a.CreatedAt, id: a.ID}.compare(keyset{createdAt: b.CreatedAt, id: b.ID})
+	})
+	return keys, nil
+}
+
+func (m *Memory) TouchAPIKey(_ context.Context, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if key, ok := m.apiKeys[id]; ok {
+		key.LastUsedAt = sql.NullTime{Time: now(), Valid: true}
+		m.apiKeys[id] = key
+	}
+	return nil
+}
+
+func (m *Memory) RevokeAPIKey(_ context.Context, arg sqlc.RevokeAPIKeyParams) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key, ok := m.apiKeys[arg.ID]
+	if !ok || key.UserID != arg.UserID || key.RevokedAt.Valid {
+		return 0, nil
+	}
+	key.RevokedAt = sql.NullTime{Time: now(), Valid: true}
+	m.apiKeys[arg.ID] = key
+	return 1, nil
+}
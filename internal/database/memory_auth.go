@@ -0,0 +1,143 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/pcauce/chirpy/internal/sqlc"
+	"time"
+)
+
+func (m *Memory) StoreRefresh(_ context.Context, arg sqlc.StoreRefreshParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.refreshTokens[arg.TokenHash]; exists {
+		return errUniqueViolation
+	}
+	if _, ok := m.sessions[arg.FamilyID]; !ok || !m.userExists(arg.UserID) {
+		return errForeignKeyViolation
+	}
+
+	createdAt := now()
+	m.refreshTokens[arg.TokenHash] = sqlc.RefreshToken{
+		TokenHash: arg.TokenHash,
+		CreatedAt: createdAt,
+		UpdatedAt: createdAt,
+		UserID:    arg.UserID,
+		ExpiresAt: arg.ExpiresAt,
+		FamilyID:  arg.FamilyID,
+	}
+	return nil
+}
+
+func (m *Memory) RevokeRefresh(_ context.Context, arg sqlc.RevokeRefreshParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if token, ok := m.refreshTokens[arg.TokenHash]; ok {
+		token.UpdatedAt = arg.UpdatedAt
+		token.RevokedAt = sql.NullTime{Time: arg.UpdatedAt, Valid: true}
+		m.refreshTokens[arg.TokenHash] = token
+	}
+	return nil
+}
+
+func (m *Memory) RevokeUserRefreshTokens(_ context.Context, userID uuid.NullUUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	revokedAt := now()
+	for token, refresh := range m.refreshTokens {
+		if userID.Valid && refresh.UserID == userID && !refresh.RevokedAt.Valid {
+			refresh.UpdatedAt = revokedAt
+			refresh.RevokedAt = sql.NullTime{Time: revokedAt, Valid: true}
+			m.refreshTokens[token] = refresh
+		}
+	}
+	return nil
+}
+
+func (m *Memory) GetRefreshToken(_ context.Context, tokenHash string) (sqlc.RefreshToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	refresh, ok := m.refreshTokens[tokenHash]
+	if !ok {
+		return sqlc.RefreshToken{}, sql.ErrNoRows
+	}
+	return refresh, nil
+}
+
+func (m *Memory) RotateRefresh(_ context.Context, tokenHash string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	refresh, ok := m.refreshTokens[tokenHash]
+	if !ok || refresh.RotatedAt.Valid || refresh.RevokedAt.Valid {
+		return 0, nil
+	}
+	rotatedAt := now()
+	refresh.UpdatedAt = rotatedAt
+	refresh.RotatedAt = sql.NullTime{Time: rotatedAt, Valid: true}
+	m.refreshTokens[tokenHash] = refresh
+	return 1, nil
+}
+
+func (m *Memory) RevokeRefreshFamily(_ context.Context, familyID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	revokedAt := now()
+	for token, refresh := range m.refreshTokens {
+		if refresh.FamilyID == familyID && !refresh.RevokedAt.Valid {
+			refresh.UpdatedAt = revokedAt
+			refresh.RevokedAt = sql.NullTime{Time: revokedAt, Valid: true}
+			m.refreshTokens[token] = refresh
+		}
+	}
+	return nil
+}
+
+// RevokeAccessToken mirrors its ON CONFLICT DO NOTHING: revoking a token
+// twice keeps the first revocation.
+func (m *Memory) RevokeAccessToken(_ context.Context, arg sqlc.RevokeAccessTokenParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.userExists(uuid.NullUUID{UUID: arg.UserID, Valid: true}) {
+		return errForeignKeyViolation
+	}
+	if _, exists := m.revokedAccess[arg.JTI]; exists {
+		return nil
+	}
+	m.revokedAccess[arg.JTI] = sqlc.RevokedAccessToken{
+		JTI:       arg.JTI,
+		UserID:    arg.UserID,
+		ExpiresAt: arg.ExpiresAt,
+		RevokedAt: now(),
+	}
+	return nil
+}
+
+func (m *Memory) IsAccessTokenRevoked(_ context.Context, jti uuid.UUID) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, revoked := m.revokedAccess[jti]
+	return revoked, nil
+}
+
+func (m *Memory) PurgeRevokedAccessTokens(_ context.Context, expiresAt time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	purged := int64(0)
+	for jti, revoked := range m.revokedAccess {
+		if !revoked.ExpiresAt.After(expiresAt) {
+			delete(m.revokedAccess, jti)
+			purged++
+		}
+	}
+	return purged, nil
+}
@@ -0,0 +1,334 @@
+package database
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/pcauce/chirpy/internal/search"
+	"github.com/pcauce/chirpy/internal/sqlc"
+	"slices"
+	"time"
+)
+
+func (m *Memory) CreateChirp(_ context.Context, arg sqlc.CreateChirpParams) (sqlc.Chirp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.userExists(arg.UserID) {
+		return sqlc.Chirp{}, errForeignKeyViolation
+	}
+	for _, ref := range []uuid.NullUUID{arg.InReplyTo, arg.RechirpOf, arg.QuoteOf} {
+		if _, ok := m.chirpByID(ref.UUID); ref.Valid && !ok {
+			return sqlc.Chirp{}, errForeignKeyViolation
+		}
+	}
+	if !arg.RechirpOf.Valid && m.bodyTaken(uuid.Nil, arg.UserID, arg.Body) {
+		return sqlc.Chirp{}, errUniqueViolation
+	}
+	for _, chirp := range m.chirps {
+		if arg.RechirpOf.Valid && chirp.RechirpOf == arg.RechirpOf && sameAuthor(chirp.UserID, arg.UserID) {
+			return sqlc.Chirp{}, errUniqueViolation
+		}
+	}
+
+	createdAt := now()
+	chirp := sqlc.Chirp{
+		ID:        uuid.New(),
+		CreatedAt: createdAt,
+		UpdatedAt: createdAt,
+		Body:      arg.Body,
+		UserID:    arg.UserID,
+		InReplyTo: arg.InReplyTo,
+		RechirpOf: arg.RechirpOf,
+		QuoteOf:   arg.QuoteOf,
+	}
+	m.chirps = append(m.chirps, chirp)
+	return chirp, nil
+}
+
+// bodyTaken mirrors the chirps_user_id_body_key index: it reports whether
+// a chirp other than id, live and not a rechirp, already has this author
+// and body.
+func (m *Memory) bodyTaken(id uuid.UUID, userID uuid.NullUUID, body string) bool {
+	return slices.ContainsFunc(m.chirps, func(chirp sqlc.Chirp) bool {
+		return chirp.ID != id && !chirp.RechirpOf.Valid && !chirp.DeletedAt.Valid && sameAuthor(chirp.UserID, userID) && chirp.Body == body
+	})
+}
+
+// sameAuthor reports whether two chirps count as by the same user for a
+// unique index on user_id, where, as in Postgres, NULLs never match.
+func sameAuthor(a, b uuid.NullUUID) bool {
+	return a.Valid && b.Valid && a.UUID == b.UUID
+}
+
+func (m *Memory) GetChirpByID(_ context.Context, id uuid.UUID) (sqlc.Chirp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, chirp := range m.chirps {
+		if chirp.ID == id && !chirp.DeletedAt.Valid {
+			return chirp, nil
+		}
+	}
+	return sqlc.Chirp{}, sql.ErrNoRows
+}
+
+func (m *Memory) ListChirpsAscending(_ context.Context, arg sqlc.ListChirpsAscendingParams) ([]sqlc.Chirp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ranked, err := m.listChirps(chirpFilter{
+		AuthorID:      arg.AuthorID,
+		Query:         arg.Query,
+		CreatedAfter:  arg.CreatedAfter,
+		CreatedBefore: arg.CreatedBefore,
+	}, chronologicalCursor(arg.CursorCreatedAt, arg.CursorID), arg.Limit, 1)
+	return unranked(ranked), err
+}
+
+func (m *Memory) ListChirpsDescending(_ context.Context, arg sqlc.ListChirpsDescendingParams) ([]sqlc.Chirp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ranked, err := m.listChirps(chirpFilter{
+		AuthorID:      arg.AuthorID,
+		Query:         arg.Query,
+		CreatedAfter:  arg.CreatedAfter,
+		CreatedBefore: arg.CreatedBefore,
+	}, chronologicalCursor(arg.CursorCreatedAt, arg.CursorID), arg.Limit, -1)
+	return unranked(ranked), err
+}
+
+func (m *Memory) SearchChirpsAscending(_ context.Context, arg sqlc.SearchChirpsAscendingParams) ([]sqlc.SearchChirpsAscendingRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ranked, err := m.listChirps(chirpFilter{
+		AuthorID:      arg.AuthorID,
+		Query:         sql.NullString{String: arg.Query, Valid: true},
+		CreatedAfter:  arg.CreatedAfter,
+		CreatedBefore: arg.CreatedBefore,
+		Ranked:        true,
+	}, rankedCursor(arg.CursorRank, arg.CursorCreatedAt, arg.CursorID), arg.Limit, 1)
+
+	var rows []sqlc.SearchChirpsAscendingRow
+	for _, chirp := range ranked {
+		rows = append(rows, sqlc.SearchChirpsAscendingRow{
+			ID:        chirp.ID,
+			CreatedAt: chirp.CreatedAt,
+			UpdatedAt: chirp.UpdatedAt,
+			Body:      chirp.Body,
+			UserID:    chirp.UserID,
+			InReplyTo: chirp.InReplyTo,
+			RechirpOf: chirp.RechirpOf,
+			QuoteOf:   chirp.QuoteOf,
+			Rank:      chirp.rank,
+		})
+	}
+	return rows, err
+}
+
+func (m *Memory) SearchChirpsDescending(_ context.Context, arg sqlc.SearchChirpsDescendingParams) ([]sqlc.SearchChirpsDescendingRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ranked, err := m.listChirps(chirpFilter{
+		AuthorID:      arg.AuthorID,
+		Query:         sql.NullString{String: arg.Query, Valid: true},
+		CreatedAfter:  arg.CreatedAfter,
+		CreatedBefore: arg.CreatedBefore,
+		Ranked:        true,
+	}, rankedCursor(arg.CursorRank, arg.CursorCreatedAt, arg.CursorID), arg.Limit, -1)
+
+	var rows []sqlc.SearchChirpsDescendingRow
+	for _, chirp := range ranked {
+		rows = append(rows, sqlc.SearchChirpsDescendingRow{
+			ID:        chirp.ID,
+			CreatedAt: chirp.CreatedAt,
+			UpdatedAt: chirp.UpdatedAt,
+			Body:      chirp.Body,
+			UserID:    chirp.UserID,
+			InReplyTo: chirp.InReplyTo,
+			RechirpOf: chirp.RechirpOf,
+			QuoteOf:   chirp.QuoteOf,
+			Rank:      chirp.rank,
+		})
+	}
+	return rows, err
+}
+
+// chirpFilter is the WHERE clause shared by the chirp list, search, reply,
+// timeline and hashtag queries. A nil AuthorIn matches every author. Ranked
+// orders by search rank before (created_at, id). KeepTrashedParents lets
+// through trashed chirps that still have replies.
+type chirpFilter struct {
+	AuthorID           uuid.NullUUID
+	AuthorIn           []uuid.UUID
+	InReplyTo          uuid.NullUUID
+	Hashtag            sql.NullString
+	Query              sql.NullString
+	CreatedAfter       sql.NullTime
+	CreatedBefore      sql.NullTime
+	Ranked             bool
+	KeepTrashedParents bool
+}
+
+type rankedChirp struct {
+	sqlc.Chirp
+	rank float32
+}
+
+// keyset is a position in a list ordered by (rank, created_at, id). Rank is
+// always zero for chronological lists.
+type keyset struct {
+	rank      float32
+	createdAt time.Time
+	id        uuid.UUID
+}
+
+func (k keyset) compare(other keyset) int {
+	if c := cmp.Compare(k.rank, other.rank); c != 0 {
+		return c
+	}
+	if c := k.createdAt.Compare(other.createdAt); c != 0 {
+		return c
+	}
+	return compareUUID(k.id, other.id)
+}
+
+// compareUUID orders UUIDs the way Postgres does, byte by byte.
+func compareUUID(a, b uuid.UUID) int {
+	return bytes.Compare(a[:], b[:])
+}
+
+func chronologicalCursor(createdAt sql.NullTime, id uuid.NullUUID) *keyset {
+	if !createdAt.Valid {
+		return nil
+	}
+	return &keyset{createdAt: createdAt.Time, id: id.UUID}
+}
+
+func rankedCursor(rank sql.NullFloat64, createdAt sql.NullTime, id uuid.NullUUID) *keyset {
+	if !rank.Valid {
+		return nil
+	}
+	return &keyset{rank: float32(rank.Float64), createdAt: createdAt.Time, id: id.UUID}
+}
+
+// listChirps returns up to limit chirps matching filter, ascending when
+// direction is 1 and descending when it is -1, starting strictly past from
+// if it is not nil.
+func (m *Memory) listChirps(filter chirpFilter, from *keyset, limit int32, direction int) ([]rankedChirp, error) {
+	var chirps []rankedChirp
+	for _, chirp := range m.chirps {
+		if chirp.DeletedAt.Valid && !(filter.KeepTrashedParents && m.hasReplies(chirp.ID)) {
+			continue
+		}
+		if filter.AuthorIn != nil && !slices.Contains(filter.AuthorIn, chirp.UserID.UUID) {
+			continue
+		}
+		if filter.InReplyTo.Valid && chirp.InReplyTo != filter.InReplyTo {
+			continue
+		}
+		if filter.AuthorID.Valid && chirp.UserID != filter.AuthorID {
+			continue
+		}
+		if filter.Hashtag.Valid && !slices.Contains(m.hashtags, sqlc.ChirpHashtag{ChirpID: chirp.ID, Tag: filter.Hashtag.String}) {
+			continue
+		}
+		if filter.CreatedAfter.Valid && chirp.CreatedAt.Before(filter.CreatedAfter.Time) {
+			continue
+		}
+		if filter.CreatedBefore.Valid && !chirp.CreatedAt.Before(filter.CreatedBefore.Time) {
+			continue
+		}
+
+		candidate := rankedChirp{Chirp: chirp}
+		if filter.Query.Valid {
+			rank, ok, err := search.Match(filter.Query.String, chirp.Body)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+			if filter.Ranked {
+				candidate.rank = rank
+			}
+		}
+
+		if from != nil && candidate.key().compare(*from)*direction <= 0 {
+			continue
+		}
+		chirps = append(chirps, candidate)
+	}
+
+	slices.SortFunc(chirps, func(a, b rankedChirp) int {
+		return a.key().compare(b.key()) * direction
+	})
+	if len(chirps) > int(limit) {
+		chirps = chirps[:max(limit, 0)]
+	}
+	return chirps, nil
+}
+
+func (c rankedChirp) key() keyset {
+	return keyset{rank: c.rank, createdAt: c.CreatedAt, id: c.ID}
+}
+
+func unranked(ranked []rankedChirp) []sqlc.Chirp {
+	var chirps []sqlc.Chirp
+	for _, chirp := range ranked {
+		chirps = append(chirps, chirp.Chirp)
+	}
+	return chirps
+}
+
+// deleteChirpsWhere removes every chirp matching del along with the rows
+// that reference it, as ON DELETE CASCADE would. That includes rechirps of a
+// removed chirp. Replies to and quotes of a removed chirp are kept but lose
+// their reference, as ON DELETE SET NULL would.
+func (m *Memory) deleteChirpsWhere(del func(sqlc.Chirp) bool) {
+	deleted := map[uuid.UUID]bool{}
+	for _, chirp := range m.chirps {
+		if del(chirp) {
+			deleted[chirp.ID] = true
+		}
+	}
+	for cascaded := true; cascaded; {
+		cascaded = false
+		for _, chirp := range m.chirps {
+			if chirp.RechirpOf.Valid && deleted[chirp.RechirpOf.UUID] && !deleted[chirp.ID] {
+				deleted[chirp.ID], cascaded = true, true
+			}
+		}
+	}
+	m.chirps = slices.DeleteFunc(m.chirps, func(chirp sqlc.Chirp) bool {
+		return deleted[chirp.ID]
+	})
+	m.revisions = slices.DeleteFunc(m.revisions, func(revision sqlc.ChirpRevision) bool {
+		return deleted[revision.ChirpID]
+	})
+	m.likes = slices.DeleteFunc(m.likes, func(like sqlc.Like) bool {
+		return deleted[like.ChirpID]
+	})
+	m.chirpFlags = slices.DeleteFunc(m.chirpFlags, func(flag sqlc.ChirpFlag) bool {
+		return deleted[flag.ChirpID]
+	})
+	m.hashtags = slices.DeleteFunc(m.hashtags, func(hashtag sqlc.ChirpHashtag) bool {
+		return deleted[hashtag.ChirpID]
+	})
+	m.mentions = slices.DeleteFunc(m.mentions, func(mention sqlc.ChirpMention) bool {
+		return deleted[mention.ChirpID]
+	})
+	for i, chirp := range m.chirps {
+		if chirp.InReplyTo.Valid && deleted[chirp.InReplyTo.UUID] {
+			m.chirps[i].InReplyTo = uuid.NullUUID{}
+		}
+		if chirp.QuoteOf.Valid && deleted[chirp.QuoteOf.UUID] {
+			m.chirps[i].QuoteOf = uuid.NullUUID{}
+		}
+	}
+}
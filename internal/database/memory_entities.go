@@ -0,0 +1,81 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/pcauce/chirpy/internal/sqlc"
+	"slices"
+)
+
+func (m *Memory) SetChirpHashtags(_ context.Context, arg sqlc.SetChirpHashtagsParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.chirpByID(arg.ChirpID); !ok && len(arg.Tags) > 0 {
+		return errForeignKeyViolation
+	}
+	for _, tag := range arg.Tags {
+		hashtag := sqlc.ChirpHashtag{ChirpID: arg.ChirpID, Tag: tag}
+		if !slices.Contains(m.hashtags, hashtag) {
+			m.hashtags = append(m.hashtags, hashtag)
+		}
+	}
+	return nil
+}
+
+func (m *Memory) DeleteChirpHashtags(_ context.Context, chirpID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.hashtags = slices.DeleteFunc(m.hashtags, func(hashtag sqlc.ChirpHashtag) bool {
+		return hashtag.ChirpID == chirpID
+	})
+	return nil
+}
+
+func (m *Memory) SetChirpMentions(_ context.Context, arg sqlc.SetChirpMentionsParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.chirpByID(arg.ChirpID); !ok && len(arg.Names) > 0 {
+		return errForeignKeyViolation
+	}
+	for _, name := range arg.Names {
+		mention := sqlc.ChirpMention{ChirpID: arg.ChirpID, Name: name}
+		if !slices.Contains(m.mentions, mention) {
+			m.mentions = append(m.mentions, mention)
+		}
+	}
+	return nil
+}
+
+func (m *Memory) DeleteChirpMentions(_ context.Context, chirpID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.mentions = slices.DeleteFunc(m.mentions, func(mention sqlc.ChirpMention) bool {
+		return mention.ChirpID == chirpID
+	})
+	return nil
+}
+
+func (m *Memory) ListHashtagChirpsAscending(_ context.Context, arg sqlc.ListHashtagChirpsAscendingParams) ([]sqlc.Chirp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ranked, err := m.listChirps(chirpFilter{
+		Hashtag: sql.NullString{String: arg.Tag, Valid: true},
+	}, chronologicalCursor(arg.CursorCreatedAt, arg.CursorID), arg.Limit, 1)
+	return unranked(ranked), err
+}
+
+func (m *Memory) ListHashtagChirpsDescending(_ context.Context, arg sqlc.ListHashtagChirpsDescendingParams) ([]sqlc.Chirp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ranked, err := m.listChirps(chirpFilter{
+		Hashtag: sql.NullString{String: arg.Tag, Valid: true},
+	}, chronologicalCursor(arg.CursorCreatedAt, arg.CursorID), arg.Limit, -1)
+	return unranked(ranked), err
+}
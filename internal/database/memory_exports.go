@@ -0,0 +1,189 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/pcauce/chirpy/internal/sqlc"
+	"slices"
+	"time"
+)
+
+// exportStatuses is the CHECK constraint on exports.status.
+var exportStatuses = []string{"pending", "ready", "failed"}
+
+func (m *Memory) CreateExport(_ context.Context, userID uuid.UUID) (sqlc.Export, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.userExists(uuid.NullUUID{UUID: userID, Valid: true}) {
+		return sqlc.Export{}, errForeignKeyViolation
+	}
+	export := sqlc.Export{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Status:    "pending",
+		CreatedAt: now(),
+	}
+	m.exports = append(m.exports, export)
+	return export, nil
+}
+
+// ClaimExport marks the oldest pending export that isn't claimed, or was
+// claimed no later than claimedAt, as claimed now and returns it.
+func (m *Memory) ClaimExport(_ context.Context, claimedAt sql.NullTime) (sqlc.Export, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	oldest := -1
+	for i, export := range m.exports {
+		claimable := !export.ClaimedAt.Valid || (claimedAt.Valid && !export.ClaimedAt.Time.After(claimedAt.Time))
+		if export.Status != "pending" || !claimable {
+			continue
+		}
+		key := keyset{createdAt: export.CreatedAt, id: export.ID}
+		if oldest < 0 || key.compare(keyset{createdAt: m.exports[oldest].CreatedAt, id: m.exports[oldest].ID}) < 0 {
+			oldest = i
+		}
+	}
+	if oldest < 0 {
+		return sqlc.Export{}, sql.ErrNoRows
+	}
+	m.exports[oldest].ClaimedAt = sql.NullTime{Time: now(), Valid: true}
+	return m.exports[oldest], nil
+}
+
+func (m *Memory) CompleteExport(_ context.Context, arg sqlc.CompleteExportParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, export := range m.exports {
+		if export.ID != arg.ID {
+			continue
+		}
+		if !slices.Contains(exportStatuses, arg.Status) {
+			return errCheckViolation
+		}
+		m.exports[i].Status = arg.Status
+		m.exports[i].Archive = slices.Clone(arg.Archive)
+		m.exports[i].CompletedAt = sql.NullTime{Time: now(), Valid: true}
+	}
+	return nil
+}
+
+func (m *Memory) GetExport(_ context.Context, arg sqlc.GetExportParams) (sqlc.Export, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, export := range m.exports {
+		if export.ID == arg.ID && export.UserID == arg.UserID {
+			return export, nil
+		}
+	}
+	return sqlc.Export{}, sql.ErrNoRows
+}
+
+func (m *Memory) GetLatestExport(_ context.Context, userID uuid.UUID) (sqlc.Export, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var latest sqlc.Export
+	found := false
+	for _, export := range m.exports {
+		if export.UserID == userID && (!found || export.CreatedAt.After(latest.CreatedAt)) {
+			latest, found = export, true
+		}
+	}
+	if !found {
+		return sqlc.Export{}, sql.ErrNoRows
+	}
+	return latest, nil
+}
+
+func (m *Memory) PurgeExports(_ context.Context, createdAt time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	before := len(m.exports)
+	m.exports = slices.DeleteFunc(m.exports, func(export sqlc.Export) bool {
+		return !export.CreatedAt.After(createdAt)
+	})
+	return int64(before - len(m.exports)), nil
+}
+
+func (m *Memory) ListUserChirps(_ context.Context, userID uuid.NullUUID) ([]sqlc.Chirp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var chirps []sqlc.Chirp
+	for _, chirp := range m.chirps {
+		if userID.Valid && chirp.UserID == userID {
+			chirps = append(chirps, chirp)
+		}
+	}
+	slices.SortFunc(chirps, func(a, b sqlc.Chirp) int {
+		return keyset{createdAt: a.CreatedAt, id: a.ID}.compare(keyset{createdAt: b.CreatedAt, id: b.ID})
+	})
+	return chirps, nil
+}
+
+// ImportChirp is CreateChirp with the timestamps given, for anything but a
+// rechirp. Like the ON CONFLICT DO NOTHING it mirrors, a chirp its author
+// has already posted is not an error; it just isn't inserted, and there is
+// no row to return.
+func (m *Memory) ImportChirp(_ context.Context, arg sqlc.ImportChirpParams) (sqlc.Chirp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.userExists(arg.UserID) {
+		return sqlc.Chirp{}, errForeignKeyViolation
+	}
+	for _, ref := range []uuid.NullUUID{arg.InReplyTo, arg.QuoteOf} {
+		if _, ok := m.chirpByID(ref.UUID); ref.Valid && !ok {
+			return sqlc.Chirp{}, errForeignKeyViolation
+		}
+	}
+	if m.bodyTaken(uuid.Nil, arg.UserID, arg.Body) {
+		return sqlc.Chirp{}, sql.ErrNoRows
+	}
+
+	chirp := sqlc.Chirp{
+		ID:        uuid.New(),
+		CreatedAt: arg.CreatedAt.UTC().Truncate(time.Microsecond),
+		UpdatedAt: arg.UpdatedAt.UTC().Truncate(time.Microsecond),
+		Body:      arg.Body,
+		UserID:    arg.UserID,
+		InReplyTo: arg.InReplyTo,
+		QuoteOf:   arg.QuoteOf,
+	}
+	m.chirps = append(m.chirps, chirp)
+	return chirp, nil
+}
+
+// ImportRechirp is CreateRechirp with the timestamps given.
+func (m *Memory) ImportRechirp(_ context.Context, arg sqlc.ImportRechirpParams) (sqlc.Chirp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.userExists(arg.UserID) {
+		return sqlc.Chirp{}, errForeignKeyViolation
+	}
+	if _, ok := m.chirpByID(arg.RechirpOf.UUID); arg.RechirpOf.Valid && !ok {
+		return sqlc.Chirp{}, errForeignKeyViolation
+	}
+	for _, chirp := range m.chirps {
+		if arg.RechirpOf.Valid && chirp.RechirpOf == arg.RechirpOf && sameAuthor(chirp.UserID, arg.UserID) {
+			return sqlc.Chirp{}, sql.ErrNoRows
+		}
+	}
+
+	chirp := sqlc.Chirp{
+		ID:        uuid.New(),
+		CreatedAt: arg.CreatedAt.UTC().Truncate(time.Microsecond),
+		UpdatedAt: arg.UpdatedAt.UTC().Truncate(time.Microsecond),
+		UserID:    arg.UserID,
+		RechirpOf: arg.RechirpOf,
+	}
+	m.chirps = append(m.chirps, chirp)
+	return chirp, nil
+}
@@ -0,0 +1,130 @@
+package database
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/pcauce/chirpy/internal/sqlc"
+	"slices"
+)
+
+// filterActions is the CHECK constraint on filter_terms.action.
+var filterActions = []string{"mask", "reject", "flag"}
+
+func (m *Memory) ListFilterTerms(_ context.Context) ([]sqlc.FilterTerm, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	terms := slices.Clone(m.filterTerms)
+	slices.SortFunc(terms, func(a, b sqlc.FilterTerm) int {
+		return cmp.Compare(a.Term, b.Term)
+	})
+	return terms, nil
+}
+
+func (m *Memory) CreateFilterTerm(_ context.Context, arg sqlc.CreateFilterTermParams) (sqlc.FilterTerm, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !slices.Contains(filterActions, arg.Action) {
+		return sqlc.FilterTerm{}, errCheckViolation
+	}
+	for _, term := range m.filterTerms {
+		if term.Term == arg.Term {
+			return sqlc.FilterTerm{}, errUniqueViolation
+		}
+	}
+
+	createdAt := now()
+	term := sqlc.FilterTerm{
+		ID:        uuid.New(),
+		Term:      arg.Term,
+		Action:    arg.Action,
+		CreatedAt: createdAt,
+		UpdatedAt: createdAt,
+	}
+	m.filterTerms = append(m.filterTerms, term)
+	return term, nil
+}
+
+func (m *Memory) UpdateFilterTerm(_ context.Context, arg sqlc.UpdateFilterTermParams) (sqlc.FilterTerm, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, term := range m.filterTerms {
+		if term.ID != arg.ID {
+			continue
+		}
+		if !slices.Contains(filterActions, arg.Action) {
+			return sqlc.FilterTerm{}, errCheckViolation
+		}
+		term.Action = arg.Action
+		term.UpdatedAt = now()
+		m.filterTerms[i] = term
+		return term, nil
+	}
+	return sqlc.FilterTerm{}, sql.ErrNoRows
+}
+
+func (m *Memory) DeleteFilterTerm(_ context.Context, id uuid.UUID) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	before := len(m.filterTerms)
+	m.filterTerms = slices.DeleteFunc(m.filterTerms, func(term sqlc.FilterTerm) bool {
+		return term.ID == id
+	})
+	return int64(before - len(m.filterTerms)), nil
+}
+
+func (m *Memory) FlagChirp(_ context.Context, arg sqlc.FlagChirpParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.chirpByID(arg.ChirpID); !ok {
+		return errForeignKeyViolation
+	}
+	for _, flag := range m.chirpFlags {
+		if flag.ChirpID == arg.ChirpID && flag.Term == arg.Term {
+			return nil
+		}
+	}
+	m.chirpFlags = append(m.chirpFlags, sqlc.ChirpFlag{ChirpID: arg.ChirpID, Term: arg.Term, CreatedAt: now()})
+	return nil
+}
+
+func (m *Memory) ClearChirpFlags(_ context.Context, chirpID uuid.UUID) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	before := len(m.chirpFlags)
+	m.chirpFlags = slices.DeleteFunc(m.chirpFlags, func(flag sqlc.ChirpFlag) bool {
+		return flag.ChirpID == chirpID
+	})
+	return int64(before - len(m.chirpFlags)), nil
+}
+
+func (m *Memory) ListFlaggedChirps(_ context.Context) ([]sqlc.ListFlaggedChirpsRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var rows []sqlc.ListFlaggedChirpsRow
+	for _, flag := range m.chirpFlags {
+		chirp, ok := m.chirpByID(flag.ChirpID)
+		if !ok || chirp.DeletedAt.Valid {
+			continue
+		}
+		rows = append(rows, sqlc.ListFlaggedChirpsRow{Chirp: chirp, Term: flag.Term, FlaggedAt: flag.CreatedAt})
+	}
+	slices.SortFunc(rows, func(a, b sqlc.ListFlaggedChirpsRow) int {
+		if c := a.FlaggedAt.Compare(b.FlaggedAt); c != 0 {
+			return c
+		}
+		if c := compareUUID(a.Chirp.ID, b.Chirp.ID); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Term, b.Term)
+	})
+	return rows, nil
+}
@@ -0,0 +1,148 @@
+package database
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/pcauce/chirpy/internal/sqlc"
+	"slices"
+)
+
+func (m *Memory) FollowUser(_ context.Context, arg sqlc.FollowUserParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[arg.FollowerID]; !ok {
+		return errForeignKeyViolation
+	}
+	if _, ok := m.users[arg.FolloweeID]; !ok {
+		return errForeignKeyViolation
+	}
+	if arg.FollowerID == arg.FolloweeID {
+		return errCheckViolation
+	}
+	if m.followIndex(arg.FollowerID, arg.FolloweeID) >= 0 {
+		return nil
+	}
+	m.follows = append(m.follows, sqlc.Follow{FollowerID: arg.FollowerID, FolloweeID: arg.FolloweeID, CreatedAt: now()})
+	return nil
+}
+
+func (m *Memory) UnfollowUser(_ context.Context, arg sqlc.UnfollowUserParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if i := m.followIndex(arg.FollowerID, arg.FolloweeID); i >= 0 {
+		m.follows = slices.Delete(m.follows, i, i+1)
+	}
+	return nil
+}
+
+func (m *Memory) ListFollowersAscending(_ context.Context, arg sqlc.ListFollowersAscendingParams) ([]sqlc.Follow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.listFollows(arg.FolloweeID, followers, chronologicalCursor(arg.CursorCreatedAt, arg.CursorID), arg.Limit, 1), nil
+}
+
+func (m *Memory) ListFollowersDescending(_ context.Context, arg sqlc.ListFollowersDescendingParams) ([]sqlc.Follow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.listFollows(arg.FolloweeID, followers, chronologicalCursor(arg.CursorCreatedAt, arg.CursorID), arg.Limit, -1), nil
+}
+
+func (m *Memory) ListFollowingAscending(_ context.Context, arg sqlc.ListFollowingAscendingParams) ([]sqlc.Follow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.listFollows(arg.FollowerID, following, chronologicalCursor(arg.CursorCreatedAt, arg.CursorID), arg.Limit, 1), nil
+}
+
+func (m *Memory) ListFollowingDescending(_ context.Context, arg sqlc.ListFollowingDescendingParams) ([]sqlc.Follow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.listFollows(arg.FollowerID, following, chronologicalCursor(arg.CursorCreatedAt, arg.CursorID), arg.Limit, -1), nil
+}
+
+func (m *Memory) ListTimelineAscending(_ context.Context, arg sqlc.ListTimelineAscendingParams) ([]sqlc.Chirp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ranked, err := m.listChirps(chirpFilter{
+		AuthorIn: m.timelineAuthors(arg.ViewerID),
+	}, chronologicalCursor(arg.CursorCreatedAt, arg.CursorID), arg.Limit, 1)
+	return unranked(ranked), err
+}
+
+func (m *Memory) ListTimelineDescending(_ context.Context, arg sqlc.ListTimelineDescendingParams) ([]sqlc.Chirp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ranked, err := m.listChirps(chirpFilter{
+		AuthorIn: m.timelineAuthors(arg.ViewerID),
+	}, chronologicalCursor(arg.CursorCreatedAt, arg.CursorID), arg.Limit, -1)
+	return unranked(ranked), err
+}
+
+func (m *Memory) followIndex(followerID, followeeID uuid.UUID) int {
+	return slices.IndexFunc(m.follows, func(follow sqlc.Follow) bool {
+		return follow.FollowerID == followerID && follow.FolloweeID == followeeID
+	})
+}
+
+// followSide picks one end of a follow: the user a list is for, and the user
+// each entry in it names.
+type followSide struct {
+	owner, other func(sqlc.Follow) uuid.UUID
+}
+
+var (
+	followers = followSide{
+		owner: func(f sqlc.Follow) uuid.UUID { return f.FolloweeID },
+		other: func(f sqlc.Follow) uuid.UUID { return f.FollowerID },
+	}
+	following = followSide{
+		owner: func(f sqlc.Follow) uuid.UUID { return f.FollowerID },
+		other: func(f sqlc.Follow) uuid.UUID { return f.FolloweeID },
+	}
+)
+
+// listFollows returns up to limit follows of userID's side, ordered by
+// (created_at, other user) ascending when direction is 1 and descending when
+// it is -1, starting strictly past from if it is not nil.
+func (m *Memory) listFollows(userID uuid.UUID, side followSide, from *keyset, limit int32, direction int) []sqlc.Follow {
+	key := func(f sqlc.Follow) keyset {
+		return keyset{createdAt: f.CreatedAt, id: side.other(f)}
+	}
+
+	var follows []sqlc.Follow
+	for _, follow := range m.follows {
+		if side.owner(follow) != userID {
+			continue
+		}
+		if from != nil && key(follow).compare(*from)*direction <= 0 {
+			continue
+		}
+		follows = append(follows, follow)
+	}
+
+	slices.SortFunc(follows, func(a, b sqlc.Follow) int {
+		return key(a).compare(key(b)) * direction
+	})
+	if len(follows) > int(limit) {
+		follows = follows[:max(limit, 0)]
+	}
+	return follows
+}
+
+// timelineAuthors is viewerID and everyone they follow.
+func (m *Memory) timelineAuthors(viewerID uuid.UUID) []uuid.UUID {
+	authors := []uuid.UUID{viewerID}
+	for _, follow := range m.follows {
+		if follow.FollowerID == viewerID {
+			authors = append(authors, follow.FolloweeID)
+		}
+	}
+	return authors
+}
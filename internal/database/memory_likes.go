@@ -0,0 +1,119 @@
+package database
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/pcauce/chirpy/internal/sqlc"
+	"slices"
+)
+
+func (m *Memory) LikeChirp(_ context.Context, arg sqlc.LikeChirpParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[arg.UserID]; !ok {
+		return errForeignKeyViolation
+	}
+	if _, ok := m.chirpByID(arg.ChirpID); !ok {
+		return errForeignKeyViolation
+	}
+	if m.likeIndex(arg.UserID, arg.ChirpID) >= 0 {
+		return nil
+	}
+	m.likes = append(m.likes, sqlc.Like{UserID: arg.UserID, ChirpID: arg.ChirpID, CreatedAt: now()})
+	return nil
+}
+
+func (m *Memory) UnlikeChirp(_ context.Context, arg sqlc.UnlikeChirpParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if i := m.likeIndex(arg.UserID, arg.ChirpID); i >= 0 {
+		m.likes = slices.Delete(m.likes, i, i+1)
+	}
+	return nil
+}
+
+func (m *Memory) CountLikes(_ context.Context, chirpIds []uuid.UUID) ([]sqlc.CountLikesRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var rows []sqlc.CountLikesRow
+	for _, like := range m.likes {
+		if !slices.Contains(chirpIds, like.ChirpID) {
+			continue
+		}
+		i := slices.IndexFunc(rows, func(row sqlc.CountLikesRow) bool { return row.ChirpID == like.ChirpID })
+		if i < 0 {
+			rows = append(rows, sqlc.CountLikesRow{ChirpID: like.ChirpID})
+			i = len(rows) - 1
+		}
+		rows[i].LikeCount++
+	}
+	return rows, nil
+}
+
+func (m *Memory) ListLikedChirpIDs(_ context.Context, arg sqlc.ListLikedChirpIDsParams) ([]uuid.UUID, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var ids []uuid.UUID
+	for _, like := range m.likes {
+		if like.UserID == arg.UserID && slices.Contains(arg.ChirpIds, like.ChirpID) {
+			ids = append(ids, like.ChirpID)
+		}
+	}
+	return ids, nil
+}
+
+func (m *Memory) ListUserLikesAscending(_ context.Context, arg sqlc.ListUserLikesAscendingParams) ([]sqlc.ListUserLikesAscendingRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var rows []sqlc.ListUserLikesAscendingRow
+	for _, liked := range m.userLikes(arg.UserID, chronologicalCursor(arg.CursorCreatedAt, arg.CursorID), arg.Limit, 1) {
+		rows = append(rows, sqlc.ListUserLikesAscendingRow(liked))
+	}
+	return rows, nil
+}
+
+func (m *Memory) ListUserLikesDescending(_ context.Context, arg sqlc.ListUserLikesDescendingParams) ([]sqlc.ListUserLikesDescendingRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.userLikes(arg.UserID, chronologicalCursor(arg.CursorCreatedAt, arg.CursorID), arg.Limit, -1), nil
+}
+
+func (m *Memory) likeIndex(userID, chirpID uuid.UUID) int {
+	return slices.IndexFunc(m.likes, func(like sqlc.Like) bool {
+		return like.UserID == userID && like.ChirpID == chirpID
+	})
+}
+
+// userLikes returns up to limit live chirps liked by userID, ordered by
+// (liked_at, chirp_id) ascending when direction is 1 and descending when it
+// is -1, starting strictly past from if it is not nil.
+func (m *Memory) userLikes(userID uuid.UUID, from *keyset, limit int32, direction int) []sqlc.ListUserLikesDescendingRow {
+	var rows []sqlc.ListUserLikesDescendingRow
+	for _, like := range m.likes {
+		if like.UserID != userID {
+			continue
+		}
+		chirp, ok := m.chirpByID(like.ChirpID)
+		if !ok || chirp.DeletedAt.Valid {
+			continue
+		}
+		if from != nil && (keyset{createdAt: like.CreatedAt, id: like.ChirpID}).compare(*from)*direction <= 0 {
+			continue
+		}
+		rows = append(rows, sqlc.ListUserLikesDescendingRow{Chirp: chirp, LikedAt: like.CreatedAt})
+	}
+
+	slices.SortFunc(rows, func(a, b sqlc.ListUserLikesDescendingRow) int {
+		return keyset{createdAt: a.LikedAt, id: a.Chirp.ID}.compare(keyset{createdAt: b.LikedAt, id: b.Chirp.ID}) * direction
+	})
+	if len(rows) > int(limit) {
+		rows = rows[:max(limit, 0)]
+	}
+	return rows
+}
@@ -0,0 +1,94 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/pcauce/chirpy/internal/sqlc"
+	"strings"
+)
+
+func (m *Memory) GetUserByHandle(_ context.Context, handle string) (sqlc.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, user := range m.users {
+		if user.Handle.Valid && strings.EqualFold(user.Handle.String, handle) {
+			return user, nil
+		}
+	}
+	return sqlc.User{}, sql.ErrNoRows
+}
+
+func (m *Memory) UpdateUserProfile(_ context.Context, arg sqlc.UpdateUserProfileParams) (sqlc.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[arg.ID]
+	if !ok {
+		return sqlc.User{}, sql.ErrNoRows
+	}
+	for _, other := range m.users {
+		if other.ID != arg.ID && arg.Handle.Valid && other.Handle.Valid && strings.EqualFold(other.Handle.String, arg.Handle.String) {
+			return sqlc.User{}, errUniqueViolation
+		}
+	}
+
+	user.Handle = arg.Handle
+	user.DisplayName = arg.DisplayName
+	user.Bio = arg.Bio
+	user.UpdatedAt = now()
+	m.users[user.ID] = user
+	return user, nil
+}
+
+func (m *Memory) GetUserStats(_ context.Context, userID uuid.UUID) (sqlc.GetUserStatsRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var stats sqlc.GetUserStatsRow
+	for _, chirp := range m.chirps {
+		if chirp.UserID.Valid && chirp.UserID.UUID == userID && !chirp.DeletedAt.Valid {
+			stats.ChirpCount++
+		}
+	}
+	for _, follow := range m.follows {
+		if follow.FolloweeID == userID {
+			stats.FollowerCount++
+		}
+		if follow.FollowerID == userID {
+			stats.FollowingCount++
+		}
+	}
+	return stats, nil
+}
+
+func (m *Memory) ReleaseHandle(_ context.Context, arg sqlc.ReleaseHandleParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.userExists(arg.UserID) {
+		return errForeignKeyViolation
+	}
+	handle := strings.ToLower(arg.Handle)
+	m.releasedHandles[handle] = sqlc.ReleasedHandle{Handle: handle, UserID: arg.UserID, ReleasedAt: now()}
+	return nil
+}
+
+func (m *Memory) GetReleasedHandle(_ context.Context, handle string) (sqlc.ReleasedHandle, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if released, ok := m.releasedHandles[strings.ToLower(handle)]; ok {
+		return released, nil
+	}
+	return sqlc.ReleasedHandle{}, sql.ErrNoRows
+}
+
+func (m *Memory) DeleteReleasedHandle(_ context.Context, handle string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.releasedHandles, strings.ToLower(handle))
+	return nil
+}
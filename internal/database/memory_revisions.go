@@ -0,0 +1,54 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/pcauce/chirpy/internal/sqlc"
+	"slices"
+)
+
+func (m *Memory) UpdateChirpBody(_ context.Context, arg sqlc.UpdateChirpBodyParams) (sqlc.Chirp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := slices.IndexFunc(m.chirps, func(chirp sqlc.Chirp) bool {
+		return chirp.ID == arg.ID && arg.UserID.Valid && chirp.UserID == arg.UserID && !chirp.DeletedAt.Valid
+	})
+	if i < 0 {
+		return sqlc.Chirp{}, sql.ErrNoRows
+	}
+	if m.bodyTaken(arg.ID, arg.UserID, arg.Body) {
+		return sqlc.Chirp{}, errUniqueViolation
+	}
+
+	updatedAt := now()
+	chirp := m.chirps[i]
+	m.revisions = append(m.revisions, sqlc.ChirpRevision{
+		ID:         uuid.New(),
+		ChirpID:    chirp.ID,
+		Body:       chirp.Body,
+		CreatedAt:  chirp.UpdatedAt,
+		ReplacedAt: updatedAt,
+	})
+	chirp.Body = arg.Body
+	chirp.UpdatedAt = updatedAt
+	m.chirps[i] = chirp
+	return chirp, nil
+}
+
+func (m *Memory) ListChirpRevisions(_ context.Context, chirpID uuid.UUID) ([]sqlc.ChirpRevision, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var revisions []sqlc.ChirpRevision
+	for _, revision := range m.revisions {
+		if revision.ChirpID == chirpID {
+			revisions = append(revisions, revision)
+		}
+	}
+	slices.SortStableFunc(revisions, func(a, b sqlc.ChirpRevision) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return revisions, nil
+}
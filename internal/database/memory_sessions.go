@@ -0,0 +1,129 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/pcauce/chirpy/internal/sqlc"
+	"slices"
+	"time"
+)
+
+func (m *Memory) CreateSession(_ context.Context, arg sqlc.CreateSessionParams) (sqlc.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.userExists(uuid.NullUUID{UUID: arg.UserID, Valid: true}) {
+		return sqlc.Session{}, errForeignKeyViolation
+	}
+	createdAt := now()
+	session := sqlc.Session{
+		ID:         uuid.New(),
+		UserID:     arg.UserID,
+		UserAgent:  arg.UserAgent,
+		IP:         arg.IP,
+		CreatedAt:  createdAt,
+		LastUsedAt: createdAt,
+		ExpiresAt:  arg.ExpiresAt,
+	}
+	m.sessions[session.ID] = session
+	return session, nil
+}
+
+func (m *Memory) GetSession(_ context.Context, id uuid.UUID) (sqlc.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, ok := m.sessions[id]
+	if !ok {
+		return sqlc.Session{}, sql.ErrNoRows
+	}
+	return session, nil
+}
+
+func (m *Memory) ListActiveSessions(_ context.Context, userID uuid.UUID) ([]sqlc.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var sessions []sqlc.Session
+	for _, session := range m.sessions {
+		if session.UserID == userID && !session.RevokedAt.Valid && session.ExpiresAt.After(time.Now()) {
+			sessions = append(sessions, session)
+		}
+	}
+	slices.SortFunc(sessions, func(a, b sqlc.Session) int {
+		if c := b.LastUsedAt.Compare(a.LastUsedAt); c != 0 {
+			return c
+		}
+		return compareUUID(a.ID, b.ID)
+	})
+	return sessions, nil
+}
+
+func (m *Memory) ListUserSessions(_ context.Context, userID uuid.UUID) ([]sqlc.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var sessions []sqlc.Session
+	for _, session := range m.sessions {
+		if session.UserID == userID {
+			sessions = append(sessions, session)
+		}
+	}
+	slices.SortFunc(sessions, func(a, b sqlc.Session) int {
+		return keyset{createdAt: a.CreatedAt, id: a.ID}.compare(keyset{createdAt: b.CreatedAt, id: b.ID})
+	})
+	return sessions, nil
+}
+
+func (m *Memory) TouchSession(_ context.Context, arg sqlc.TouchSessionParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if session, ok := m.sessions[arg.ID]; ok {
+		session.LastUsedAt = now()
+		session.IP = arg.IP
+		m.sessions[arg.ID] = session
+	}
+	return nil
+}
+
+func (m *Memory) RenewSession(_ context.Context, arg sqlc.RenewSessionParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if session, ok := m.sessions[arg.ID]; ok {
+		session.LastUsedAt = now()
+		session.IP = arg.IP
+		session.ExpiresAt = arg.ExpiresAt
+		m.sessions[arg.ID] = session
+	}
+	return nil
+}
+
+func (m *Memory) RevokeSession(_ context.Context, arg sqlc.RevokeSessionParams) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, ok := m.sessions[arg.ID]
+	if !ok || session.UserID != arg.UserID || session.RevokedAt.Valid {
+		return 0, nil
+	}
+	session.RevokedAt = sql.NullTime{Time: now(), Valid: true}
+	m.sessions[arg.ID] = session
+	return 1, nil
+}
+
+func (m *Memory) RevokeUserSessions(_ context.Context, userID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	revokedAt := now()
+	for id, session := range m.sessions {
+		if session.UserID == userID && !session.RevokedAt.Valid {
+			session.RevokedAt = sql.NullTime{Time: revokedAt, Valid: true}
+			m.sessions[id] = session
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/pcauce/chirpy/internal/sqlc"
+	"slices"
+)
+
+// CreateRechirp is CreateChirp for a rechirp. Like the ON CONFLICT DO
+// NOTHING it mirrors, rechirping a chirp twice is not an error; nothing is
+// inserted, and there is no row to return.
+func (m *Memory) CreateRechirp(_ context.Context, arg sqlc.CreateRechirpParams) (sqlc.Chirp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.userExists(arg.UserID) {
+		return sqlc.Chirp{}, errForeignKeyViolation
+	}
+	if _, ok := m.chirpByID(arg.RechirpOf.UUID); arg.RechirpOf.Valid && !ok {
+		return sqlc.Chirp{}, errForeignKeyViolation
+	}
+	for _, chirp := range m.chirps {
+		if arg.RechirpOf.Valid && chirp.RechirpOf == arg.RechirpOf && sameAuthor(chirp.UserID, arg.UserID) {
+			return sqlc.Chirp{}, sql.ErrNoRows
+		}
+	}
+
+	createdAt := now()
+	chirp := sqlc.Chirp{
+		ID:        uuid.New(),
+		CreatedAt: createdAt,
+		UpdatedAt: createdAt,
+		UserID:    arg.UserID,
+		RechirpOf: arg.RechirpOf,
+	}
+	m.chirps = append(m.chirps, chirp)
+	return chirp, nil
+}
+
+func (m *Memory) DeleteRechirp(_ context.Context, arg sqlc.DeleteRechirpParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.deleteChirpsWhere(func(chirp sqlc.Chirp) bool {
+		return chirp.ID == arg.ID && arg.UserID.Valid && chirp.UserID == arg.UserID && chirp.RechirpOf.Valid
+	})
+	return nil
+}
+
+func (m *Memory) CountShares(_ context.Context, chirpIds []uuid.UUID) ([]sqlc.CountSharesRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var rows []sqlc.CountSharesRow
+	for _, chirp := range m.chirps {
+		shared := chirp.RechirpOf
+		if !shared.Valid {
+			shared = chirp.QuoteOf
+		}
+		if chirp.DeletedAt.Valid || !shared.Valid || !slices.Contains(chirpIds, shared.UUID) {
+			continue
+		}
+		i := slices.IndexFunc(rows, func(row sqlc.CountSharesRow) bool { return row.ChirpID == shared })
+		if i < 0 {
+			rows = append(rows, sqlc.CountSharesRow{ChirpID: shared})
+			i = len(rows) - 1
+		}
+		if chirp.RechirpOf.Valid {
+			rows[i].RechirpCount++
+		}
+		if chirp.QuoteOf.Valid {
+			rows[i].QuoteCount++
+		}
+	}
+	return rows, nil
+}
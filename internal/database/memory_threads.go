@@ -0,0 +1,113 @@
+package database
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/pcauce/chirpy/internal/sqlc"
+	"slices"
+)
+
+func (m *Memory) CountReplies(_ context.Context, chirpIds []uuid.UUID) ([]sqlc.CountRepliesRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var rows []sqlc.CountRepliesRow
+	for _, id := range chirpIds {
+		if slices.ContainsFunc(rows, func(row sqlc.CountRepliesRow) bool { return row.ChirpID.UUID == id }) {
+			continue
+		}
+		var count int64
+		for _, chirp := range m.chirps {
+			if chirp.InReplyTo.Valid && chirp.InReplyTo.UUID == id && !chirp.DeletedAt.Valid {
+				count++
+			}
+		}
+		if count > 0 {
+			rows = append(rows, sqlc.CountRepliesRow{
+				ChirpID:    uuid.NullUUID{UUID: id, Valid: true},
+				ReplyCount: count,
+			})
+		}
+	}
+	return rows, nil
+}
+
+func (m *Memory) GetChirpAncestors(_ context.Context, id uuid.UUID) ([]sqlc.Chirp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var ancestors []sqlc.Chirp
+	chirp, ok := m.chirpByID(id)
+	for ok && chirp.InReplyTo.Valid {
+		chirp, ok = m.chirpByID(chirp.InReplyTo.UUID)
+		if ok {
+			ancestors = append(ancestors, chirp)
+		}
+	}
+	slices.Reverse(ancestors)
+	return ancestors, nil
+}
+
+func (m *Memory) ListRepliesAscending(_ context.Context, arg sqlc.ListRepliesAscendingParams) ([]sqlc.Chirp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ranked, err := m.listChirps(chirpFilter{
+		InReplyTo:          arg.InReplyTo,
+		KeepTrashedParents: true,
+	}, chronologicalCursor(arg.CursorCreatedAt, arg.CursorID), arg.Limit, 1)
+	return unranked(ranked), err
+}
+
+func (m *Memory) ListRepliesDescending(_ context.Context, arg sqlc.ListRepliesDescendingParams) ([]sqlc.Chirp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ranked, err := m.listChirps(chirpFilter{
+		InReplyTo:          arg.InReplyTo,
+		KeepTrashedParents: true,
+	}, chronologicalCursor(arg.CursorCreatedAt, arg.CursorID), arg.Limit, -1)
+	return unranked(ranked), err
+}
+
+func (m *Memory) GetChirpDescendants(_ context.Context, arg sqlc.GetChirpDescendantsParams) ([]sqlc.Chirp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var descendants []sqlc.Chirp
+	parents := arg.RootIds
+	for depth := int32(1); depth <= arg.MaxDepth && len(parents) > 0; depth++ {
+		var children []uuid.UUID
+		for _, chirp := range m.chirps {
+			if chirp.InReplyTo.Valid && slices.Contains(parents, chirp.InReplyTo.UUID) {
+				descendants = append(descendants, chirp)
+				children = append(children, chirp.ID)
+			}
+		}
+		parents = children
+	}
+
+	slices.SortFunc(descendants, func(a, b sqlc.Chirp) int {
+		return keyset{createdAt: a.CreatedAt, id: a.ID}.compare(keyset{createdAt: b.CreatedAt, id: b.ID})
+	})
+	return descendants, nil
+}
+
+func (m *Memory) chirpByID(id uuid.UUID) (sqlc.Chirp, bool) {
+	for _, chirp := range m.chirps {
+		if chirp.ID == id {
+			return chirp, true
+		}
+	}
+	return sqlc.Chirp{}, false
+}
+
+// hasReplies reports whether any chirp, trashed or not, replies to id.
+func (m *Memory) hasReplies(id uuid.UUID) bool {
+	for _, chirp := range m.chirps {
+		if chirp.InReplyTo.Valid && chirp.InReplyTo.UUID == id {
+			return true
+		}
+	}
+	return false
+}
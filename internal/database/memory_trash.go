@@ -0,0 +1,72 @@
+package database
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"github.com/pcauce/chirpy/internal/sqlc"
+	"slices"
+)
+
+func (m *Memory) TrashChirp(_ context.Context, arg sqlc.TrashChirpParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, chirp := range m.chirps {
+		if chirp.ID == arg.ID && arg.UserID.Valid && chirp.UserID == arg.UserID && !chirp.DeletedAt.Valid {
+			m.chirps[i].DeletedAt = sql.NullTime{Time: now(), Valid: true}
+		}
+	}
+	return nil
+}
+
+func (m *Memory) ListTrashedChirps(_ context.Context, arg sqlc.ListTrashedChirpsParams) ([]sqlc.Chirp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var chirps []sqlc.Chirp
+	for _, chirp := range m.chirps {
+		if arg.UserID.Valid && chirp.UserID == arg.UserID && deletedAfter(chirp, arg.DeletedAt) {
+			chirps = append(chirps, chirp)
+		}
+	}
+	slices.SortFunc(chirps, func(a, b sqlc.Chirp) int {
+		return -cmp.Or(a.DeletedAt.Time.Compare(b.DeletedAt.Time), compareUUID(a.ID, b.ID))
+	})
+	return chirps, nil
+}
+
+func (m *Memory) RestoreChirp(_ context.Context, arg sqlc.RestoreChirpParams) (sqlc.Chirp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, chirp := range m.chirps {
+		if chirp.ID == arg.ID && arg.UserID.Valid && chirp.UserID == arg.UserID && deletedAfter(chirp, arg.DeletedAt) {
+			if !chirp.RechirpOf.Valid && m.bodyTaken(chirp.ID, chirp.UserID, chirp.Body) {
+				return sqlc.Chirp{}, errUniqueViolation
+			}
+			m.chirps[i].DeletedAt = sql.NullTime{}
+			return m.chirps[i], nil
+		}
+	}
+	return sqlc.Chirp{}, sql.ErrNoRows
+}
+
+func (m *Memory) PurgeTrashedChirps(_ context.Context, deletedAt sql.NullTime) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !deletedAt.Valid {
+		return 0, nil
+	}
+	before := len(m.chirps)
+	m.deleteChirpsWhere(func(chirp sqlc.Chirp) bool {
+		return chirp.DeletedAt.Valid && !chirp.DeletedAt.Time.After(deletedAt.Time)
+	})
+	return int64(before - len(m.chirps)), nil
+}
+
+// deletedAfter mirrors `deleted_at > $n`, which is never true for NULLs.
+func deletedAfter(chirp sqlc.Chirp, t sql.NullTime) bool {
+	return chirp.DeletedAt.Valid && t.Valid && chirp.DeletedAt.Time.After(t.Time)
+}
@@ -0,0 +1,28 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/pcauce/chirpy/sql/schema"
+	"github.com/pressly/goose/v3"
+	"slices"
+)
+
+// MigrateCommands are the goose commands exposed by `chirpy migrate`.
+var MigrateCommands = []string{"up", "down", "status", "redo"}
+
+// Migrate runs a goose command against db using the migrations embedded in
+// the binary.
+func Migrate(ctx context.Context, db *sql.DB, command string) error {
+	if !slices.Contains(MigrateCommands, command) {
+		return fmt.Errorf("unknown migrate command %q", command)
+	}
+
+	goose.SetBaseFS(schema.Migrations)
+	err := goose.SetDialect("postgres")
+	if err != nil {
+		return err
+	}
+	return goose.RunContext(ctx, command, db, ".")
+}
@@ -0,0 +1,1315 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+	"github.com/pcauce/chirpy/internal/sqlc"
+)
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, func(t *testing.T) Store {
+		return NewMemory()
+	})
+}
+
+// TestPostgresStore runs the conformance suite against a real database. It
+// is skipped unless TEST_DB_URL points at a disposable database, which is
+// migrated to the latest schema first.
+func TestPostgresStore(t *testing.T) {
+	dbURL := os.Getenv("TEST_DB_URL")
+	if dbURL == "" {
+		t.Skip("TEST_DB_URL not set")
+	}
+	db, err := Open(dbURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := Migrate(context.Background(), db, "up"); err != nil {
+		t.Fatal(err)
+	}
+
+	testStore(t, func(t *testing.T) Store {
+		if _, err := db.Exec("TRUNCATE users, chirps, chirp_revisions, likes, follows, chirp_flags, chirp_hashtags, chirp_mentions, released_handles, exports, sessions, refresh_tokens, revoked_access_tokens, api_keys CASCADE"); err != nil {
+			t.Fatal(err)
+		}
+		return NewPostgres(db)
+	})
+}
+
+// testStore is the conformance suite every Store implementation must pass.
+// newStore must return an empty store on every call.
+func testStore(t *testing.T, newStore func(t *testing.T) Store) {
+	ctx := context.Background()
+
+	createUser := func(t *testing.T, store Store, email string) sqlc.User {
+		t.Helper()
+		user, err := store.CreateUser(ctx, sqlc.CreateUserParams{Email: email, HashedPassword: "hash"})
+		if err != nil {
+			t.Fatalf("CreateUser() error = %v", err)
+		}
+		return user
+	}
+	// tick waits until the clock is past the microsecond the last write may
+	// have been stamped with. Rows written within the same microsecond tie
+	// on created_at, and their order then comes down to their random IDs,
+	// so a test that expects one row to sort after another ticks between
+	// writing them.
+	tick := func() {
+		time.Sleep(time.Microsecond)
+	}
+	createChirp := func(t *testing.T, store Store, body string, userID uuid.UUID) sqlc.Chirp {
+		t.Helper()
+		tick()
+		chirp, err := store.CreateChirp(ctx, sqlc.CreateChirpParams{
+			Body:   body,
+			UserID: uuid.NullUUID{UUID: userID, Valid: true},
+		})
+		if err != nil {
+			t.Fatalf("CreateChirp() error = %v", err)
+		}
+		return chirp
+	}
+	createSession := func(t *testing.T, store Store, userID uuid.UUID) sqlc.Session {
+		t.Helper()
+		tick()
+		session, err := store.CreateSession(ctx, sqlc.CreateSessionParams{
+			UserID:    userID,
+			UserAgent: "curl/8.0",
+			IP:        "192.0.2.1",
+			ExpiresAt: time.Now().Add(time.Hour),
+		})
+		if err != nil {
+			t.Fatalf("CreateSession() error = %v", err)
+		}
+		return session
+	}
+
+	t.Run("Users", func(t *testing.T) {
+		store := newStore(t)
+		user := createUser(t, store, "walt@example.com")
+		if user.ID == uuid.Nil || user.IsChirpyRed || user.HashedPassword != "hash" {
+			t.Errorf("CreateUser() = %+v", user)
+		}
+
+		if _, err := store.CreateUser(ctx, sqlc.CreateUserParams{Email: "walt@example.com"}); err == nil {
+			t.Error("CreateUser() with duplicate email should fail")
+		}
+
+		got, err := store.GetUserByEmail(ctx, "walt@example.com")
+		if err != nil || got.ID != user.ID {
+			t.Errorf("GetUserByEmail() = %v, %v, want %v", got.ID, err, user.ID)
+		}
+		if _, err := store.GetUserByEmail(ctx, "nobody@example.com"); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetUserByEmail() unknown email error = %v, want sql.ErrNoRows", err)
+		}
+
+		updated, err := store.UpdateUserEmail(ctx, sqlc.UpdateUserEmailParams{ID: user.ID, Email: "heisenberg@example.com"})
+		if err != nil || updated.Email != "heisenberg@example.com" {
+			t.Errorf("UpdateUserEmail() = %v, %v", updated.Email, err)
+		}
+		if _, err := store.UpdateUserEmail(ctx, sqlc.UpdateUserEmailParams{ID: uuid.New(), Email: "x@example.com"}); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("UpdateUserEmail() unknown user error = %v, want sql.ErrNoRows", err)
+		}
+		other := createUser(t, store, "jesse@example.com")
+		if _, err := store.UpdateUserEmail(ctx, sqlc.UpdateUserEmailParams{ID: other.ID, Email: "heisenberg@example.com"}); err == nil {
+			t.Error("UpdateUserEmail() to a taken email should fail")
+		}
+
+		if _, err := store.UpdateUserPassword(ctx, sqlc.UpdateUserPasswordParams{ID: user.ID, HashedPassword: "new"}); err != nil {
+			t.Errorf("UpdateUserPassword() error = %v", err)
+		}
+		got, _ = store.GetUserByEmail(ctx, "heisenberg@example.com")
+		if got.HashedPassword != "new" {
+			t.Errorf("UpdateUserPassword() stored %q, want %q", got.HashedPassword, "new")
+		}
+
+		if err := store.UpgradeChirpyRed(ctx, user.ID); err != nil {
+			t.Errorf("UpgradeChirpyRed() error = %v", err)
+		}
+		got, _ = store.GetUserByEmail(ctx, "heisenberg@example.com")
+		if !got.IsChirpyRed {
+			t.Error("UpgradeChirpyRed() did not set is_chirpy_red")
+		}
+
+		if user.Role != "user" {
+			t.Errorf("CreateUser() role = %q, want %q", user.Role, "user")
+		}
+		promoted, err := store.SetUserRole(ctx, sqlc.SetUserRoleParams{ID: user.ID, Role: "admin"})
+		if err != nil || promoted.Role != "admin" {
+			t.Errorf("SetUserRole() = %q, %v, want %q", promoted.Role, err, "admin")
+		}
+		if _, err := store.SetUserRole(ctx, sqlc.SetUserRoleParams{ID: user.ID, Role: "kingpin"}); err == nil {
+			t.Error("SetUserRole() with an unknown role should fail")
+		}
+		if _, err := store.SetUserRole(ctx, sqlc.SetUserRoleParams{ID: uuid.New(), Role: "admin"}); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("SetUserRole() unknown user error = %v, want sql.ErrNoRows", err)
+		}
+	})
+
+	t.Run("Chirps", func(t *testing.T) {
+		store := newStore(t)
+		walt := createUser(t, store, "walt@example.com")
+		jesse := createUser(t, store, "jesse@example.com")
+
+		first := createChirp(t, store, "first", walt.ID)
+		second := createChirp(t, store, "second", jesse.ID)
+		third := createChirp(t, store, "third", walt.ID)
+
+		if _, err := store.CreateChirp(ctx, sqlc.CreateChirpParams{
+			Body:   "first",
+			UserID: uuid.NullUUID{UUID: walt.ID, Valid: true},
+		}); err == nil {
+			t.Error("CreateChirp() with duplicate body should fail")
+		}
+		if _, err := store.CreateChirp(ctx, sqlc.CreateChirpParams{
+			Body:   "orphan",
+			UserID: uuid.NullUUID{UUID: uuid.New(), Valid: true},
+		}); err == nil {
+			t.Error("CreateChirp() for unknown user should fail")
+		}
+
+		all, err := store.ListChirpsAscending(ctx, sqlc.ListChirpsAscendingParams{Limit: 10})
+		if err != nil {
+			t.Fatalf("ListChirpsAscending() error = %v", err)
+		}
+		if len(all) != 3 || all[0].ID != first.ID || all[1].ID != second.ID || all[2].ID != third.ID {
+			t.Errorf("ListChirpsAscending() returned %d chirps out of creation order", len(all))
+		}
+
+		desc, err := store.ListChirpsDescending(ctx, sqlc.ListChirpsDescendingParams{Limit: 2})
+		if err != nil || len(desc) != 2 || desc[0].ID != third.ID || desc[1].ID != second.ID {
+			t.Errorf("ListChirpsDescending() = %d chirps, %v, want [third second]", len(desc), err)
+		}
+
+		after, err := store.ListChirpsAscending(ctx, sqlc.ListChirpsAscendingParams{
+			CursorCreatedAt: sql.NullTime{Time: first.CreatedAt, Valid: true},
+			CursorID:        uuid.NullUUID{UUID: first.ID, Valid: true},
+			Limit:           10,
+		})
+		if err != nil || len(after) != 2 || after[0].ID != second.ID {
+			t.Errorf("ListChirpsAscending() after first = %d chirps, %v, want [second third]", len(after), err)
+		}
+
+		before, err := store.ListChirpsDescending(ctx, sqlc.ListChirpsDescendingParams{
+			CursorCreatedAt: sql.NullTime{Time: third.CreatedAt, Valid: true},
+			CursorID:        uuid.NullUUID{UUID: third.ID, Valid: true},
+			Limit:           10,
+		})
+		if err != nil || len(before) != 2 || before[0].ID != second.ID {
+			t.Errorf("ListChirpsDescending() before third = %d chirps, %v, want [second first]", len(before), err)
+		}
+
+		byWalt, err := store.ListChirpsDescending(ctx, sqlc.ListChirpsDescendingParams{
+			AuthorID: uuid.NullUUID{UUID: walt.ID, Valid: true},
+			Limit:    10,
+		})
+		if err != nil || len(byWalt) != 2 || byWalt[0].ID != third.ID {
+			t.Errorf("ListChirpsDescending() by author = %d chirps, %v, want [third first]", len(byWalt), err)
+		}
+
+		got, err := store.GetChirpByID(ctx, second.ID)
+		if err != nil || got.Body != "second" || got.UserID.UUID != jesse.ID {
+			t.Errorf("GetChirpByID() = %+v, %v", got, err)
+		}
+		if _, err := store.GetChirpByID(ctx, uuid.New()); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetChirpByID() unknown chirp error = %v, want sql.ErrNoRows", err)
+		}
+
+		err = store.TrashChirp(ctx, sqlc.TrashChirpParams{ID: first.ID, UserID: uuid.NullUUID{UUID: jesse.ID, Valid: true}})
+		if err != nil {
+			t.Errorf("TrashChirp() by non-author error = %v", err)
+		}
+		if _, err := store.GetChirpByID(ctx, first.ID); err != nil {
+			t.Error("TrashChirp() by non-author hid the chirp")
+		}
+	})
+
+	t.Run("Trash", func(t *testing.T) {
+		store := newStore(t)
+		walt := createUser(t, store, "walt@example.com")
+		waltID := uuid.NullUUID{UUID: walt.ID, Valid: true}
+		kept := createChirp(t, store, "kept", walt.ID)
+		trashed := createChirp(t, store, "trashed", walt.ID)
+		_, err := store.UpdateChirpBody(ctx, sqlc.UpdateChirpBodyParams{ID: trashed.ID, UserID: waltID, Body: "trashed, edited"})
+		if err != nil {
+			t.Fatalf("UpdateChirpBody() error = %v", err)
+		}
+
+		if err := store.TrashChirp(ctx, sqlc.TrashChirpParams{ID: trashed.ID, UserID: waltID}); err != nil {
+			t.Fatalf("TrashChirp() error = %v", err)
+		}
+		if _, err := store.GetChirpByID(ctx, trashed.ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetChirpByID() trashed chirp error = %v, want sql.ErrNoRows", err)
+		}
+		listed, _ := store.ListChirpsAscending(ctx, sqlc.ListChirpsAscendingParams{Limit: 10})
+		if len(listed) != 1 || listed[0].ID != kept.ID {
+			t.Errorf("ListChirpsAscending() = %d chirps, want only the kept one", len(listed))
+		}
+		if _, err := store.UpdateChirpBody(ctx, sqlc.UpdateChirpBodyParams{ID: trashed.ID, UserID: waltID, Body: "zombie"}); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("UpdateChirpBody() trashed chirp error = %v, want sql.ErrNoRows", err)
+		}
+
+		longAgo := sql.NullTime{Time: time.Now().Add(-time.Hour), Valid: true}
+		inTrash, err := store.ListTrashedChirps(ctx, sqlc.ListTrashedChirpsParams{UserID: waltID, DeletedAt: longAgo})
+		if err != nil || len(inTrash) != 1 || inTrash[0].ID != trashed.ID || !inTrash[0].DeletedAt.Valid {
+			t.Fatalf("ListTrashedChirps() = %+v, %v", inTrash, err)
+		}
+
+		_, err = store.RestoreChirp(ctx, sqlc.RestoreChirpParams{
+			ID:        trashed.ID,
+			UserID:    waltID,
+			DeletedAt: sql.NullTime{Time: time.Now().Add(time.Hour), Valid: true},
+		})
+		if !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("RestoreChirp() past the window error = %v, want sql.ErrNoRows", err)
+		}
+		again := createChirp(t, store, "trashed, edited", walt.ID)
+		_, err = store.RestoreChirp(ctx, sqlc.RestoreChirpParams{ID: trashed.ID, UserID: waltID, DeletedAt: longAgo})
+		if !IsUniqueViolation(err) {
+			t.Errorf("RestoreChirp() while a live chirp has its body error = %v, want a unique violation", err)
+		}
+		if err := store.TrashChirp(ctx, sqlc.TrashChirpParams{ID: again.ID, UserID: waltID}); err != nil {
+			t.Fatalf("TrashChirp() error = %v", err)
+		}
+		restored, err := store.RestoreChirp(ctx, sqlc.RestoreChirpParams{ID: trashed.ID, UserID: waltID, DeletedAt: longAgo})
+		if err != nil || restored.DeletedAt.Valid {
+			t.Fatalf("RestoreChirp() = %+v, %v", restored, err)
+		}
+		if _, err := store.GetChirpByID(ctx, trashed.ID); err != nil {
+			t.Errorf("GetChirpByID() restored chirp error = %v", err)
+		}
+
+		if err := store.TrashChirp(ctx, sqlc.TrashChirpParams{ID: trashed.ID, UserID: waltID}); err != nil {
+			t.Fatalf("TrashChirp() error = %v", err)
+		}
+		purged, err := store.PurgeTrashedChirps(ctx, longAgo)
+		if err != nil || purged != 0 {
+			t.Errorf("PurgeTrashedChirps() before retention = %d, %v, want 0", purged, err)
+		}
+		purged, err = store.PurgeTrashedChirps(ctx, sql.NullTime{Time: time.Now().Add(time.Hour), Valid: true})
+		if err != nil || purged != 2 {
+			t.Errorf("PurgeTrashedChirps() = %d, %v, want 2", purged, err)
+		}
+		if inTrash, _ := store.ListTrashedChirps(ctx, sqlc.ListTrashedChirpsParams{UserID: waltID, DeletedAt: longAgo}); len(inTrash) != 0 {
+			t.Errorf("ListTrashedChirps() after purge = %d chirps, want 0", len(inTrash))
+		}
+		if revisions, _ := store.ListChirpRevisions(ctx, trashed.ID); len(revisions) != 0 {
+			t.Errorf("ListChirpRevisions() after purge = %d revisions, want 0", len(revisions))
+		}
+	})
+
+	t.Run("Search", func(t *testing.T) {
+		store := newStore(t)
+		walt := createUser(t, store, "walt@example.com")
+		jesse := createUser(t, store, "jesse@example.com")
+		createChirp(t, store, "say my name", walt.ID)
+		chemistry := createChirp(t, store, "chemistry is the study of change", walt.ID)
+		science := createChirp(t, store, "science science chemistry", jesse.ID)
+
+		ascending, err := store.SearchChirpsAscending(ctx, sqlc.SearchChirpsAscendingParams{Query: "chemistry", Limit: 10})
+		if err != nil || len(ascending) != 2 {
+			t.Fatalf("SearchChirpsAscending() = %d rows, %v, want 2", len(ascending), err)
+		}
+		descending, err := store.SearchChirpsDescending(ctx, sqlc.SearchChirpsDescendingParams{Query: "chemistry", Limit: 10})
+		if err != nil || len(descending) != 2 || descending[0].ID != ascending[1].ID || descending[0].Rank < descending[1].Rank {
+			t.Fatalf("SearchChirpsDescending() = %+v, %v, want best rank first", descending, err)
+		}
+
+		after, err := store.SearchChirpsDescending(ctx, sqlc.SearchChirpsDescendingParams{
+			Query:           "chemistry",
+			CursorRank:      sql.NullFloat64{Float64: float64(descending[0].Rank), Valid: true},
+			CursorCreatedAt: sql.NullTime{Time: descending[0].CreatedAt, Valid: true},
+			CursorID:        uuid.NullUUID{UUID: descending[0].ID, Valid: true},
+			Limit:           10,
+		})
+		if err != nil || len(after) != 1 || after[0].ID != descending[1].ID {
+			t.Errorf("SearchChirpsDescending() past cursor = %d rows, %v, want 1", len(after), err)
+		}
+
+		byJesse, err := store.SearchChirpsDescending(ctx, sqlc.SearchChirpsDescendingParams{
+			Query:    "chemistry",
+			AuthorID: uuid.NullUUID{UUID: jesse.ID, Valid: true},
+			Limit:    10,
+		})
+		if err != nil || len(byJesse) != 1 || byJesse[0].ID != science.ID {
+			t.Errorf("SearchChirpsDescending() by author = %d rows, %v, want 1", len(byJesse), err)
+		}
+
+		phrase, err := store.ListChirpsAscending(ctx, sqlc.ListChirpsAscendingParams{
+			Query: sql.NullString{String: "study <-> of <-> change", Valid: true},
+			Limit: 10,
+		})
+		if err != nil || len(phrase) != 1 || phrase[0].ID != chemistry.ID {
+			t.Errorf("ListChirpsAscending() phrase query = %d chirps, %v, want 1", len(phrase), err)
+		}
+
+		none, err := store.ListChirpsAscending(ctx, sqlc.ListChirpsAscendingParams{
+			Query:         sql.NullString{String: "chem:*", Valid: true},
+			CreatedBefore: sql.NullTime{Time: walt.CreatedAt.Add(-time.Hour), Valid: true},
+			Limit:         10,
+		})
+		if err != nil || len(none) != 0 {
+			t.Errorf("ListChirpsAscending() before any chirp = %d chirps, %v, want 0", len(none), err)
+		}
+	})
+
+	t.Run("Revisions", func(t *testing.T) {
+		store := newStore(t)
+		walt := createUser(t, store, "walt@example.com")
+		jesse := createUser(t, store, "jesse@example.com")
+		chirp := createChirp(t, store, "first draft", walt.ID)
+		createChirp(t, store, "taken", walt.ID)
+
+		_, err := store.UpdateChirpBody(ctx, sqlc.UpdateChirpBodyParams{
+			ID:     chirp.ID,
+			UserID: uuid.NullUUID{UUID: jesse.ID, Valid: true},
+			Body:   "hijacked",
+		})
+		if !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("UpdateChirpBody() by non-author error = %v, want sql.ErrNoRows", err)
+		}
+		_, err = store.UpdateChirpBody(ctx, sqlc.UpdateChirpBodyParams{
+			ID:     chirp.ID,
+			UserID: uuid.NullUUID{UUID: walt.ID, Valid: true},
+			Body:   "taken",
+		})
+		if !IsUniqueViolation(err) {
+			t.Errorf("UpdateChirpBody() to a duplicate body error = %v, want a unique violation", err)
+		}
+
+		for _, body := range []string{"second draft", "final draft"} {
+			edited, err := store.UpdateChirpBody(ctx, sqlc.UpdateChirpBodyParams{
+				ID:     chirp.ID,
+				UserID: uuid.NullUUID{UUID: walt.ID, Valid: true},
+				Body:   body,
+			})
+			if err != nil || edited.Body != body || !edited.UpdatedAt.After(edited.CreatedAt) {
+				t.Fatalf("UpdateChirpBody() = %+v, %v", edited, err)
+			}
+		}
+
+		revisions, err := store.ListChirpRevisions(ctx, chirp.ID)
+		if err != nil || len(revisions) != 2 {
+			t.Fatalf("ListChirpRevisions() = %d revisions, %v, want 2", len(revisions), err)
+		}
+		if revisions[0].Body != "first draft" || revisions[1].Body != "second draft" || !revisions[0].CreatedAt.Equal(chirp.CreatedAt) {
+			t.Errorf("ListChirpRevisions() = %+v", revisions)
+		}
+
+	})
+
+	t.Run("Threads", func(t *testing.T) {
+		store := newStore(t)
+		walt := createUser(t, store, "walt@example.com")
+		waltID := uuid.NullUUID{UUID: walt.ID, Valid: true}
+		reply := func(t *testing.T, body string, parent sqlc.Chirp) sqlc.Chirp {
+			t.Helper()
+			chirp, err := store.CreateChirp(ctx, sqlc.CreateChirpParams{
+				Body:      body,
+				UserID:    waltID,
+				InReplyTo: uuid.NullUUID{UUID: parent.ID, Valid: true},
+			})
+			if err != nil || chirp.InReplyTo.UUID != parent.ID {
+				t.Fatalf("CreateChirp() reply = %+v, %v", chirp, err)
+			}
+			return chirp
+		}
+		root := createChirp(t, store, "root", walt.ID)
+		first := reply(t, "first", root)
+		nested := reply(t, "nested", first)
+		second := reply(t, "second", root)
+
+		_, err := store.CreateChirp(ctx, sqlc.CreateChirpParams{
+			Body:      "orphan",
+			UserID:    waltID,
+			InReplyTo: uuid.NullUUID{UUID: uuid.New(), Valid: true},
+		})
+		if err == nil {
+			t.Error("CreateChirp() replying to a missing chirp should fail")
+		}
+
+		ancestors, err := store.GetChirpAncestors(ctx, nested.ID)
+		if err != nil || len(ancestors) != 2 || ancestors[0].ID != root.ID || ancestors[1].ID != first.ID {
+			t.Errorf("GetChirpAncestors() = %+v, %v, want root then first", ancestors, err)
+		}
+		if ancestors, _ := store.GetChirpAncestors(ctx, root.ID); len(ancestors) != 0 {
+			t.Errorf("GetChirpAncestors() of a root = %d chirps, want 0", len(ancestors))
+		}
+
+		rootID := uuid.NullUUID{UUID: root.ID, Valid: true}
+		replies, err := store.ListRepliesAscending(ctx, sqlc.ListRepliesAscendingParams{InReplyTo: rootID, Limit: 10})
+		if err != nil || len(replies) != 2 || replies[0].ID != first.ID || replies[1].ID != second.ID {
+			t.Errorf("ListRepliesAscending() = %+v, %v", replies, err)
+		}
+		replies, _ = store.ListRepliesDescending(ctx, sqlc.ListRepliesDescendingParams{
+			InReplyTo:       rootID,
+			CursorCreatedAt: sql.NullTime{Time: second.CreatedAt, Valid: true},
+			CursorID:        uuid.NullUUID{UUID: second.ID, Valid: true},
+			Limit:           10,
+		})
+		if len(replies) != 1 || replies[0].ID != first.ID {
+			t.Errorf("ListRepliesDescending() after cursor = %+v, want first", replies)
+		}
+
+		descendants, err := store.GetChirpDescendants(ctx, sqlc.GetChirpDescendantsParams{RootIds: []uuid.UUID{first.ID, second.ID}, MaxDepth: 1})
+		if err != nil || len(descendants) != 1 || descendants[0].ID != nested.ID {
+			t.Errorf("GetChirpDescendants() = %+v, %v, want nested", descendants, err)
+		}
+		if descendants, _ := store.GetChirpDescendants(ctx, sqlc.GetChirpDescendantsParams{RootIds: []uuid.UUID{root.ID}, MaxDepth: 2}); len(descendants) != 3 {
+			t.Errorf("GetChirpDescendants() two levels = %d chirps, want 3", len(descendants))
+		}
+
+		countReplies := func(t *testing.T, ids ...uuid.UUID) map[uuid.UUID]int64 {
+			t.Helper()
+			rows, err := store.CountReplies(ctx, ids)
+			if err != nil {
+				t.Fatalf("CountReplies() error = %v", err)
+			}
+			counts := map[uuid.UUID]int64{}
+			for _, row := range rows {
+				counts[row.ChirpID.UUID] = row.ReplyCount
+			}
+			return counts
+		}
+		if counts := countReplies(t, root.ID, first.ID, nested.ID); counts[root.ID] != 2 || counts[first.ID] != 1 || counts[nested.ID] != 0 {
+			t.Errorf("CountReplies() = %v", counts)
+		}
+
+		for _, chirp := range []sqlc.Chirp{first, second} {
+			if err := store.TrashChirp(ctx, sqlc.TrashChirpParams{ID: chirp.ID, UserID: waltID}); err != nil {
+				t.Fatalf("TrashChirp() error = %v", err)
+			}
+		}
+		if counts := countReplies(t, root.ID); counts[root.ID] != 0 {
+			t.Errorf("CountReplies() after trashing replies = %v, want 0", counts)
+		}
+		replies, _ = store.ListRepliesAscending(ctx, sqlc.ListRepliesAscendingParams{InReplyTo: rootID, Limit: 10})
+		if len(replies) != 1 || replies[0].ID != first.ID {
+			t.Errorf("ListRepliesAscending() after trashing = %+v, want only first, which has replies", replies)
+		}
+
+		if _, err := store.PurgeTrashedChirps(ctx, sql.NullTime{Time: time.Now().Add(time.Hour), Valid: true}); err != nil {
+			t.Fatalf("PurgeTrashedChirps() error = %v", err)
+		}
+		orphaned, err := store.GetChirpByID(ctx, nested.ID)
+		if err != nil || orphaned.InReplyTo.Valid {
+			t.Errorf("GetChirpByID() reply to a purged chirp = %+v, %v, want no parent", orphaned, err)
+		}
+	})
+
+	t.Run("Likes", func(t *testing.T) {
+		store := newStore(t)
+		walt := createUser(t, store, "walt@example.com")
+		jesse := createUser(t, store, "jesse@example.com")
+		first := createChirp(t, store, "first", walt.ID)
+		second := createChirp(t, store, "second", walt.ID)
+
+		for _, like := range []sqlc.LikeChirpParams{
+			{UserID: jesse.ID, ChirpID: first.ID},
+			{UserID: jesse.ID, ChirpID: second.ID},
+			{UserID: jesse.ID, ChirpID: first.ID},
+			{UserID: walt.ID, ChirpID: first.ID},
+		} {
+			tick()
+			if err := store.LikeChirp(ctx, like); err != nil {
+				t.Fatalf("LikeChirp(%+v) error = %v", like, err)
+			}
+		}
+		if err := store.LikeChirp(ctx, sqlc.LikeChirpParams{UserID: jesse.ID, ChirpID: uuid.New()}); err == nil {
+			t.Error("LikeChirp() of a missing chirp should fail")
+		}
+
+		counts, err := store.CountLikes(ctx, []uuid.UUID{first.ID, second.ID})
+		if err != nil || len(counts) != 2 {
+			t.Fatalf("CountLikes() = %+v, %v", counts, err)
+		}
+		for _, row := range counts {
+			if want := map[uuid.UUID]int64{first.ID: 2, second.ID: 1}[row.ChirpID]; row.LikeCount != want {
+				t.Errorf("CountLikes() %v = %d, want %d", row.ChirpID, row.LikeCount, want)
+			}
+		}
+		liked, err := store.ListLikedChirpIDs(ctx, sqlc.ListLikedChirpIDsParams{UserID: walt.ID, ChirpIds: []uuid.UUID{first.ID, second.ID}})
+		if err != nil || len(liked) != 1 || liked[0] != first.ID {
+			t.Errorf("ListLikedChirpIDs() = %v, %v, want only first", liked, err)
+		}
+
+		likes, err := store.ListUserLikesAscending(ctx, sqlc.ListUserLikesAscendingParams{UserID: jesse.ID, Limit: 10})
+		if err != nil || len(likes) != 2 || likes[0].Chirp.ID != first.ID || likes[1].Chirp.ID != second.ID {
+			t.Fatalf("ListUserLikesAscending() = %+v, %v", likes, err)
+		}
+		newest, _ := store.ListUserLikesDescending(ctx, sqlc.ListUserLikesDescendingParams{UserID: jesse.ID, Limit: 1})
+		if len(newest) != 1 || newest[0].Chirp.ID != second.ID || !newest[0].LikedAt.Equal(likes[1].LikedAt) {
+			t.Errorf("ListUserLikesDescending() = %+v, want the second like", newest)
+		}
+
+		if err := store.UnlikeChirp(ctx, sqlc.UnlikeChirpParams{UserID: jesse.ID, ChirpID: second.ID}); err != nil {
+			t.Fatalf("UnlikeChirp() error = %v", err)
+		}
+		if err := store.TrashChirp(ctx, sqlc.TrashChirpParams{ID: first.ID, UserID: uuid.NullUUID{UUID: walt.ID, Valid: true}}); err != nil {
+			t.Fatalf("TrashChirp() error = %v", err)
+		}
+		if likes, _ := store.ListUserLikesAscending(ctx, sqlc.ListUserLikesAscendingParams{UserID: jesse.ID, Limit: 10}); len(likes) != 0 {
+			t.Errorf("ListUserLikesAscending() after unliking and trashing = %d chirps, want 0", len(likes))
+		}
+
+		if _, err := store.PurgeTrashedChirps(ctx, sql.NullTime{Time: time.Now().Add(time.Hour), Valid: true}); err != nil {
+			t.Fatalf("PurgeTrashedChirps() error = %v", err)
+		}
+		if counts, _ := store.CountLikes(ctx, []uuid.UUID{first.ID}); len(counts) != 0 {
+			t.Errorf("CountLikes() after purge = %+v, want none", counts)
+		}
+	})
+
+	t.Run("Shares", func(t *testing.T) {
+		store := newStore(t)
+		walt := createUser(t, store, "walt@example.com")
+		jesse := createUser(t, store, "jesse@example.com")
+		jesseID := uuid.NullUUID{UUID: jesse.ID, Valid: true}
+		original := createChirp(t, store, "say my name", walt.ID)
+		originalID := uuid.NullUUID{UUID: original.ID, Valid: true}
+
+		rechirp, err := store.CreateRechirp(ctx, sqlc.CreateRechirpParams{UserID: jesseID, RechirpOf: originalID})
+		if err != nil || rechirp.RechirpOf != originalID || rechirp.Body != "" {
+			t.Fatalf("CreateRechirp() = %+v, %v", rechirp, err)
+		}
+		if _, err := store.CreateRechirp(ctx, sqlc.CreateRechirpParams{UserID: jesseID, RechirpOf: originalID}); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("CreateRechirp() of the same chirp twice error = %v, want sql.ErrNoRows", err)
+		}
+		if _, err := store.CreateChirp(ctx, sqlc.CreateChirpParams{UserID: jesseID, RechirpOf: originalID}); err == nil {
+			t.Error("CreateChirp() rechirping the same chirp twice should fail")
+		}
+		waltRechirp, err := store.CreateRechirp(ctx, sqlc.CreateRechirpParams{
+			UserID:    uuid.NullUUID{UUID: walt.ID, Valid: true},
+			RechirpOf: originalID,
+		})
+		if err != nil {
+			t.Errorf("CreateRechirp() second user's rechirp error = %v", err)
+		}
+		quote, err := store.CreateChirp(ctx, sqlc.CreateChirpParams{Body: "heisenberg", UserID: jesseID, QuoteOf: originalID})
+		if err != nil || quote.QuoteOf != originalID {
+			t.Fatalf("CreateChirp() quote = %+v, %v", quote, err)
+		}
+
+		counts, err := store.CountShares(ctx, []uuid.UUID{original.ID, quote.ID})
+		if err != nil || len(counts) != 1 || counts[0].ChirpID != originalID || counts[0].RechirpCount != 2 || counts[0].QuoteCount != 1 {
+			t.Errorf("CountShares() = %+v, %v, want 2 rechirps and 1 quote", counts, err)
+		}
+
+		if err := store.DeleteRechirp(ctx, sqlc.DeleteRechirpParams{ID: quote.ID, UserID: jesseID}); err != nil {
+			t.Fatalf("DeleteRechirp() error = %v", err)
+		}
+		if _, err := store.GetChirpByID(ctx, quote.ID); err != nil {
+			t.Errorf("DeleteRechirp() of a quote removed it: %v", err)
+		}
+		if err := store.DeleteRechirp(ctx, sqlc.DeleteRechirpParams{ID: rechirp.ID, UserID: jesseID}); err != nil {
+			t.Fatalf("DeleteRechirp() error = %v", err)
+		}
+		if _, err := store.GetChirpByID(ctx, rechirp.ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetChirpByID() after DeleteRechirp() error = %v, want sql.ErrNoRows", err)
+		}
+
+		waltID := uuid.NullUUID{UUID: walt.ID, Valid: true}
+		if err := store.TrashChirp(ctx, sqlc.TrashChirpParams{ID: original.ID, UserID: waltID}); err != nil {
+			t.Fatalf("TrashChirp() error = %v", err)
+		}
+		if _, err := store.PurgeTrashedChirps(ctx, sql.NullTime{Time: time.Now().Add(time.Hour), Valid: true}); err != nil {
+			t.Fatalf("PurgeTrashedChirps() error = %v", err)
+		}
+		if _, err := store.GetChirpByID(ctx, waltRechirp.ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetChirpByID() rechirp of a purged chirp error = %v, want sql.ErrNoRows", err)
+		}
+		quote, err = store.GetChirpByID(ctx, quote.ID)
+		if err != nil || quote.QuoteOf.Valid {
+			t.Errorf("GetChirpByID() quote of a purged chirp = %+v, %v, want it kept without quote_of", quote, err)
+		}
+	})
+
+	t.Run("Follows", func(t *testing.T) {
+		store := newStore(t)
+		walt := createUser(t, store, "walt@example.com")
+		jesse := createUser(t, store, "jesse@example.com")
+		skyler := createUser(t, store, "skyler@example.com")
+
+		for _, follow := range []sqlc.FollowUserParams{
+			{FollowerID: jesse.ID, FolloweeID: walt.ID},
+			{FollowerID: skyler.ID, FolloweeID: walt.ID},
+			{FollowerID: jesse.ID, FolloweeID: walt.ID},
+			{FollowerID: walt.ID, FolloweeID: skyler.ID},
+		} {
+			tick()
+			if err := store.FollowUser(ctx, follow); err != nil {
+				t.Fatalf("FollowUser(%+v) error = %v", follow, err)
+			}
+		}
+		if err := store.FollowUser(ctx, sqlc.FollowUserParams{FollowerID: walt.ID, FolloweeID: walt.ID}); err == nil {
+			t.Error("FollowUser() of yourself should fail")
+		}
+
+		followers, err := store.ListFollowersAscending(ctx, sqlc.ListFollowersAscendingParams{FolloweeID: walt.ID, Limit: 10})
+		if err != nil || len(followers) != 2 || followers[0].FollowerID != jesse.ID || followers[1].FollowerID != skyler.ID {
+			t.Errorf("ListFollowersAscending() = %+v, %v, want jesse then skyler", followers, err)
+		}
+		followers, _ = store.ListFollowersDescending(ctx, sqlc.ListFollowersDescendingParams{
+			FolloweeID:      walt.ID,
+			CursorCreatedAt: sql.NullTime{Time: followers[1].CreatedAt, Valid: true},
+			CursorID:        uuid.NullUUID{UUID: skyler.ID, Valid: true},
+			Limit:           10,
+		})
+		if len(followers) != 1 || followers[0].FollowerID != jesse.ID {
+			t.Errorf("ListFollowersDescending() after cursor = %+v, want jesse", followers)
+		}
+		following, err := store.ListFollowingAscending(ctx, sqlc.ListFollowingAscendingParams{FollowerID: walt.ID, Limit: 10})
+		if err != nil || len(following) != 1 || following[0].FolloweeID != skyler.ID {
+			t.Errorf("ListFollowingAscending() = %+v, %v, want skyler", following, err)
+		}
+
+		mine := createChirp(t, store, "mine", jesse.ID)
+		followed := createChirp(t, store, "followed", walt.ID)
+		createChirp(t, store, "not followed", skyler.ID)
+		timeline, err := store.ListTimelineDescending(ctx, sqlc.ListTimelineDescendingParams{ViewerID: jesse.ID, Limit: 10})
+		if err != nil || len(timeline) != 2 || timeline[0].ID != followed.ID || timeline[1].ID != mine.ID {
+			t.Errorf("ListTimelineDescending() = %+v, %v, want followed then mine", timeline, err)
+		}
+
+		if err := store.UnfollowUser(ctx, sqlc.UnfollowUserParams{FollowerID: jesse.ID, FolloweeID: walt.ID}); err != nil {
+			t.Fatalf("UnfollowUser() error = %v", err)
+		}
+		timeline, _ = store.ListTimelineAscending(ctx, sqlc.ListTimelineAscendingParams{ViewerID: jesse.ID, Limit: 10})
+		if len(timeline) != 1 || timeline[0].ID != mine.ID {
+			t.Errorf("ListTimelineAscending() after unfollowing = %+v, want only mine", timeline)
+		}
+	})
+
+	t.Run("Profiles", func(t *testing.T) {
+		store := newStore(t)
+		walt := createUser(t, store, "walt@example.com")
+		jesse := createUser(t, store, "jesse@example.com")
+
+		updated, err := store.UpdateUserProfile(ctx, sqlc.UpdateUserProfileParams{
+			ID:          walt.ID,
+			Handle:      sql.NullString{String: "Heisenberg", Valid: true},
+			DisplayName: "Walter White",
+			Bio:         "Chemistry teacher",
+		})
+		if err != nil || updated.Handle.String != "Heisenberg" || updated.DisplayName != "Walter White" {
+			t.Fatalf("UpdateUserProfile() = %+v, %v", updated, err)
+		}
+		found, err := store.GetUserByHandle(ctx, "heisenBERG")
+		if err != nil || found.ID != walt.ID {
+			t.Errorf("GetUserByHandle() in another case = %+v, %v, want walt", found, err)
+		}
+		_, err = store.UpdateUserProfile(ctx, sqlc.UpdateUserProfileParams{ID: jesse.ID, Handle: sql.NullString{String: "HEISENBERG", Valid: true}})
+		if err == nil {
+			t.Error("UpdateUserProfile() to a handle that differs only in case should fail")
+		}
+
+		createChirp(t, store, "say my name", walt.ID)
+		store.FollowUser(ctx, sqlc.FollowUserParams{FollowerID: jesse.ID, FolloweeID: walt.ID})
+		stats, err := store.GetUserStats(ctx, walt.ID)
+		if err != nil || stats.ChirpCount != 1 || stats.FollowerCount != 1 || stats.FollowingCount != 0 {
+			t.Errorf("GetUserStats() = %+v, %v, want 1 chirp and 1 follower", stats, err)
+		}
+
+		waltID := uuid.NullUUID{UUID: walt.ID, Valid: true}
+		if err := store.ReleaseHandle(ctx, sqlc.ReleaseHandleParams{Handle: "Heisenberg", UserID: waltID}); err != nil {
+			t.Fatalf("ReleaseHandle() error = %v", err)
+		}
+		if err := store.ReleaseHandle(ctx, sqlc.ReleaseHandleParams{Handle: "heisenberg", UserID: waltID}); err != nil {
+			t.Fatalf("ReleaseHandle() again error = %v", err)
+		}
+		released, err := store.GetReleasedHandle(ctx, "HeisenBerg")
+		if err != nil || released.Handle != "heisenberg" || released.UserID != waltID {
+			t.Errorf("GetReleasedHandle() = %+v, %v, want it held for walt", released, err)
+		}
+		if err := store.DeleteReleasedHandle(ctx, "Heisenberg"); err != nil {
+			t.Fatalf("DeleteReleasedHandle() error = %v", err)
+		}
+		if _, err := store.GetReleasedHandle(ctx, "heisenberg"); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetReleasedHandle() after deleting error = %v, want sql.ErrNoRows", err)
+		}
+	})
+
+	t.Run("AccountDeletion", func(t *testing.T) {
+		store := newStore(t)
+		walt := createUser(t, store, "walt@example.com")
+		jesse := createUser(t, store, "jesse@example.com")
+		waltID := uuid.NullUUID{UUID: walt.ID, Valid: true}
+		jesseID := uuid.NullUUID{UUID: jesse.ID, Valid: true}
+
+		waltChirp := createChirp(t, store, "say my name", walt.ID)
+		jesseChirp := createChirp(t, store, "yeah science", jesse.ID)
+		store.LikeChirp(ctx, sqlc.LikeChirpParams{UserID: walt.ID, ChirpID: jesseChirp.ID})
+		store.LikeChirp(ctx, sqlc.LikeChirpParams{UserID: jesse.ID, ChirpID: waltChirp.ID})
+		store.FollowUser(ctx, sqlc.FollowUserParams{FollowerID: jesse.ID, FolloweeID: walt.ID})
+		waltSession := createSession(t, store, walt.ID)
+		store.StoreRefresh(ctx, sqlc.StoreRefreshParams{TokenHash: "walt-token", UserID: waltID, ExpiresAt: time.Now().Add(time.Hour), FamilyID: waltSession.ID})
+		store.StoreRefresh(ctx, sqlc.StoreRefreshParams{TokenHash: "jesse-token", UserID: jesseID, ExpiresAt: time.Now().Add(time.Hour), FamilyID: createSession(t, store, jesse.ID).ID})
+		store.ReleaseHandle(ctx, sqlc.ReleaseHandleParams{Handle: "heisenberg", UserID: waltID})
+
+		requested, err := store.RequestUserDeletion(ctx, walt.ID)
+		if err != nil || !requested.DeletionRequestedAt.Valid {
+			t.Fatalf("RequestUserDeletion() = %+v, %v", requested, err)
+		}
+		again, err := store.RequestUserDeletion(ctx, walt.ID)
+		if err != nil || !again.DeletionRequestedAt.Time.Equal(requested.DeletionRequestedAt.Time) {
+			t.Errorf("RequestUserDeletion() again = %+v, %v, want the first request time kept", again, err)
+		}
+		if err := store.CancelUserDeletion(ctx, walt.ID); err != nil {
+			t.Fatalf("CancelUserDeletion() error = %v", err)
+		}
+		if user, _ := store.GetUserByID(ctx, walt.ID); user.DeletionRequestedAt.Valid {
+			t.Errorf("GetUserByID() after cancelling = %+v, want no deletion requested", user)
+		}
+		store.RequestUserDeletion(ctx, walt.ID)
+
+		if err := store.RevokeUserRefreshTokens(ctx, waltID); err != nil {
+			t.Fatalf("RevokeUserRefreshTokens() error = %v", err)
+		}
+		if got, err := store.GetRefreshToken(ctx, "walt-token"); err != nil || !got.RevokedAt.Valid {
+			t.Errorf("GetRefreshToken() after RevokeUserRefreshTokens() = %+v, %v, want revoked", got, err)
+		}
+		if got, err := store.GetRefreshToken(ctx, "jesse-token"); err != nil || got.RevokedAt.Valid {
+			t.Errorf("GetRefreshToken() someone else's token = %+v, %v, want it live", got, err)
+		}
+
+		purged, err := store.PurgeDeletedUsers(ctx, sql.NullTime{Time: time.Now().Add(-time.Hour), Valid: true})
+		if err != nil || len(purged) != 0 {
+			t.Errorf("PurgeDeletedUsers() within the grace period = %d users, %v, want 0", len(purged), err)
+		}
+		purged, err = store.PurgeDeletedUsers(ctx, sql.NullTime{Time: time.Now().Add(time.Hour), Valid: true})
+		if err != nil || len(purged) != 1 || purged[0].ID != walt.ID {
+			t.Fatalf("PurgeDeletedUsers() = %+v, %v, want walt", purged, err)
+		}
+
+		if _, err := store.GetUserByID(ctx, walt.ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetUserByID() purged user error = %v, want sql.ErrNoRows", err)
+		}
+		if _, err := store.GetChirpByID(ctx, waltChirp.ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetChirpByID() purged user's chirp error = %v, want sql.ErrNoRows", err)
+		}
+		likes, _ := store.CountLikes(ctx, []uuid.UUID{jesseChirp.ID})
+		if len(likes) != 0 {
+			t.Errorf("CountLikes() after purge = %+v, want the purged user's like gone", likes)
+		}
+		if stats, _ := store.GetUserStats(ctx, jesse.ID); stats.ChirpCount != 1 || stats.FollowingCount != 0 {
+			t.Errorf("GetUserStats() after purge = %+v, want jesse's chirp kept and follow gone", stats)
+		}
+		if released, err := store.GetReleasedHandle(ctx, "heisenberg"); err != nil || released.UserID.Valid {
+			t.Errorf("GetReleasedHandle() after purge = %+v, %v, want it kept without an owner", released, err)
+		}
+		if _, err := store.GetSession(ctx, waltSession.ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetSession() purged user's session error = %v, want sql.ErrNoRows", err)
+		}
+	})
+
+	t.Run("Exports", func(t *testing.T) {
+		store := newStore(t)
+		walt := createUser(t, store, "walt@example.com")
+		waltID := uuid.NullUUID{UUID: walt.ID, Valid: true}
+
+		export, err := store.CreateExport(ctx, walt.ID)
+		if err != nil || export.Status != "pending" || export.CompletedAt.Valid {
+			t.Fatalf("CreateExport() = %+v, %v", export, err)
+		}
+		claimed, err := store.ClaimExport(ctx, sql.NullTime{Time: time.Now().Add(-time.Hour), Valid: true})
+		if err != nil || claimed.ID != export.ID || !claimed.ClaimedAt.Valid {
+			t.Fatalf("ClaimExport() = %+v, %v, want the pending export", claimed, err)
+		}
+		if _, err := store.ClaimExport(ctx, sql.NullTime{Time: time.Now().Add(-time.Hour), Valid: true}); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("ClaimExport() of a freshly claimed export error = %v, want sql.ErrNoRows", err)
+		}
+		if reclaimed, err := store.ClaimExport(ctx, sql.NullTime{Time: time.Now().Add(time.Hour), Valid: true}); err != nil || reclaimed.ID != export.ID {
+			t.Errorf("ClaimExport() of a stale claim = %+v, %v, want the export again", reclaimed, err)
+		}
+		if err := store.CompleteExport(ctx, sqlc.CompleteExportParams{ID: export.ID, Status: "lost"}); err == nil {
+			t.Error("CompleteExport() with an unknown status should fail")
+		}
+		err = store.CompleteExport(ctx, sqlc.CompleteExportParams{ID: export.ID, Status: "ready", Archive: []byte("zip")})
+		if err != nil {
+			t.Fatalf("CompleteExport() error = %v", err)
+		}
+		got, err := store.GetExport(ctx, sqlc.GetExportParams{ID: export.ID, UserID: walt.ID})
+		if err != nil || got.Status != "ready" || string(got.Archive) != "zip" || !got.CompletedAt.Valid {
+			t.Errorf("GetExport() = %+v, %v", got, err)
+		}
+		if _, err := store.ClaimExport(ctx, sql.NullTime{Time: time.Now().Add(time.Hour), Valid: true}); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("ClaimExport() with only a ready export error = %v, want sql.ErrNoRows", err)
+		}
+		if _, err := store.GetExport(ctx, sqlc.GetExportParams{ID: export.ID, UserID: uuid.New()}); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetExport() for another user error = %v, want sql.ErrNoRows", err)
+		}
+		newer, _ := store.CreateExport(ctx, walt.ID)
+		if latest, err := store.GetLatestExport(ctx, walt.ID); err != nil || latest.ID != newer.ID {
+			t.Errorf("GetLatestExport() = %+v, %v, want %s", latest, err, newer.ID)
+		}
+		purged, err := store.PurgeExports(ctx, time.Now().Add(time.Hour))
+		if err != nil || purged != 2 {
+			t.Errorf("PurgeExports() = %d, %v, want 2", purged, err)
+		}
+
+		postedAt := time.Date(2008, 1, 20, 21, 0, 0, 0, time.UTC)
+		imported, err := store.ImportChirp(ctx, sqlc.ImportChirpParams{
+			CreatedAt: postedAt,
+			UpdatedAt: postedAt.Add(time.Hour),
+			Body:      "say my name",
+			UserID:    waltID,
+		})
+		if err != nil || !imported.CreatedAt.Equal(postedAt) || !imported.UpdatedAt.Equal(postedAt.Add(time.Hour)) {
+			t.Fatalf("ImportChirp() = %+v, %v, want the given timestamps", imported, err)
+		}
+		_, err = store.ImportChirp(ctx, sqlc.ImportChirpParams{CreatedAt: postedAt, UpdatedAt: postedAt, Body: "say my name", UserID: waltID})
+		if !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("ImportChirp() with a duplicate body error = %v, want sql.ErrNoRows", err)
+		}
+		jesse := createUser(t, store, "jesse@example.com")
+		jesseID := uuid.NullUUID{UUID: jesse.ID, Valid: true}
+		if _, err := store.ImportChirp(ctx, sqlc.ImportChirpParams{CreatedAt: postedAt, UpdatedAt: postedAt, Body: "say my name", UserID: jesseID}); err != nil {
+			t.Errorf("ImportChirp() with another user's body error = %v", err)
+		}
+		rechirpParams := sqlc.ImportRechirpParams{
+			CreatedAt: postedAt,
+			UpdatedAt: postedAt,
+			UserID:    jesseID,
+			RechirpOf: uuid.NullUUID{UUID: imported.ID, Valid: true},
+		}
+		rechirp, err := store.ImportRechirp(ctx, rechirpParams)
+		if err != nil || rechirp.RechirpOf.UUID != imported.ID || !rechirp.CreatedAt.Equal(postedAt) {
+			t.Errorf("ImportRechirp() = %+v, %v, want a rechirp with the given timestamps", rechirp, err)
+		}
+		if _, err := store.ImportRechirp(ctx, rechirpParams); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("ImportRechirp() of the same chirp twice error = %v, want sql.ErrNoRows", err)
+		}
+		later := createChirp(t, store, "tread lightly", walt.ID)
+		chirps, err := store.ListUserChirps(ctx, waltID)
+		if err != nil || len(chirps) != 2 || chirps[0].ID != imported.ID || chirps[1].ID != later.ID {
+			t.Errorf("ListUserChirps() = %+v, %v, want the imported chirp first", chirps, err)
+		}
+	})
+
+	t.Run("Hashtags", func(t *testing.T) {
+		store := newStore(t)
+		user := createUser(t, store, "walt@example.com")
+		first := createChirp(t, store, "#go first", user.ID)
+		second := createChirp(t, store, "#go second", user.ID)
+		other := createChirp(t, store, "#rust", user.ID)
+		for _, chirp := range []sqlc.Chirp{first, second} {
+			if err := store.SetChirpHashtags(ctx, sqlc.SetChirpHashtagsParams{ChirpID: chirp.ID, Tags: []string{"go", "go"}}); err != nil {
+				t.Fatalf("SetChirpHashtags() error = %v", err)
+			}
+		}
+		store.SetChirpHashtags(ctx, sqlc.SetChirpHashtagsParams{ChirpID: other.ID, Tags: []string{"rust"}})
+		if err := store.SetChirpMentions(ctx, sqlc.SetChirpMentionsParams{ChirpID: first.ID, Names: []string{"jesse"}}); err != nil {
+			t.Fatalf("SetChirpMentions() error = %v", err)
+		}
+
+		chirps, err := store.ListHashtagChirpsAscending(ctx, sqlc.ListHashtagChirpsAscendingParams{Tag: "go", Limit: 10})
+		if err != nil || len(chirps) != 2 || chirps[0].ID != first.ID || chirps[1].ID != second.ID {
+			t.Errorf("ListHashtagChirpsAscending() = %+v, %v, want first then second", chirps, err)
+		}
+		chirps, _ = store.ListHashtagChirpsDescending(ctx, sqlc.ListHashtagChirpsDescendingParams{
+			Tag:             "go",
+			CursorCreatedAt: sql.NullTime{Time: second.CreatedAt, Valid: true},
+			CursorID:        uuid.NullUUID{UUID: second.ID, Valid: true},
+			Limit:           10,
+		})
+		if len(chirps) != 1 || chirps[0].ID != first.ID {
+			t.Errorf("ListHashtagChirpsDescending() after cursor = %+v, want first", chirps)
+		}
+
+		if err := store.DeleteChirpHashtags(ctx, first.ID); err != nil {
+			t.Fatalf("DeleteChirpHashtags() error = %v", err)
+		}
+		store.TrashChirp(ctx, sqlc.TrashChirpParams{ID: second.ID, UserID: second.UserID})
+		chirps, _ = store.ListHashtagChirpsAscending(ctx, sqlc.ListHashtagChirpsAscendingParams{Tag: "go", Limit: 10})
+		if len(chirps) != 0 {
+			t.Errorf("ListHashtagChirpsAscending() after untagging and trashing = %+v, want none", chirps)
+		}
+		if err := store.DeleteChirpMentions(ctx, first.ID); err != nil {
+			t.Errorf("DeleteChirpMentions() error = %v", err)
+		}
+	})
+
+	t.Run("FilterTerms", func(t *testing.T) {
+		store := newStore(t)
+		// filter_terms is seeded by its migration rather than truncated, so
+		// the test uses a term nobody else will have added.
+		word := "term" + strings.ReplaceAll(uuid.NewString(), "-", "")
+
+		created, err := store.CreateFilterTerm(ctx, sqlc.CreateFilterTermParams{Term: word, Action: "flag"})
+		if err != nil {
+			t.Fatalf("CreateFilterTerm() error = %v", err)
+		}
+		t.Cleanup(func() { store.DeleteFilterTerm(ctx, created.ID) })
+		if _, err := store.CreateFilterTerm(ctx, sqlc.CreateFilterTermParams{Term: word, Action: "mask"}); err == nil {
+			t.Error("CreateFilterTerm() of a duplicate term should fail")
+		}
+		if _, err := store.CreateFilterTerm(ctx, sqlc.CreateFilterTermParams{Term: word + "x", Action: "delete"}); err == nil {
+			t.Error("CreateFilterTerm() with an unknown action should fail")
+		}
+
+		terms, err := store.ListFilterTerms(ctx)
+		if err != nil || !slices.ContainsFunc(terms, func(term sqlc.FilterTerm) bool { return term.ID == created.ID }) {
+			t.Errorf("ListFilterTerms() = %+v, %v, want it to include %s", terms, err, word)
+		}
+		updated, err := store.UpdateFilterTerm(ctx, sqlc.UpdateFilterTermParams{ID: created.ID, Action: "reject"})
+		if err != nil || updated.Action != "reject" {
+			t.Errorf("UpdateFilterTerm() = %+v, %v, want action reject", updated, err)
+		}
+		if _, err := store.UpdateFilterTerm(ctx, sqlc.UpdateFilterTermParams{ID: uuid.New(), Action: "mask"}); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("UpdateFilterTerm() of a missing term error = %v, want sql.ErrNoRows", err)
+		}
+
+		user := createUser(t, store, "walt@example.com")
+		chirp := createChirp(t, store, "flagged", user.ID)
+		trashed := createChirp(t, store, "flagged and trashed", user.ID)
+		for _, flag := range []sqlc.FlagChirpParams{
+			{ChirpID: chirp.ID, Term: word},
+			{ChirpID: chirp.ID, Term: word},
+			{ChirpID: trashed.ID, Term: word},
+		} {
+			if err := store.FlagChirp(ctx, flag); err != nil {
+				t.Fatalf("FlagChirp(%+v) error = %v", flag, err)
+			}
+		}
+		store.TrashChirp(ctx, sqlc.TrashChirpParams{ID: trashed.ID, UserID: trashed.UserID})
+		flagged, err := store.ListFlaggedChirps(ctx)
+		if err != nil || len(flagged) != 1 || flagged[0].Chirp.ID != chirp.ID || flagged[0].Term != word {
+			t.Errorf("ListFlaggedChirps() = %+v, %v, want the untrashed chirp once", flagged, err)
+		}
+		if cleared, err := store.ClearChirpFlags(ctx, chirp.ID); err != nil || cleared != 1 {
+			t.Errorf("ClearChirpFlags() = %d, %v, want 1", cleared, err)
+		}
+
+		if deleted, err := store.DeleteFilterTerm(ctx, created.ID); err != nil || deleted != 1 {
+			t.Errorf("DeleteFilterTerm() = %d, %v, want 1", deleted, err)
+		}
+	})
+
+	t.Run("RefreshTokens", func(t *testing.T) {
+		store := newStore(t)
+		user := createUser(t, store, "walt@example.com")
+		userID := uuid.NullUUID{UUID: user.ID, Valid: true}
+		session := createSession(t, store, user.ID)
+
+		err := store.StoreRefresh(ctx, sqlc.StoreRefreshParams{TokenHash: "live", UserID: userID, ExpiresAt: time.Now().Add(time.Hour), FamilyID: session.ID})
+		if err != nil {
+			t.Fatalf("StoreRefresh() error = %v", err)
+		}
+		err = store.StoreRefresh(ctx, sqlc.StoreRefreshParams{TokenHash: "expired", UserID: userID, ExpiresAt: time.Now().Add(-time.Hour), FamilyID: session.ID})
+		if err != nil {
+			t.Fatalf("StoreRefresh() error = %v", err)
+		}
+		if err := store.StoreRefresh(ctx, sqlc.StoreRefreshParams{TokenHash: "live", UserID: userID, ExpiresAt: time.Now(), FamilyID: session.ID}); err == nil {
+			t.Error("StoreRefresh() with duplicate token should fail")
+		}
+		if err := store.StoreRefresh(ctx, sqlc.StoreRefreshParams{TokenHash: "orphan", UserID: userID, ExpiresAt: time.Now(), FamilyID: uuid.New()}); err == nil {
+			t.Error("StoreRefresh() without a session should fail")
+		}
+
+		got, err := store.GetRefreshToken(ctx, "live")
+		if err != nil || got.UserID != userID || got.RevokedAt.Valid {
+			t.Errorf("GetRefreshToken() = %+v, %v, want a live token of %v", got, err, userID)
+		}
+		if _, err := store.GetRefreshToken(ctx, "unknown"); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetRefreshToken() unknown token error = %v, want sql.ErrNoRows", err)
+		}
+
+		if err := store.RevokeRefresh(ctx, sqlc.RevokeRefreshParams{TokenHash: "live", UpdatedAt: time.Now()}); err != nil {
+			t.Errorf("RevokeRefresh() error = %v", err)
+		}
+		if got, _ := store.GetRefreshToken(ctx, "live"); !got.RevokedAt.Valid {
+			t.Errorf("GetRefreshToken() after RevokeRefresh() = %+v, want revoked", got)
+		}
+	})
+
+	t.Run("RefreshRotation", func(t *testing.T) {
+		store := newStore(t)
+		user := createUser(t, store, "walt@example.com")
+		userID := uuid.NullUUID{UUID: user.ID, Valid: true}
+		family, otherFamily := createSession(t, store, user.ID).ID, createSession(t, store, user.ID).ID
+		for _, params := range []sqlc.StoreRefreshParams{
+			{TokenHash: "first", UserID: userID, ExpiresAt: time.Now().Add(time.Hour), FamilyID: family},
+			{TokenHash: "second", UserID: userID, ExpiresAt: time.Now().Add(time.Hour), FamilyID: family},
+			{TokenHash: "other", UserID: userID, ExpiresAt: time.Now().Add(time.Hour), FamilyID: otherFamily},
+		} {
+			if err := store.StoreRefresh(ctx, params); err != nil {
+				t.Fatalf("StoreRefresh() error = %v", err)
+			}
+		}
+
+		got, err := store.GetRefreshToken(ctx, "first")
+		if err != nil || got.FamilyID != family || got.RotatedAt.Valid {
+			t.Errorf("GetRefreshToken() = %+v, %v", got, err)
+		}
+		if rotated, err := store.RotateRefresh(ctx, "first"); err != nil || rotated != 1 {
+			t.Errorf("RotateRefresh() = %d, %v, want 1", rotated, err)
+		}
+		if rotated, err := store.RotateRefresh(ctx, "first"); err != nil || rotated != 0 {
+			t.Errorf("RotateRefresh() again = %d, %v, want 0", rotated, err)
+		}
+		if got, _ := store.GetRefreshToken(ctx, "first"); !got.RotatedAt.Valid {
+			t.Errorf("GetRefreshToken() after rotating = %+v, want rotated_at set", got)
+		}
+
+		if err := store.RevokeRefreshFamily(ctx, family); err != nil {
+			t.Fatalf("RevokeRefreshFamily() error = %v", err)
+		}
+		if got, _ := store.GetRefreshToken(ctx, "second"); !got.RevokedAt.Valid {
+			t.Errorf("GetRefreshToken() after revoking the family = %+v, want revoked", got)
+		}
+		if rotated, _ := store.RotateRefresh(ctx, "second"); rotated != 0 {
+			t.Errorf("RotateRefresh() revoked token = %d, want 0", rotated)
+		}
+		if got, err := store.GetRefreshToken(ctx, "other"); err != nil || got.RevokedAt.Valid {
+			t.Errorf("GetRefreshToken() token in another family = %+v, %v, want it live", got, err)
+		}
+	})
+
+	t.Run("RevokedAccessTokens", func(t *testing.T) {
+		store := newStore(t)
+		user := createUser(t, store, "walt@example.com")
+		live, expired := uuid.New(), uuid.New()
+
+		if revoked, err := store.IsAccessTokenRevoked(ctx, live); err != nil || revoked {
+			t.Errorf("IsAccessTokenRevoked() before revoking = %v, %v, want false", revoked, err)
+		}
+		if err := store.RevokeAccessToken(ctx, sqlc.RevokeAccessTokenParams{JTI: uuid.New(), UserID: uuid.New(), ExpiresAt: time.Now()}); err == nil {
+			t.Error("RevokeAccessToken() for a missing user should fail")
+		}
+		for _, params := range []sqlc.RevokeAccessTokenParams{
+			{JTI: live, UserID: user.ID, ExpiresAt: time.Now().Add(time.Hour)},
+			{JTI: live, UserID: user.ID, ExpiresAt: time.Now().Add(time.Hour)},
+			{JTI: expired, UserID: user.ID, ExpiresAt: time.Now().Add(-time.Hour)},
+		} {
+			if err := store.RevokeAccessToken(ctx, params); err != nil {
+				t.Fatalf("RevokeAccessToken() error = %v", err)
+			}
+		}
+		if revoked, err := store.IsAccessTokenRevoked(ctx, live); err != nil || !revoked {
+			t.Errorf("IsAccessTokenRevoked() = %v, %v, want true", revoked, err)
+		}
+
+		purged, err := store.PurgeRevokedAccessTokens(ctx, time.Now())
+		if err != nil || purged != 1 {
+			t.Errorf("PurgeRevokedAccessTokens() = %d, %v, want 1", purged, err)
+		}
+		if revoked, _ := store.IsAccessTokenRevoked(ctx, expired); revoked {
+			t.Error("IsAccessTokenRevoked() after purging = true, want the expired token gone")
+		}
+		if revoked, _ := store.IsAccessTokenRevoked(ctx, live); !revoked {
+			t.Error("IsAccessTokenRevoked() after purging = false, want the live token kept")
+		}
+	})
+
+	t.Run("Sessions", func(t *testing.T) {
+		store := newStore(t)
+		walt := createUser(t, store, "walt@example.com")
+		jesse := createUser(t, store, "jesse@example.com")
+
+		if _, err := store.CreateSession(ctx, sqlc.CreateSessionParams{UserID: uuid.New(), ExpiresAt: time.Now()}); err == nil {
+			t.Error("CreateSession() for a missing user should fail")
+		}
+		laptop := createSession(t, store, walt.ID)
+		phone := createSession(t, store, walt.ID)
+		jesseSession := createSession(t, store, jesse.ID)
+		tick()
+		expired, _ := store.CreateSession(ctx, sqlc.CreateSessionParams{UserID: walt.ID, ExpiresAt: time.Now().Add(-time.Hour)})
+
+		got, err := store.GetSession(ctx, laptop.ID)
+		if err != nil || got.UserID != walt.ID || got.UserAgent != "curl/8.0" || got.IP != "192.0.2.1" || got.RevokedAt.Valid {
+			t.Errorf("GetSession() = %+v, %v", got, err)
+		}
+		tick()
+		if err := store.TouchSession(ctx, sqlc.TouchSessionParams{ID: laptop.ID, IP: "198.51.100.7"}); err != nil {
+			t.Fatalf("TouchSession() error = %v", err)
+		}
+		if got, _ := store.GetSession(ctx, laptop.ID); got.IP != "198.51.100.7" || !got.LastUsedAt.After(laptop.LastUsedAt) {
+			t.Errorf("GetSession() after touching = %+v, want the new IP and a later last use", got)
+		}
+		active, err := store.ListActiveSessions(ctx, walt.ID)
+		if err != nil || len(active) != 2 || active[0].ID != laptop.ID || active[1].ID != phone.ID {
+			t.Errorf("ListActiveSessions() = %+v, %v, want the laptop then the phone", active, err)
+		}
+
+		renewed := time.Now().Add(2 * time.Hour).UTC().Truncate(time.Second)
+		if err := store.RenewSession(ctx, sqlc.RenewSessionParams{ID: phone.ID, IP: "203.0.113.9", ExpiresAt: renewed}); err != nil {
+			t.Fatalf("RenewSession() error = %v", err)
+		}
+		if got, _ := store.GetSession(ctx, phone.ID); !got.ExpiresAt.Equal(renewed) || got.IP != "203.0.113.9" {
+			t.Errorf("GetSession() after renewing = %+v, want it to expire at %v", got, renewed)
+		}
+
+		if revoked, err := store.RevokeSession(ctx, sqlc.RevokeSessionParams{ID: laptop.ID, UserID: jesse.ID}); err != nil || revoked != 0 {
+			t.Errorf("RevokeSession() someone else's session = %d, %v, want 0", revoked, err)
+		}
+		if revoked, err := store.RevokeSession(ctx, sqlc.RevokeSessionParams{ID: laptop.ID, UserID: walt.ID}); err != nil || revoked != 1 {
+			t.Errorf("RevokeSession() = %d, %v, want 1", revoked, err)
+		}
+		if revoked, _ := store.RevokeSession(ctx, sqlc.RevokeSessionParams{ID: laptop.ID, UserID: walt.ID}); revoked != 0 {
+			t.Errorf("RevokeSession() again = %d, want 0", revoked)
+		}
+		if active, _ := store.ListActiveSessions(ctx, walt.ID); len(active) != 1 || active[0].ID != phone.ID {
+			t.Errorf("ListActiveSessions() after revoking = %+v, want the phone", active)
+		}
+
+		if err := store.RevokeUserSessions(ctx, walt.ID); err != nil {
+			t.Fatalf("RevokeUserSessions() error = %v", err)
+		}
+		if active, _ := store.ListActiveSessions(ctx, walt.ID); len(active) != 0 {
+			t.Errorf("ListActiveSessions() after revoking all = %+v, want none", active)
+		}
+		if got, _ := store.GetSession(ctx, jesseSession.ID); got.RevokedAt.Valid {
+			t.Errorf("GetSession() someone else's session = %+v, want it left alone", got)
+		}
+		all, err := store.ListUserSessions(ctx, walt.ID)
+		if err != nil || len(all) != 3 || all[0].ID != laptop.ID || all[2].ID != expired.ID {
+			t.Errorf("ListUserSessions() = %+v, %v, want every session oldest first", all, err)
+		}
+	})
+
+	t.Run("APIKeys", func(t *testing.T) {
+		store := newStore(t)
+		walt := createUser(t, store, "walt@example.com")
+		jesse := createUser(t, store, "jesse@example.com")
+		createKey := func(userID uuid.UUID, name string) sqlc.APIKey {
+			t.Helper()
+			key, err := store.CreateAPIKey(ctx, sqlc.CreateAPIKeyParams{
+				UserID:  userID,
+				Name:    name,
+				Prefix:  "chirpy_" + name,
+				KeyHash: "hash-" + name,
+				Scopes:  []string{"chirps:read", "chirps:write"},
+			})
+			if err != nil {
+				t.Fatalf("CreateAPIKey() error = %v", err)
+			}
+			return key
+		}
+
+		if _, err := store.CreateAPIKey(ctx, sqlc.CreateAPIKeyParams{UserID: uuid.New(), KeyHash: "orphan", Scopes: []string{}}); err == nil {
+			t.Error("CreateAPIKey() for a missing user should fail")
+		}
+		bot := createKey(walt.ID, "bot")
+		backup := createKey(walt.ID, "backup")
+		jesseKey := createKey(jesse.ID, "jesse")
+		if _, err := store.CreateAPIKey(ctx, sqlc.CreateAPIKeyParams{UserID: walt.ID, KeyHash: "hash-bot", Scopes: []string{}}); err == nil {
+			t.Error("CreateAPIKey() with a duplicate hash should fail")
+		}
+
+		got, err := store.GetAPIKeyByHash(ctx, "hash-bot")
+		if err != nil || got.ID != bot.ID || !slices.Equal(got.Scopes, []string{"chirps:read", "chirps:write"}) || got.LastUsedAt.Valid {
+			t.Errorf("GetAPIKeyByHash() = %+v, %v, want the bot key", got, err)
+		}
+		if _, err := store.GetAPIKeyByHash(ctx, "hash-nobody"); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetAPIKeyByHash() unknown hash error = %v, want sql.ErrNoRows", err)
+		}
+		if err := store.TouchAPIKey(ctx, bot.ID); err != nil {
+			t.Fatalf("TouchAPIKey() error = %v", err)
+		}
+		if got, _ := store.GetAPIKeyByHash(ctx, "hash-bot"); !got.LastUsedAt.Valid {
+			t.Errorf("GetAPIKeyByHash() after touching = %+v, want a last use", got)
+		}
+		active, err := store.ListActiveAPIKeys(ctx, walt.ID)
+		if err != nil || len(active) != 2 || active[0].ID != bot.ID || active[1].ID != backup.ID {
+			t.Errorf("ListActiveAPIKeys() = %+v, %v, want the bot then the backup key", active, err)
+		}
+
+		if revoked, err := store.RevokeAPIKey(ctx, sqlc.RevokeAPIKeyParams{ID: bot.ID, UserID: jesse.ID}); err != nil || revoked != 0 {
+			t.Errorf("RevokeAPIKey() someone else's key = %d, %v, want 0", revoked, err)
+		}
+		if revoked, err := store.RevokeAPIKey(ctx, sqlc.RevokeAPIKeyParams{ID: bot.ID, UserID: walt.ID}); err != nil || revoked != 1 {
+			t.Errorf("RevokeAPIKey() = %d, %v, want 1", revoked, err)
+		}
+		if revoked, _ := store.RevokeAPIKey(ctx, sqlc.RevokeAPIKeyParams{ID: bot.ID, UserID: walt.ID}); revoked != 0 {
+			t.Errorf("RevokeAPIKey() again = %d, want 0", revoked)
+		}
+		if got, _ := store.GetAPIKeyByHash(ctx, "hash-bot"); !got.RevokedAt.Valid {
+			t.Errorf("GetAPIKeyByHash() after revoking = %+v, want it revoked", got)
+		}
+
+		if active, _ := store.ListActiveAPIKeys(ctx, walt.ID); len(active) != 1 || active[0].ID != backup.ID {
+			t.Errorf("ListActiveAPIKeys() after revoking = %+v, want the backup key", active)
+		}
+		if active, _ := store.ListActiveAPIKeys(ctx, jesse.ID); len(active) != 1 || active[0].ID != jesseKey.ID {
+			t.Errorf("ListActiveAPIKeys() someone else's keys = %+v, want them left alone", active)
+		}
+	})
+
+	t.Run("Transactions", func(t *testing.T) {
+		store := newStore(t)
+		user := createUser(t, store, "walt@example.com")
+		failed := errors.New("failed")
+
+		var rolledBack sqlc.Chirp
+		err := store.WithTx(ctx, func(tx Store) error {
+			rolledBack = createChirp(t, tx, "#go rolled back", user.ID)
+			if err := tx.SetChirpHashtags(ctx, sqlc.SetChirpHashtagsParams{ChirpID: rolledBack.ID, Tags: []string{"go"}}); err != nil {
+				t.Fatalf("SetChirpHashtags() error = %v", err)
+			}
+			return failed
+		})
+		if !errors.Is(err, failed) {
+			t.Errorf("WithTx() error = %v, want %v", err, failed)
+		}
+		if _, err := store.GetChirpByID(ctx, rolledBack.ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetChirpByID() after rollback error = %v, want sql.ErrNoRows", err)
+		}
+		chirps, _ := store.ListHashtagChirpsAscending(ctx, sqlc.ListHashtagChirpsAscendingParams{Tag: "go", Limit: 10})
+		if len(chirps) != 0 {
+			t.Errorf("ListHashtagChirpsAscending() after rollback = %+v, want none", chirps)
+		}
+
+		var committed sqlc.Chirp
+		err = store.WithTx(ctx, func(tx Store) error {
+			committed = createChirp(t, tx, "#go committed", user.ID)
+			return tx.WithTx(ctx, func(tx Store) error {
+				return tx.SetChirpHashtags(ctx, sqlc.SetChirpHashtagsParams{ChirpID: committed.ID, Tags: []string{"go"}})
+			})
+		})
+		if err != nil {
+			t.Fatalf("WithTx() error = %v", err)
+		}
+		chirps, _ = store.ListHashtagChirpsAscending(ctx, sqlc.ListHashtagChirpsAscendingParams{Tag: "go", Limit: 10})
+		if len(chirps) != 1 || chirps[0].ID != committed.ID {
+			t.Errorf("ListHashtagChirpsAscending() after commit = %+v, want the committed chirp", chirps)
+		}
+	})
+
+	t.Run("Reset", func(t *testing.T) {
+		store := newStore(t)
+		user := createUser(t, store, "walt@example.com")
+		createChirp(t, store, "say my name", user.ID)
+		err := store.StoreRefresh(ctx, sqlc.StoreRefreshParams{
+			TokenHash: "token",
+			UserID:    uuid.NullUUID{UUID: user.ID, Valid: true},
+			ExpiresAt: time.Now().Add(time.Hour),
+			FamilyID:  createSession(t, store, user.ID).ID,
+		})
+		if err != nil {
+			t.Fatalf("StoreRefresh() error = %v", err)
+		}
+
+		if err := store.DeleteAllUsers(ctx); err != nil {
+			t.Fatalf("DeleteAllUsers() error = %v", err)
+		}
+		if _, err := store.GetUserByEmail(ctx, "walt@example.com"); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetUserByEmail() after reset error = %v, want sql.ErrNoRows", err)
+		}
+		if chirps, _ := store.ListChirpsAscending(ctx, sqlc.ListChirpsAscendingParams{Limit: 10}); len(chirps) != 0 {
+			t.Errorf("ListChirpsAscending() after reset = %d chirps, want 0", len(chirps))
+		}
+		if _, err := store.GetRefreshToken(ctx, "token"); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetRefreshToken() after reset error = %v, want sql.ErrNoRows", err)
+		}
+	})
+}
@@ -0,0 +1,23 @@
+package jobs
+
+import (
+	"context"
+	"github.com/pcauce/chirpy/internal/database"
+	"log"
+	"time"
+)
+
+// PurgeExports deletes export archives older than retention, after which
+// their download links stop working.
+func PurgeExports(store database.Store, retention time.Duration) func(context.Context) error {
+	return func(ctx context.Context) error {
+		purged, err := store.PurgeExports(ctx, time.Now().UTC().Add(-retention))
+		if err != nil {
+			return err
+		}
+		if purged > 0 {
+			log.Printf("Purged %d expired exports", purged)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,28 @@
+// Package jobs holds the background work the server runs alongside the API.
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Run calls job once per interval until ctx is done. Failures are logged and
+// retried on the next tick rather than stopping the loop.
+func Run(ctx context.Context, name string, interval time.Duration, job func(context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := job(ctx)
+		if err != nil {
+			log.Printf("Job %q failed: %v", name, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
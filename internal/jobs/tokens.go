@@ -0,0 +1,23 @@
+package jobs
+
+import (
+	"context"
+	"github.com/pcauce/chirpy/internal/database"
+	"log"
+	"time"
+)
+
+// PurgeRevokedAccessTokens drops access tokens from the denylist once they
+// have expired, since an expired token is rejected without it.
+func PurgeRevokedAccessTokens(store database.Store) func(context.Context) error {
+	return func(ctx context.Context) error {
+		purged, err := store.PurgeRevokedAccessTokens(ctx, time.Now().UTC())
+		if err != nil {
+			return err
+		}
+		if purged > 0 {
+			log.Printf("Purged %d expired access tokens from the denylist", purged)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,27 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"github.com/pcauce/chirpy/internal/database"
+	"log"
+	"time"
+)
+
+// PurgeTrash hard deletes chirps that have been in the trash for longer
+// than retention, after which they can no longer be restored.
+func PurgeTrash(store database.Store, retention time.Duration) func(context.Context) error {
+	return func(ctx context.Context) error {
+		purged, err := store.PurgeTrashedChirps(ctx, sql.NullTime{
+			Time:  time.Now().UTC().Add(-retention),
+			Valid: true,
+		})
+		if err != nil {
+			return err
+		}
+		if purged > 0 {
+			log.Printf("Purged %d chirps from the trash", purged)
+		}
+		return nil
+	}
+}
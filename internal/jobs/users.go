@@ -0,0 +1,42 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/pcauce/chirpy/internal/database"
+	"github.com/pcauce/chirpy/internal/sqlc"
+	"log"
+	"time"
+)
+
+// PurgeUsers deletes the accounts whose owners asked for it more than grace
+// ago, along with everything that cascades from them. Their handles are
+// released so that nobody can take one over straight away and pose as them.
+func PurgeUsers(store database.Store, grace time.Duration) func(context.Context) error {
+	return func(ctx context.Context) error {
+		purged, err := store.PurgeDeletedUsers(ctx, sql.NullTime{
+			Time:  time.Now().UTC().Add(-grace),
+			Valid: true,
+		})
+		if err != nil {
+			return err
+		}
+		for _, user := range purged {
+			if !user.Handle.Valid {
+				continue
+			}
+			err = store.ReleaseHandle(ctx, sqlc.ReleaseHandleParams{
+				Handle: user.Handle.String,
+				UserID: uuid.NullUUID{},
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if len(purged) > 0 {
+			log.Printf("Purged %d deleted accounts", len(purged))
+		}
+		return nil
+	}
+}
@@ -0,0 +1,211 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+)
+
+// node is a parsed tsquery expression as produced by Parse.
+type node interface {
+	// match reports whether the node holds for words, adding one hit for
+	// every occurrence of a positive term it matched.
+	match(words []string, hits *int) bool
+}
+
+type orNode []node
+
+func (n orNode) match(words []string, hits *int) bool {
+	matched := false
+	for _, child := range n {
+		if child.match(words, hits) {
+			matched = true
+		}
+	}
+	return matched
+}
+
+type andNode []node
+
+func (n andNode) match(words []string, hits *int) bool {
+	matched := true
+	for _, child := range n {
+		if !child.match(words, hits) {
+			matched = false
+		}
+	}
+	return matched
+}
+
+type notNode struct{ child node }
+
+func (n notNode) match(words []string, _ *int) bool {
+	var ignored int
+	return !n.child.match(words, &ignored)
+}
+
+type lexeme struct {
+	word   string
+	prefix bool
+}
+
+func (l lexeme) matches(word string) bool {
+	if l.prefix {
+		return strings.HasPrefix(word, l.word)
+	}
+	return word == l.word
+}
+
+// phraseNode is one or more lexemes that must appear consecutively.
+type phraseNode []lexeme
+
+func (n phraseNode) match(words []string, hits *int) bool {
+	found := 0
+	for start := 0; start+len(n) <= len(words); start++ {
+		matched := true
+		for i, l := range n {
+			if !l.matches(words[start+i]) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			found++
+		}
+	}
+	*hits += found
+	return found > 0
+}
+
+// Match evaluates tsquery text produced by Parse against text. The rank is a
+// rough stand-in for ts_rank: the share of words in text that were hits.
+func Match(tsquery, text string) (rank float32, ok bool, err error) {
+	p := parser{tokens: tokenize(tsquery)}
+	expr, err := p.parseOr()
+	if err != nil {
+		return 0, false, err
+	}
+	if p.pos != len(p.tokens) {
+		return 0, false, fmt.Errorf("unexpected %q in tsquery", p.tokens[p.pos])
+	}
+
+	words := Words(text)
+	hits := 0
+	if !expr.match(words, &hits) {
+		return 0, false, nil
+	}
+	return float32(hits) / float32(len(words)+1), true, nil
+}
+
+func tokenize(tsquery string) []string {
+	var tokens []string
+	for _, field := range strings.Fields(tsquery) {
+		for strings.HasPrefix(field, "(") || strings.HasPrefix(field, "!") {
+			tokens = append(tokens, field[:1])
+			field = field[1:]
+		}
+		closing := 0
+		for strings.HasSuffix(field, ")") {
+			closing++
+			field = field[:len(field)-1]
+		}
+		if field != "" {
+			tokens = append(tokens, field)
+		}
+		for range closing {
+			tokens = append(tokens, ")")
+		}
+	}
+	return tokens
+}
+
+type parser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *parser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *parser) parseOr() (node, error) {
+	var children orNode
+	for {
+		child, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+		if p.peek() != "|" {
+			break
+		}
+		p.pos++
+	}
+	if len(children) == 1 {
+		return children[0], nil
+	}
+	return children, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	var children andNode
+	for {
+		child, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+		if p.peek() != "&" {
+			break
+		}
+		p.pos++
+	}
+	if len(children) == 1 {
+		return children[0], nil
+	}
+	return children, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	switch p.peek() {
+	case "!":
+		p.pos++
+		child, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{child}, nil
+	case "(":
+		p.pos++
+		child, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("missing closing parenthesis in tsquery")
+		}
+		p.pos++
+		return child, nil
+	}
+	return p.parsePhrase()
+}
+
+func (p *parser) parsePhrase() (node, error) {
+	var phrase phraseNode
+	for {
+		token := p.peek()
+		switch token {
+		case "", "&", "|", "!", "(", ")", "<->":
+			return nil, fmt.Errorf("expected a lexeme in tsquery, got %q", token)
+		}
+		p.pos++
+		word, prefix := strings.CutSuffix(token, ":*")
+		phrase = append(phrase, lexeme{word: word, prefix: prefix})
+		if p.peek() != "<->" {
+			return phrase, nil
+		}
+		p.pos++
+	}
+}
@@ -0,0 +1,117 @@
+// Package search turns user search input into Postgres tsquery text, and
+// evaluates that same text against plain strings for the in-memory store.
+package search
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+// ErrEmptyQuery is returned when the input has nothing to search for.
+var ErrEmptyQuery = errors.New("search query has no terms")
+
+// Parse converts a search string into tsquery text for to_tsquery.
+//
+// Whitespace-separated terms must all match. A term wrapped in double
+// quotes is a phrase, a trailing * makes the last word a prefix, a leading
+// - excludes the term, and OR between two terms matches either of them.
+func Parse(input string) (string, error) {
+	var groups [][]string
+	var negated []string
+	joinNext := false
+
+	rest := strings.TrimSpace(input)
+	for rest != "" {
+		var raw string
+		exclude := false
+		if strings.HasPrefix(rest, "-") {
+			exclude = true
+			rest = rest[1:]
+		}
+
+		if strings.HasPrefix(rest, "\"") {
+			end := strings.Index(rest[1:], "\"")
+			if end < 0 {
+				raw, rest = rest[1:], ""
+			} else {
+				raw, rest = rest[1:end+1], rest[end+2:]
+			}
+		} else {
+			end := strings.IndexFunc(rest, unicode.IsSpace)
+			if end < 0 {
+				raw, rest = rest, ""
+			} else {
+				raw, rest = rest[:end], rest[end:]
+			}
+			if raw == "OR" && !exclude {
+				joinNext = len(groups) > 0
+				rest = strings.TrimSpace(rest)
+				continue
+			}
+		}
+		rest = strings.TrimSpace(rest)
+
+		phrase := parsePhrase(raw)
+		if phrase == "" {
+			joinNext = false
+			continue
+		}
+		switch {
+		case exclude:
+			negated = append(negated, "!"+parenthesize(phrase))
+		case joinNext:
+			groups[len(groups)-1] = append(groups[len(groups)-1], phrase)
+		default:
+			groups = append(groups, []string{phrase})
+		}
+		joinNext = false
+	}
+
+	if len(groups) == 0 {
+		return "", ErrEmptyQuery
+	}
+
+	var clauses []string
+	for _, group := range groups {
+		if len(group) == 1 {
+			clauses = append(clauses, group[0])
+			continue
+		}
+		var alternatives []string
+		for _, phrase := range group {
+			alternatives = append(alternatives, parenthesize(phrase))
+		}
+		clauses = append(clauses, "("+strings.Join(alternatives, " | ")+")")
+	}
+	clauses = append(clauses, negated...)
+	return strings.Join(clauses, " & "), nil
+}
+
+// parsePhrase splits raw into lexemes joined by the followed-by operator.
+// Punctuation separates words, so "don't" becomes the phrase don <-> t.
+func parsePhrase(raw string) string {
+	prefix := strings.HasSuffix(raw, "*")
+	words := Words(raw)
+	if len(words) == 0 {
+		return ""
+	}
+	if prefix {
+		words[len(words)-1] += ":*"
+	}
+	return strings.Join(words, " <-> ")
+}
+
+func parenthesize(phrase string) string {
+	if strings.Contains(phrase, " ") {
+		return "(" + phrase + ")"
+	}
+	return phrase
+}
+
+// Words lowercases text and splits it into runs of letters and digits.
+func Words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
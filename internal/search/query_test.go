@@ -0,0 +1,112 @@
+package search
+
+import (
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "Single word",
+			input: "Heisenberg",
+			want:  "heisenberg",
+		},
+		{
+			name:  "All words must match",
+			input: "blue  sky",
+			want:  "blue & sky",
+		},
+		{
+			name:  "Phrase",
+			input: `"say my name"`,
+			want:  "say <-> my <-> name",
+		},
+		{
+			name:  "Prefix",
+			input: "heis*",
+			want:  "heis:*",
+		},
+		{
+			name:  "Alternatives",
+			input: `walter OR "mr white" OR heis*`,
+			want:  "(walter | (mr <-> white) | heis:*)",
+		},
+		{
+			name:  "Exclusion",
+			input: `chemistry -"jesse pinkman" -meth`,
+			want:  "chemistry & !(jesse <-> pinkman) & !meth",
+		},
+		{
+			name:  "Punctuation is stripped",
+			input: "it's fornax, & (kerfuffle)!",
+			want:  "it <-> s & fornax & kerfuffle",
+		},
+		{
+			name:    "Only exclusions",
+			input:   "-meth",
+			wantErr: true,
+		},
+		{
+			name:    "Nothing to search for",
+			input:   " & ! OR ",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("Parse() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	text := "Say my name. You're Heisenberg, the chemistry teacher!"
+
+	tests := []struct {
+		name  string
+		input string
+		want  bool
+	}{
+		{name: "Word", input: "heisenberg", want: true},
+		{name: "Missing word", input: "pinkman", want: false},
+		{name: "Phrase", input: `"say my name"`, want: true},
+		{name: "Phrase out of order", input: `"name my say"`, want: false},
+		{name: "Prefix", input: "chem*", want: true},
+		{name: "Prefix inside phrase", input: `"chemistry teach*"`, want: true},
+		{name: "Alternatives", input: "pinkman OR heisenberg", want: true},
+		{name: "Exclusion", input: "chemistry -teacher", want: false},
+		{name: "Excluded phrase absent", input: `chemistry -"teacher chemistry"`, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tsquery, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			rank, got, err := Match(tsquery, text)
+			if err != nil {
+				t.Fatalf("Match() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Match(%q) = %v, want %v", tsquery, got, tt.want)
+			}
+			if got && rank <= 0 {
+				t.Errorf("Match(%q) rank = %v, want > 0", tsquery, rank)
+			}
+		})
+	}
+}
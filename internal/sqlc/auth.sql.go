@@ -12,55 +12,150 @@ import (
 	"github.com/google/uuid"
 )
 
-const getUserFromRefresh = `-- name: GetUserFromRefresh :one
-SELECT user_id
+const getRefreshToken = `-- name: GetRefreshToken :one
+SELECT token_hash, created_at, updated_at, user_id, expires_at, revoked_at, family_id, rotated_at
 FROM refresh_tokens
-WHERE token = $1
-  AND revoked_at IS NULL
-  AND expires_at > NOW()
+WHERE token_hash = $1
 `
 
-func (q *Queries) GetUserFromRefresh(ctx context.Context, token string) (uuid.NullUUID, error) {
-	row := q.db.QueryRowContext(ctx, getUserFromRefresh, token)
-	var user_id uuid.NullUUID
-	err := row.Scan(&user_id)
-	return user_id, err
+func (q *Queries) GetRefreshToken(ctx context.Context, tokenHash string) (RefreshToken, error) {
+	row := q.db.QueryRowContext(ctx, getRefreshToken, tokenHash)
+	var i RefreshToken
+	err := row.Scan(
+		&i.TokenHash,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.FamilyID,
+		&i.RotatedAt,
+	)
+	return i, err
+}
+
+const isAccessTokenRevoked = `-- name: IsAccessTokenRevoked :one
+SELECT EXISTS (
+    SELECT 1
+    FROM revoked_access_tokens
+    WHERE jti = $1
+) AS revoked
+`
+
+func (q *Queries) IsAccessTokenRevoked(ctx context.Context, jti uuid.UUID) (bool, error) {
+	row := q.db.QueryRowContext(ctx, isAccessTokenRevoked, jti)
+	var revoked bool
+	err := row.Scan(&revoked)
+	return revoked, err
+}
+
+const purgeRevokedAccessTokens = `-- name: PurgeRevokedAccessTokens :execrows
+DELETE FROM revoked_access_tokens
+WHERE expires_at <= $1
+`
+
+func (q *Queries) PurgeRevokedAccessTokens(ctx context.Context, expiresAt time.Time) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeRevokedAccessTokens, expiresAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const revokeAccessToken = `-- name: RevokeAccessToken :exec
+INSERT INTO revoked_access_tokens (jti, user_id, expires_at, revoked_at)
+VALUES ($1, $2, $3, now())
+ON CONFLICT DO NOTHING
+`
+
+type RevokeAccessTokenParams struct {
+	JTI       uuid.UUID
+	UserID    uuid.UUID
+	ExpiresAt time.Time
+}
+
+func (q *Queries) RevokeAccessToken(ctx context.Context, arg RevokeAccessTokenParams) error {
+	_, err := q.db.ExecContext(ctx, revokeAccessToken, arg.JTI, arg.UserID, arg.ExpiresAt)
+	return err
 }
 
 const revokeRefresh = `-- name: RevokeRefresh :exec
 UPDATE refresh_tokens
 SET updated_at = $2, revoked_at = $2
-WHERE token = $1
+WHERE token_hash = $1
 `
 
 type RevokeRefreshParams struct {
-	Token     string
+	TokenHash string
 	UpdatedAt time.Time
 }
 
 func (q *Queries) RevokeRefresh(ctx context.Context, arg RevokeRefreshParams) error {
-	_, err := q.db.ExecContext(ctx, revokeRefresh, arg.Token, arg.UpdatedAt)
+	_, err := q.db.ExecContext(ctx, revokeRefresh, arg.TokenHash, arg.UpdatedAt)
 	return err
 }
 
+const revokeRefreshFamily = `-- name: RevokeRefreshFamily :exec
+UPDATE refresh_tokens
+SET updated_at = now(), revoked_at = now()
+WHERE family_id = $1 AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeRefreshFamily(ctx context.Context, familyID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, revokeRefreshFamily, familyID)
+	return err
+}
+
+const revokeUserRefreshTokens = `-- name: RevokeUserRefreshTokens :exec
+UPDATE refresh_tokens
+SET updated_at = now(), revoked_at = now()
+WHERE user_id = $1 AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeUserRefreshTokens(ctx context.Context, userID uuid.NullUUID) error {
+	_, err := q.db.ExecContext(ctx, revokeUserRefreshTokens, userID)
+	return err
+}
+
+const rotateRefresh = `-- name: RotateRefresh :execrows
+UPDATE refresh_tokens
+SET updated_at = now(), rotated_at = now()
+WHERE token_hash = $1 AND rotated_at IS NULL AND revoked_at IS NULL
+`
+
+func (q *Queries) RotateRefresh(ctx context.Context, tokenHash string) (int64, error) {
+	result, err := q.db.ExecContext(ctx, rotateRefresh, tokenHash)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const storeRefresh = `-- name: StoreRefresh :exec
-INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at)
+INSERT INTO refresh_tokens (token_hash, created_at, updated_at, user_id, expires_at, family_id)
 VALUES (
         $1,
         now(),
         now(),
         $2,
-        $3
+        $3,
+        $4
        )
 `
 
 type StoreRefreshParams struct {
-	Token     string
+	TokenHash string
 	UserID    uuid.NullUUID
 	ExpiresAt time.Time
+	FamilyID  uuid.UUID
 }
 
 func (q *Queries) StoreRefresh(ctx context.Context, arg StoreRefreshParams) error {
-	_, err := q.db.ExecContext(ctx, storeRefresh, arg.Token, arg.UserID, arg.ExpiresAt)
+	_, err := q.db.ExecContext(ctx, storeRefresh,
+		arg.TokenHash,
+		arg.UserID,
+		arg.ExpiresAt,
+		arg.FamilyID,
+	)
 	return err
 }
@@ -7,29 +7,66 @@ package sqlc
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, in_reply_to, rechirp_of, quote_of)
 VALUES (
            gen_random_uuid(),
            now(),
            now(),
            $1,
-           $2
+           $2,
+           $3,
+           $4,
+           $5
        )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, deleted_at, in_reply_to, rechirp_of, quote_of
 `
 
 type CreateChirpParams struct {
-	Body   string
-	UserID uuid.NullUUID
+	Body      string
+	UserID    uuid.NullUUID
+	InReplyTo uuid.NullUUID
+	RechirpOf uuid.NullUUID
+	QuoteOf   uuid.NullUUID
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp,
+		arg.Body,
+		arg.UserID,
+		arg.InReplyTo,
+		arg.RechirpOf,
+		arg.QuoteOf,
+	)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.DeletedAt,
+		&i.InReplyTo,
+		&i.RechirpOf,
+		&i.QuoteOf,
+	)
+	return i, err
+}
+
+const getChirpByID = `-- name: GetChirpByID :one
+SELECT id, created_at, updated_at, body, user_id, deleted_at, in_reply_to, rechirp_of, quote_of
+FROM chirps
+WHERE id = $1 AND deleted_at IS NULL
+`
+
+func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirpByID, id)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -37,33 +74,115 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.DeletedAt,
+		&i.InReplyTo,
+		&i.RechirpOf,
+		&i.QuoteOf,
 	)
 	return i, err
 }
 
-const deleteChirp = `-- name: DeleteChirp :exec
-DELETE FROM chirps
-WHERE id = $1 AND user_id = $2
+const listChirpsAscending = `-- name: ListChirpsAscending :many
+SELECT id, created_at, updated_at, body, user_id, deleted_at, in_reply_to, rechirp_of, quote_of
+FROM chirps
+WHERE deleted_at IS NULL
+  AND ($1::uuid IS NULL OR user_id = $1::uuid)
+  AND ($2::text IS NULL
+    OR to_tsvector('english', body) @@ to_tsquery('english', $2::text))
+  AND ($3::timestamp IS NULL OR created_at >= $3::timestamp)
+  AND ($4::timestamp IS NULL OR created_at < $4::timestamp)
+  AND ($5::timestamp IS NULL
+    OR (created_at, id) > ($5::timestamp, $6::uuid))
+ORDER BY created_at, id
+LIMIT $7
 `
 
-type DeleteChirpParams struct {
-	ID     uuid.UUID
-	UserID uuid.NullUUID
+type ListChirpsAscendingParams struct {
+	AuthorID        uuid.NullUUID
+	Query           sql.NullString
+	CreatedAfter    sql.NullTime
+	CreatedBefore   sql.NullTime
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	Limit           int32
 }
 
-func (q *Queries) DeleteChirp(ctx context.Context, arg DeleteChirpParams) error {
-	_, err := q.db.ExecContext(ctx, deleteChirp, arg.ID, arg.UserID)
-	return err
+func (q *Queries) ListChirpsAscending(ctx context.Context, arg ListChirpsAscendingParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsAscending,
+		arg.AuthorID,
+		arg.Query,
+		arg.CreatedAfter,
+		arg.CreatedBefore,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.DeletedAt,
+			&i.InReplyTo,
+			&i.RechirpOf,
+			&i.QuoteOf,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
-const getAllChirps = `-- name: GetAllChirps :many
-SELECT id, created_at, updated_at, body, user_id
+const listChirpsDescending = `-- name: ListChirpsDescending :many
+SELECT id, created_at, updated_at, body, user_id, deleted_at, in_reply_to, rechirp_of, quote_of
 FROM chirps
-ORDER BY created_at
+WHERE deleted_at IS NULL
+  AND ($1::uuid IS NULL OR user_id = $1::uuid)
+  AND ($2::text IS NULL
+    OR to_tsvector('english', body) @@ to_tsquery('english', $2::text))
+  AND ($3::timestamp IS NULL OR created_at >= $3::timestamp)
+  AND ($4::timestamp IS NULL OR created_at < $4::timestamp)
+  AND ($5::timestamp IS NULL
+    OR (created_at, id) < ($5::timestamp, $6::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT $7
 `
 
-func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getAllChirps)
+type ListChirpsDescendingParams struct {
+	AuthorID        uuid.NullUUID
+	Query           sql.NullString
+	CreatedAfter    sql.NullTime
+	CreatedBefore   sql.NullTime
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	Limit           int32
+}
+
+func (q *Queries) ListChirpsDescending(ctx context.Context, arg ListChirpsDescendingParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsDescending,
+		arg.AuthorID,
+		arg.Query,
+		arg.CreatedAfter,
+		arg.CreatedBefore,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.Limit,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -77,6 +196,10 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.DeletedAt,
+			&i.InReplyTo,
+			&i.RechirpOf,
+			&i.QuoteOf,
 		); err != nil {
 			return nil, err
 		}
@@ -91,46 +214,156 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 	return items, nil
 }
 
-const getChirpByID = `-- name: GetChirpByID :one
-SELECT id, created_at, updated_at, body, user_id
-FROM chirps
-WHERE id = $1
+const searchChirpsAscending = `-- name: SearchChirpsAscending :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, rechirp_of, quote_of, rank
+FROM (
+    SELECT id, created_at, updated_at, body, user_id, deleted_at, in_reply_to, rechirp_of, quote_of, ts_rank(to_tsvector('english', body), to_tsquery('english', $1)) AS rank
+    FROM chirps
+    WHERE deleted_at IS NULL
+      AND to_tsvector('english', body) @@ to_tsquery('english', $1)
+      AND ($2::uuid IS NULL OR user_id = $2::uuid)
+      AND ($3::timestamp IS NULL OR created_at >= $3::timestamp)
+      AND ($4::timestamp IS NULL OR created_at < $4::timestamp)
+) AS ranked
+WHERE ($5::real IS NULL
+    OR (rank, created_at, id) > ($5::real, $6::timestamp, $7::uuid))
+ORDER BY rank, created_at, id
+LIMIT $8
 `
 
-func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, getChirpByID, id)
-	var i Chirp
-	err := row.Scan(
-		&i.ID,
-		&i.CreatedAt,
-		&i.UpdatedAt,
-		&i.Body,
-		&i.UserID,
+type SearchChirpsAscendingParams struct {
+	Query           string
+	AuthorID        uuid.NullUUID
+	CreatedAfter    sql.NullTime
+	CreatedBefore   sql.NullTime
+	CursorRank      sql.NullFloat64
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	Limit           int32
+}
+
+type SearchChirpsAscendingRow struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Body      string
+	UserID    uuid.NullUUID
+	InReplyTo uuid.NullUUID
+	RechirpOf uuid.NullUUID
+	QuoteOf   uuid.NullUUID
+	Rank      float32
+}
+
+func (q *Queries) SearchChirpsAscending(ctx context.Context, arg SearchChirpsAscendingParams) ([]SearchChirpsAscendingRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchChirpsAscending,
+		arg.Query,
+		arg.AuthorID,
+		arg.CreatedAfter,
+		arg.CreatedBefore,
+		arg.CursorRank,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.Limit,
 	)
-	return i, err
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchChirpsAscendingRow
+	for rows.Next() {
+		var i SearchChirpsAscendingRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.RechirpOf,
+			&i.QuoteOf,
+			&i.Rank,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
-const getChirpsByAuthor = `-- name: GetChirpsByAuthor :many
-SELECT id, created_at, updated_at, body, user_id
-FROM chirps
-WHERE user_id = $1
+const searchChirpsDescending = `-- name: SearchChirpsDescending :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, rechirp_of, quote_of, rank
+FROM (
+    SELECT id, created_at, updated_at, body, user_id, deleted_at, in_reply_to, rechirp_of, quote_of, ts_rank(to_tsvector('english', body), to_tsquery('english', $1)) AS rank
+    FROM chirps
+    WHERE deleted_at IS NULL
+      AND to_tsvector('english', body) @@ to_tsquery('english', $1)
+      AND ($2::uuid IS NULL OR user_id = $2::uuid)
+      AND ($3::timestamp IS NULL OR created_at >= $3::timestamp)
+      AND ($4::timestamp IS NULL OR created_at < $4::timestamp)
+) AS ranked
+WHERE ($5::real IS NULL
+    OR (rank, created_at, id) < ($5::real, $6::timestamp, $7::uuid))
+ORDER BY rank DESC, created_at DESC, id DESC
+LIMIT $8
 `
 
-func (q *Queries) GetChirpsByAuthor(ctx context.Context, userID uuid.NullUUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirpsByAuthor, userID)
+type SearchChirpsDescendingParams struct {
+	Query           string
+	AuthorID        uuid.NullUUID
+	CreatedAfter    sql.NullTime
+	CreatedBefore   sql.NullTime
+	CursorRank      sql.NullFloat64
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	Limit           int32
+}
+
+type SearchChirpsDescendingRow struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Body      string
+	UserID    uuid.NullUUID
+	InReplyTo uuid.NullUUID
+	RechirpOf uuid.NullUUID
+	QuoteOf   uuid.NullUUID
+	Rank      float32
+}
+
+func (q *Queries) SearchChirpsDescending(ctx context.Context, arg SearchChirpsDescendingParams) ([]SearchChirpsDescendingRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchChirpsDescending,
+		arg.Query,
+		arg.AuthorID,
+		arg.CreatedAfter,
+		arg.CreatedBefore,
+		arg.CursorRank,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.Limit,
+	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Chirp
+	var items []SearchChirpsDescendingRow
 	for rows.Next() {
-		var i Chirp
+		var i SearchChirpsDescendingRow
 		if err := rows.Scan(
 			&i.ID,
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.InReplyTo,
+			&i.RechirpOf,
+			&i.QuoteOf,
+			&i.Rank,
 		); err != nil {
 			return nil, err
 		}
@@ -1,33 +1,199 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/pcauce/chirpy/internal/auth"
 	"github.com/pcauce/chirpy/internal/config"
+	"github.com/pcauce/chirpy/internal/database"
+	"github.com/pcauce/chirpy/internal/jobs"
+	"github.com/pcauce/chirpy/internal/sqlc"
 	"github.com/pcauce/chirpy/server/handler"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 )
 
 import _ "github.com/lib/pq"
 
 func main() {
+	db, err := database.Open(config.APIConfig().DBURL)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(db, os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "role" {
+		runRole(database.NewPostgres(db), os.Args[2:])
+		return
+	}
+	if config.APIConfig().MigrateOnStartup {
+		err = database.Migrate(context.Background(), db, "up")
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	store := database.NewPostgres(db)
+	go jobs.Run(ctx, "purge trash", time.Hour, jobs.PurgeTrash(store, config.APIConfig().TrashRetention))
+	go jobs.Run(ctx, "purge users", time.Hour, jobs.PurgeUsers(store, config.APIConfig().DeletionGrace))
+	go jobs.Run(ctx, "purge exports", time.Hour, jobs.PurgeExports(store, config.APIConfig().ExportRetention))
+	go jobs.Run(ctx, "purge revoked access tokens", time.Hour, jobs.PurgeRevokedAccessTokens(store))
+
+	keys, err := loadKeys()
+	if err != nil {
+		log.Fatal(err)
+	}
+	h := handler.New(store, keys)
+	go jobs.Run(ctx, "reload filter", time.Minute, h.ReloadFilter)
+	exportsDone := make(chan struct{})
+	go func() {
+		defer close(exportsDone)
+		jobs.Run(ctx, "build exports", 5*time.Second, h.BuildExports)
+	}()
+
 	mux := http.NewServeMux()
-	mux.HandleFunc("POST /admin/reset", handler.ResetDatabase)
-	mux.HandleFunc("POST /api/users", handler.CreateUser)
-	mux.HandleFunc("PUT /api/users", handler.ChangeUserCredentials)
-	mux.HandleFunc("POST /api/login", handler.LoginUser)
-	mux.HandleFunc("POST /api/refresh", handler.IssueNewAccessToken)
-	mux.HandleFunc("POST /api/revoke", handler.RevokeAccessToken)
-	mux.HandleFunc("POST /api/chirps", handler.CreateChirp)
-	mux.HandleFunc("POST /api/validate_chirp", handler.ValidateChirp)
-	mux.HandleFunc("GET /api/chirps", handler.GetChirps)
-	mux.HandleFunc("GET /api/chirps/{chirpID}", handler.GetChirpByID)
-	mux.HandleFunc("DELETE /api/chirps/{chirpID}", handler.DeleteChirp)
-	mux.HandleFunc("POST /api/polka/webhooks", handler.PolkaWebhooks)
+	mux.HandleFunc("GET /.well-known/jwks.json", h.GetJWKS)
+	mux.Handle("POST /admin/reset", handler.Require(auth.ScopeAdmin, h.ResetDatabase))
+	mux.Handle("PUT /admin/users/{userID}/role", handler.Require(auth.ScopeAdmin, h.SetUserRole))
+	mux.Handle("GET /admin/filter_terms", handler.Require(auth.ScopeAdmin, h.GetFilterTerms))
+	mux.Handle("POST /admin/filter_terms", handler.Require(auth.ScopeAdmin, h.CreateFilterTerm))
+	mux.Handle("PUT /admin/filter_terms/{termID}", handler.Require(auth.ScopeAdmin, h.UpdateFilterTerm))
+	mux.Handle("DELETE /admin/filter_terms/{termID}", handler.Require(auth.ScopeAdmin, h.DeleteFilterTerm))
+	mux.Handle("GET /admin/flagged_chirps", handler.Require(auth.ScopeAdmin, h.GetFlaggedChirps))
+	mux.Handle("DELETE /admin/flagged_chirps/{chirpID}", handler.Require(auth.ScopeAdmin, h.DismissChirpFlags))
+	mux.HandleFunc("POST /api/users", h.CreateUser)
+	mux.Handle("PUT /api/users", handler.Require(auth.ScopeAccount, h.ChangeUserCredentials))
+	mux.Handle("DELETE /api/users/me", handler.Require(auth.ScopeAccount, h.DeleteUser))
+	mux.Handle("GET /api/users/me/trash", handler.Require(auth.ScopeChirpsRead, h.GetTrash))
+	mux.Handle("GET /api/users/me/export", handler.Require(auth.ScopeAccount, h.ExportUser))
+	mux.Handle("GET /api/users/me/exports/{exportID}", handler.Require(auth.ScopeAccount, h.DownloadExport))
+	mux.Handle("POST /api/users/me/import", handler.Require(auth.ScopeAccount, h.ImportUser))
+	mux.Handle("POST /api/users/me/api_keys", handler.Require(auth.ScopeAccount, h.CreateAPIKey))
+	mux.Handle("GET /api/users/me/api_keys", handler.Require(auth.ScopeAccount, h.GetAPIKeys))
+	mux.Handle("DELETE /api/users/me/api_keys/{keyID}", handler.Require(auth.ScopeAccount, h.RevokeAPIKey))
+	mux.HandleFunc("GET /api/users/{userID}", h.GetUserProfile)
+	mux.HandleFunc("GET /api/users/{userID}/likes", h.GetUserLikes)
+	mux.Handle("POST /api/users/{userID}/follow", handler.Require(auth.ScopeFollowsWrite, h.FollowUser))
+	mux.Handle("DELETE /api/users/{userID}/follow", handler.Require(auth.ScopeFollowsWrite, h.UnfollowUser))
+	mux.HandleFunc("GET /api/users/{userID}/followers", h.GetFollowers)
+	mux.HandleFunc("GET /api/users/{userID}/following", h.GetFollowing)
+	mux.HandleFunc("POST /api/login", h.LoginUser)
+	mux.HandleFunc("POST /api/refresh", h.IssueNewAccessToken)
+	mux.HandleFunc("POST /api/revoke", h.RevokeAccessToken)
+	mux.Handle("GET /api/sessions", handler.Require(auth.ScopeAccount, h.GetSessions))
+	mux.Handle("DELETE /api/sessions/{sessionID}", handler.Require(auth.ScopeAccount, h.RevokeSession))
+	mux.Handle("POST /api/sessions/revoke-all", handler.Require(auth.ScopeAccount, h.RevokeAllSessions))
+	mux.Handle("POST /api/chirps", handler.Require(auth.ScopeChirpsWrite, h.CreateChirp))
+	mux.HandleFunc("POST /api/validate_chirp", h.ValidateChirp)
+	mux.HandleFunc("GET /api/chirps", h.GetChirps)
+	mux.HandleFunc("GET /api/search", h.SearchChirps)
+	mux.Handle("GET /api/timeline", handler.Require(auth.ScopeChirpsRead, h.GetTimeline))
+	mux.HandleFunc("GET /api/hashtags/{tag}", h.GetHashtagChirps)
+	mux.HandleFunc("GET /api/chirps/{chirpID}", h.GetChirpByID)
+	mux.Handle("PUT /api/chirps/{chirpID}", handler.Require(auth.ScopeChirpsWrite, h.EditChirp))
+	mux.Handle("DELETE /api/chirps/{chirpID}", handler.Require(auth.ScopeChirpsWrite, h.DeleteChirp))
+	mux.HandleFunc("GET /api/chirps/{chirpID}/revisions", h.GetChirpRevisions)
+	mux.Handle("POST /api/chirps/{chirpID}/restore", handler.Require(auth.ScopeChirpsWrite, h.RestoreChirp))
+	mux.HandleFunc("GET /api/chirps/{chirpID}/thread", h.GetChirpThread)
+	mux.Handle("POST /api/chirps/{chirpID}/likes", handler.Require(auth.ScopeChirpsWrite, h.LikeChirp))
+	mux.Handle("DELETE /api/chirps/{chirpID}/likes", handler.Require(auth.ScopeChirpsWrite, h.UnlikeChirp))
+	mux.HandleFunc("POST /api/polka/webhooks", h.PolkaWebhooks)
 
 	server := http.Server{
 		Addr:    ":" + config.Port,
-		Handler: mux,
+		Handler: h.Authenticate(mux),
+	}
+
+	go func() {
+		err := server.ListenAndServe()
+		if !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	// On SIGINT or SIGTERM, stop taking requests, let the ones in flight
+	// finish and wait for an export being built to be saved.
+	<-ctx.Done()
+	log.Print("Shutting down")
+	err = server.Shutdown(context.Background())
+	if err != nil {
+		log.Printf("Couldn't shut down cleanly: %v", err)
+	}
+	<-exportsDone
+}
+
+// loadKeys loads the keys access tokens are signed with from JWT_KEYS_DIR.
+// In development, without one, it makes up a key that lasts until the
+// server stops.
+func loadKeys() (*auth.KeySet, error) {
+	if config.APIConfig().JWTKeysDir == "" && config.APIConfig().Platform == "dev" {
+		log.Println("No JWT_KEYS_DIR set, signing access tokens with a temporary key")
+		key, err := auth.GenerateKey("dev")
+		if err != nil {
+			return nil, err
+		}
+		return auth.NewKeySet(key.ID, key)
+	}
+	if config.APIConfig().JWTKeysDir == "" {
+		return nil, errors.New("JWT_KEYS_DIR is not set")
+	}
+	return auth.LoadKeys(config.APIConfig().JWTKeysDir, config.APIConfig().JWTSigningKey)
+}
+
+func runMigrate(db *sql.DB, args []string) {
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "usage: chirpy migrate %s\n", strings.Join(database.MigrateCommands, "|"))
+		os.Exit(2)
+	}
+
+	err := database.Migrate(context.Background(), db, args[0])
+	if err != nil {
+		log.Fatal(err)
 	}
+}
 
-	log.Fatal(server.ListenAndServe())
+// runRole gives the user with an email a role, which is how the first admin
+// is made. Their sessions are revoked like when an admin changes a role.
+func runRole(store database.Store, args []string) {
+	if len(args) != 2 {
+		fmt.Fprintln(os.Stderr, "usage: chirpy role <email> user|admin")
+		os.Exit(2)
+	}
+	role, err := auth.ParseRole(args[1])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx := context.Background()
+	user, err := store.GetUserByEmail(ctx, args[0])
+	if err != nil {
+		log.Fatalf("Couldn't find %s: %v", args[0], err)
+	}
+	_, err = store.SetUserRole(ctx, sqlc.SetUserRoleParams{ID: user.ID, Role: string(role)})
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = store.RevokeUserSessions(ctx, user.ID)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = store.RevokeUserRefreshTokens(ctx, uuid.NullUUID{UUID: user.ID, Valid: true})
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("%s is now %s", user.Email, role)
 }
@@ -0,0 +1,185 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/pcauce/chirpy/internal/auth"
+	"github.com/pcauce/chirpy/internal/content"
+	"github.com/pcauce/chirpy/internal/database"
+	"github.com/pcauce/chirpy/internal/sqlc"
+	"github.com/pcauce/chirpy/server/respond"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Handler serves the Chirpy HTTP API on top of a database.Store.
+type Handler struct {
+	store  database.Store
+	keys   *auth.KeySet
+	filter *content.Filter
+	// content and redContent check chirps by standard and Chirpy Red
+	// users, which differ only in how long a chirp can be.
+	content    *content.Pipeline
+	redContent *content.Pipeline
+}
+
+// New returns a Handler that signs access tokens with keys and whose filter
+// holds content.DefaultTerms until ReloadFilter first loads the terms in the
+// store.
+func New(store database.Store, keys *auth.KeySet) *Handler {
+	filter := content.NewFilter(content.DefaultTerms)
+	return &Handler{
+		store:      store,
+		keys:       keys,
+		filter:     filter,
+		content:    content.Default(filter, content.LengthLimit(false)),
+		redContent: content.Default(filter, content.LengthLimit(true)),
+	}
+}
+
+// Authenticate finds who made each request from its access token or
+// personal API key and puts them in the request context for Require and
+// the handlers. A request whose
+// token is refused still goes on, so public routes keep working, but
+// Require turns it away with the reason.
+func (h *Handler) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, err := h.principal(r)
+		ctx := r.Context()
+		if err == nil {
+			ctx = auth.WithPrincipal(ctx, principal)
+		} else {
+			ctx = context.WithValue(ctx, refusalKey{}, err)
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Require serves next only to a caller Authenticate found who has scope.
+// Anyone else gets a 401, or a 403 if they're logged in but lack the scope.
+func Require(scope string, next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.PrincipalFrom(r.Context())
+		if !ok {
+			refuse(w, r)
+			return
+		}
+		if !principal.HasScope(scope) {
+			w.Header().Set("WWW-Authenticate", `Bearer, error="insufficient_scope", scope="`+scope+`"`)
+			respond.WithError(w, http.StatusForbidden, "Forbidden. Requires the "+scope+" scope", nil)
+			return
+		}
+		next(w, r)
+	})
+}
+
+// caller returns the ID of the user who made r. Behind Require there always
+// is one; elsewhere, if there isn't, it has already responded and returns
+// false.
+func caller(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	principal, ok := auth.PrincipalFrom(r.Context())
+	if !ok {
+		refuse(w, r)
+	}
+	return principal.UserID, ok
+}
+
+// refuse responds to a request Authenticate found nobody on with why.
+func refuse(w http.ResponseWriter, r *http.Request) {
+	err, _ := r.Context().Value(refusalKey{}).(error)
+	if err == nil {
+		err = errNoToken
+	}
+	for _, refusal := range tokenRefusals {
+		if !errors.Is(err, refusal.err) {
+			continue
+		}
+		// RFC 6750 asks for a challenge, with the reason when a token was
+		// given but refused.
+		challenge := "Bearer"
+		if strings.HasPrefix(r.Header.Get("Authorization"), "ApiKey ") {
+			challenge = "ApiKey"
+		}
+		if refusal.err != errNoToken {
+			challenge += `, error="invalid_token", error_description="` + refusal.message + `"`
+		}
+		w.Header().Set("WWW-Authenticate", challenge)
+		respond.WithError(w, http.StatusUnauthorized, refusal.message, err)
+		return
+	}
+	respond.WithError(w, http.StatusInternalServerError, "Couldn't check session", err)
+}
+
+// refusalKey is the context key of why Authenticate found nobody.
+type refusalKey struct{}
+
+// tokenRefusals are the reasons an access token is refused, most specific
+// first, and what the response says.
+var tokenRefusals = []struct {
+	err     error
+	message string
+}{
+	{errNoToken, "Unauthorized"},
+	{auth.ErrTokenExpired, "Access token has expired"},
+	{auth.ErrTokenNotYetValid, "Access token is not valid yet"},
+	{auth.ErrBadSignature, "Access token signature is invalid"},
+	{auth.ErrWrongIssuer, "Access token was not issued by Chirpy"},
+	{auth.ErrWrongAudience, "Access token is not meant for Chirpy"},
+	{auth.ErrInvalidToken, "Unauthorized. JWT not valid"},
+	{auth.ErrTokenRevoked, "Access token has been revoked"},
+	{errSessionEnded, "Session has ended. Log in again"},
+	{errAPIKeyInvalid, "API key is not valid"},
+	{errAPIKeyRevoked, "API key has been revoked"},
+}
+
+// viewer returns the ID of the user who made r, if Authenticate found one.
+// Public routes use it to personalise responses without requiring a login.
+func viewer(r *http.Request) uuid.NullUUID {
+	principal, ok := auth.PrincipalFrom(r.Context())
+	return uuid.NullUUID{UUID: principal.UserID, Valid: ok}
+}
+
+var (
+	errNoToken       = errors.New("no access token")
+	errSessionEnded  = errors.New("session has ended")
+	errAPIKeyInvalid = errors.New("API key not valid")
+	errAPIKeyRevoked = errors.New("API key has been revoked")
+)
+
+// principal returns who the access token or personal API key on r was
+// issued to. Access tokens are checked against the denylist and their
+// session on every request, so revoking either locks them out at once
+// instead of when they expire.
+func (h *Handler) principal(r *http.Request) (auth.Principal, error) {
+	if key, err := auth.GetAPIKey(r.Header); err == nil {
+		return h.apiKeyPrincipal(r, key)
+	}
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return auth.Principal{}, fmt.Errorf("%w: %v", errNoToken, err)
+	}
+	access, err := auth.ValidateJWT(r.Context(), token, h.keys, tokenValidation(), h.store)
+	if err != nil {
+		return auth.Principal{}, err
+	}
+
+	session, err := h.store.GetSession(r.Context(), access.SessionID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && (session.RevokedAt.Valid || session.UserID != access.UserID)) {
+		return auth.Principal{}, errSessionEnded
+	}
+	if err != nil {
+		return auth.Principal{}, err
+	}
+	if time.Since(session.LastUsedAt) >= sessionTouchInterval {
+		err = h.store.TouchSession(r.Context(), sqlc.TouchSessionParams{ID: session.ID, IP: clientIP(r)})
+		if err != nil {
+			log.Printf("Couldn't update session %s: %v", session.ID, err)
+		}
+	}
+	return access.Principal, nil
+}
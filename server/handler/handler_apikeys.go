@@ -0,0 +1,200 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/pcauce/chirpy/internal/auth"
+	"github.com/pcauce/chirpy/internal/config"
+	"github.com/pcauce/chirpy/internal/sqlc"
+	"github.com/pcauce/chirpy/server/respond"
+	"github.com/rivo/uniseg"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// apiKeyTouchInterval is how stale a key's last_used_at can get before
+	// a request with it updates it, like sessionTouchInterval.
+	apiKeyTouchInterval = time.Minute
+	maxAPIKeyNameLength = 50
+)
+
+// APIKey is a personal API key as its owner sees it. Key is only in the
+// response that creates it; after that, the prefix is all that's left of
+// it to tell it by.
+type APIKey struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	Key        string     `json:"key,omitempty"`
+}
+
+func formatAPIKey(key sqlc.APIKey) APIKey {
+	return APIKey{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     key.Scopes,
+		CreatedAt:  key.CreatedAt,
+		LastUsedAt: nullableTime(key.LastUsedAt),
+	}
+}
+
+// CreateAPIKey makes the caller a personal API key limited to the scopes
+// they ask for. The key is in the response and nowhere else: only its hash
+// is stored.
+func (h *Handler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	principal, ok := auth.PrincipalFrom(r.Context())
+	if !ok {
+		refuse(w, r)
+		return
+	}
+
+	var params struct {
+		Name   string   `json:"name"`
+		Scopes []string `json:"scopes"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&params)
+	if err != nil {
+		respond.WithError(w, http.StatusBadRequest, "Couldn't decode JSON", err)
+		return
+	}
+	name := strings.TrimSpace(params.Name)
+	if name == "" {
+		respond.WithError(w, http.StatusBadRequest, "API key needs a name", nil)
+		return
+	}
+	if uniseg.GraphemeClusterCount(name) > maxAPIKeyNameLength {
+		respond.WithError(w, http.StatusBadRequest, "Name is longer than "+strconv.Itoa(maxAPIKeyNameLength)+" characters", nil)
+		return
+	}
+	slices.Sort(params.Scopes)
+	scopes := slices.Compact(params.Scopes)
+	err = auth.CheckAPIKeyScopes(principal.Role, scopes)
+	if err != nil {
+		respond.WithError(w, http.StatusBadRequest, "Couldn't use those scopes: "+err.Error(), err)
+		return
+	}
+
+	key, prefix, err := auth.MakeAPIKey()
+	if err != nil {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't create API key", err)
+		return
+	}
+	keyHash, err := hashAPIKey(key)
+	if err != nil {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't create API key", err)
+		return
+	}
+	created, err := h.store.CreateAPIKey(r.Context(), sqlc.CreateAPIKeyParams{
+		UserID:  principal.UserID,
+		Name:    name,
+		Prefix:  prefix,
+		KeyHash: keyHash,
+		Scopes:  scopes,
+	})
+	if err != nil {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't create API key", err)
+		return
+	}
+
+	formatted := formatAPIKey(created)
+	formatted.Key = key
+	respond.WithJSON(w, http.StatusCreated, formatted)
+}
+
+// GetAPIKeys lists the caller's API keys that haven't been revoked, oldest
+// first.
+func (h *Handler) GetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	userID, ok := caller(w, r)
+	if !ok {
+		return
+	}
+
+	keys, err := h.store.ListActiveAPIKeys(r.Context(), userID)
+	if err != nil {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't get API keys", err)
+		return
+	}
+	formatted := make([]APIKey, 0, len(keys))
+	for _, key := range keys {
+		formatted = append(formatted, formatAPIKey(key))
+	}
+	respond.WithJSON(w, http.StatusOK, formatted)
+}
+
+// RevokeAPIKey stops one of the caller's API keys from working.
+func (h *Handler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID, ok := caller(w, r)
+	if !ok {
+		return
+	}
+
+	keyID, err := uuid.Parse(r.PathValue("keyID"))
+	if err != nil {
+		respond.WithError(w, http.StatusBadRequest, "Couldn't parse API key ID", err)
+		return
+	}
+	revoked, err := h.store.RevokeAPIKey(r.Context(), sqlc.RevokeAPIKeyParams{ID: keyID, UserID: userID})
+	if err != nil {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't revoke API key", err)
+		return
+	}
+	if revoked == 0 {
+		respond.WithError(w, http.StatusNotFound, "Couldn't find API key", nil)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiKeyPrincipal returns who a personal API key belongs to, with the
+// scopes it was given that their role still grants. A key doesn't work
+// while its owner's account is waiting to be deleted.
+func (h *Handler) apiKeyPrincipal(r *http.Request, key string) (auth.Principal, error) {
+	if !auth.IsPersonalAPIKey(key) {
+		return auth.Principal{}, errAPIKeyInvalid
+	}
+	keyHash, err := hashAPIKey(key)
+	if err != nil {
+		return auth.Principal{}, err
+	}
+	apiKey, err := h.store.GetAPIKeyByHash(r.Context(), keyHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return auth.Principal{}, errAPIKeyInvalid
+	}
+	if err != nil {
+		return auth.Principal{}, err
+	}
+	if apiKey.RevokedAt.Valid {
+		return auth.Principal{}, errAPIKeyRevoked
+	}
+	user, err := h.store.GetUserByID(r.Context(), apiKey.UserID)
+	if err != nil {
+		return auth.Principal{}, err
+	}
+	if user.DeletionRequestedAt.Valid {
+		return auth.Principal{}, errAPIKeyInvalid
+	}
+
+	if !apiKey.LastUsedAt.Valid || time.Since(apiKey.LastUsedAt.Time) >= apiKeyTouchInterval {
+		err = h.store.TouchAPIKey(r.Context(), apiKey.ID)
+		if err != nil {
+			log.Printf("Couldn't update API key %s: %v", apiKey.ID, err)
+		}
+	}
+	return auth.NewAPIKeyPrincipal(user.ID, auth.Role(user.Role), apiKey.Scopes), nil
+}
+
+// hashAPIKey is the only form of a personal API key that reaches the store.
+func hashAPIKey(key string) (string, error) {
+	return auth.HashAPIKey(key, config.APIConfig().APIKeyHashKey)
+}
@@ -1,183 +1,412 @@
 package handler
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"github.com/google/uuid"
-	"github.com/pcauce/chirpy/internal/auth"
-	"github.com/pcauce/chirpy/internal/config"
+	"github.com/pcauce/chirpy/internal/content"
 	"github.com/pcauce/chirpy/internal/database"
 	"github.com/pcauce/chirpy/internal/sqlc"
 	"github.com/pcauce/chirpy/server/respond"
 	"net/http"
-	"sort"
 	"time"
 )
 
 type Chirp struct {
-	ID        uuid.UUID     `json:"id"`
-	CreatedAt time.Time     `json:"created_at"`
-	UpdatedAt time.Time     `json:"updated_at"`
-	Body      string        `json:"body"`
-	UserID    uuid.NullUUID `json:"user_id"`
+	ID           uuid.UUID     `json:"id"`
+	CreatedAt    time.Time     `json:"created_at"`
+	UpdatedAt    time.Time     `json:"updated_at"`
+	Body         string        `json:"body"`
+	Entities     ChirpEntities `json:"entities"`
+	UserID       uuid.NullUUID `json:"user_id"`
+	Edited       bool          `json:"edited"`
+	InReplyTo    uuid.NullUUID `json:"in_reply_to"`
+	RechirpOf    uuid.NullUUID `json:"rechirp_of"`
+	QuoteOf      uuid.NullUUID `json:"quote_of"`
+	ReplyCount   int64         `json:"reply_count"`
+	LikeCount    int64         `json:"like_count"`
+	RechirpCount int64         `json:"rechirp_count"`
+	QuoteCount   int64         `json:"quote_count"`
+	LikedByMe    *bool         `json:"liked_by_me,omitempty"`
+	DeletedAt    *time.Time    `json:"deleted_at,omitempty"`
 }
 
-func CreateChirp(w http.ResponseWriter, r *http.Request) {
-	token, err := auth.GetBearerToken(r.Header)
+// ChirpEntities are the hashtags, mentions and links in a chirp's body, as
+// found by content.FindEntities.
+type ChirpEntities struct {
+	Hashtags []Entity `json:"hashtags"`
+	Mentions []Entity `json:"mentions"`
+	Links    []Entity `json:"links"`
+}
+
+// Entity marks part of a chirp body. Start and End count Unicode code
+// points, End exclusive.
+type Entity struct {
+	Text  string `json:"text"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+func formatEntities(body string) ChirpEntities {
+	entities := ChirpEntities{Hashtags: []Entity{}, Mentions: []Entity{}, Links: []Entity{}}
+	for _, found := range content.FindEntities(body) {
+		entity := Entity{Text: found.Text, Start: found.Start, End: found.End}
+		switch found.Type {
+		case content.EntityHashtag:
+			entities.Hashtags = append(entities.Hashtags, entity)
+		case content.EntityMention:
+			entities.Mentions = append(entities.Mentions, entity)
+		case content.EntityLink:
+			entities.Links = append(entities.Links, entity)
+		}
+	}
+	return entities
+}
+
+// formatChirp converts a chirp row for the API. Only edits move updated_at,
+// so a chirp has been edited exactly when it differs from created_at.
+func formatChirp(chirp sqlc.Chirp) Chirp {
+	formatted := Chirp{
+		ID:        chirp.ID,
+		CreatedAt: chirp.CreatedAt,
+		UpdatedAt: chirp.UpdatedAt,
+		Body:      chirp.Body,
+		Entities:  formatEntities(chirp.Body),
+		UserID:    chirp.UserID,
+		Edited:    chirp.UpdatedAt.After(chirp.CreatedAt),
+		InReplyTo: chirp.InReplyTo,
+		RechirpOf: chirp.RechirpOf,
+		QuoteOf:   chirp.QuoteOf,
+	}
+	if chirp.DeletedAt.Valid {
+		formatted.DeletedAt = &chirp.DeletedAt.Time
+	}
+	return formatted
+}
+
+// formatChirps converts chirp rows for the API, filling in the counts that
+// live outside the chirps table with one query per count for all of them.
+// When there is a viewer it also fills in their own state for each chirp.
+func (h *Handler) formatChirps(ctx context.Context, viewer uuid.NullUUID, chirps []sqlc.Chirp) ([]Chirp, error) {
+	ids := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		ids = append(ids, chirp.ID)
+	}
+	replyCounts, err := h.store.CountReplies(ctx, ids)
 	if err != nil {
-		respond.WithError(w, http.StatusUnauthorized, "Unauthorized", err)
-		return
+		return nil, err
+	}
+	replies := map[uuid.UUID]int64{}
+	for _, row := range replyCounts {
+		replies[row.ChirpID.UUID] = row.ReplyCount
+	}
+
+	likeCounts, err := h.store.CountLikes(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	likes := map[uuid.UUID]int64{}
+	for _, row := range likeCounts {
+		likes[row.ChirpID] = row.LikeCount
 	}
 
-	userID, err := auth.ValidateJWT(token, config.APIConfig().JWTSecret)
+	shareCounts, err := h.store.CountShares(ctx, ids)
 	if err != nil {
-		respond.WithError(w, http.StatusUnauthorized, "Unauthorized. JWT not valid", err)
+		return nil, err
+	}
+	shares := map[uuid.UUID]sqlc.CountSharesRow{}
+	for _, row := range shareCounts {
+		shares[row.ChirpID.UUID] = row
+	}
+
+	liked := map[uuid.UUID]bool{}
+	if viewer.Valid {
+		likedIDs, err := h.store.ListLikedChirpIDs(ctx, sqlc.ListLikedChirpIDsParams{
+			UserID:   viewer.UUID,
+			ChirpIds: ids,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range likedIDs {
+			liked[id] = true
+		}
+	}
+
+	formatted := make([]Chirp, 0, len(chirps))
+	for _, chirp := range chirps {
+		f := formatChirp(chirp)
+		f.ReplyCount = replies[chirp.ID]
+		f.LikeCount = likes[chirp.ID]
+		f.RechirpCount = shares[chirp.ID].RechirpCount
+		f.QuoteCount = shares[chirp.ID].QuoteCount
+		if viewer.Valid {
+			likedByMe := liked[chirp.ID]
+			f.LikedByMe = &likedByMe
+		}
+		formatted = append(formatted, f)
+	}
+	return formatted, nil
+}
+
+// formatOneChirp is formatChirps for a single chirp.
+func (h *Handler) formatOneChirp(ctx context.Context, viewer uuid.NullUUID, chirp sqlc.Chirp) (Chirp, error) {
+	formatted, err := h.formatChirps(ctx, viewer, []sqlc.Chirp{chirp})
+	if err != nil {
+		return Chirp{}, err
+	}
+	return formatted[0], nil
+}
+
+func (h *Handler) CreateChirp(w http.ResponseWriter, r *http.Request) {
+	userID, ok := caller(w, r)
+	if !ok {
 		return
 	}
 
-	chirpData := map[string]string{}
+	chirpData := struct {
+		Body      string        `json:"body"`
+		InReplyTo uuid.NullUUID `json:"in_reply_to"`
+		RechirpOf uuid.NullUUID `json:"rechirp_of"`
+		QuoteOf   uuid.NullUUID `json:"quote_of"`
+	}{}
 	decoder := json.NewDecoder(r.Body)
-	err = decoder.Decode(&chirpData)
+	err := decoder.Decode(&chirpData)
 	if err != nil {
 		respond.WithError(w, http.StatusBadRequest, "Couldn't decode JSON", err)
 		return
 	}
 
-	chirpRecord, err := database.Queries().CreateChirp(r.Context(), sqlc.CreateChirpParams{
-		Body:   chirpData["body"],
+	params := sqlc.CreateChirpParams{
+		Body:   chirpData.Body,
 		UserID: uuid.NullUUID{UUID: userID, Valid: true},
+	}
+	references := 0
+	for _, ref := range []struct {
+		id     uuid.NullUUID
+		target *uuid.NullUUID
+		action string
+	}{
+		{chirpData.InReplyTo, &params.InReplyTo, "replying to"},
+		{chirpData.RechirpOf, &params.RechirpOf, "rechirping"},
+		{chirpData.QuoteOf, &params.QuoteOf, "quoting"},
+	} {
+		if !ref.id.Valid {
+			continue
+		}
+		references++
+		original, err := h.sharedChirp(r.Context(), ref.id.UUID)
+		if err != nil {
+			respond.WithError(w, http.StatusBadRequest, "Couldn't find the chirp you're "+ref.action, err)
+			return
+		}
+		*ref.target = uuid.NullUUID{UUID: original.ID, Valid: true}
+	}
+
+	switch {
+	case references > 1:
+		respond.WithError(w, http.StatusBadRequest, "A chirp can only reply to, rechirp or quote one chirp", nil)
+		return
+	case params.RechirpOf.Valid && params.Body != "":
+		respond.WithError(w, http.StatusBadRequest, "Rechirps can't have a body. Use quote_of to add one", nil)
+		return
+	case params.QuoteOf.Valid && params.Body == "":
+		respond.WithError(w, http.StatusBadRequest, "Quotes need a body", nil)
+		return
+	}
+
+	var cleaned content.Chirp
+	if !params.RechirpOf.Valid {
+		var ok bool
+		cleaned, ok = h.cleanChirp(w, r, params.UserID, params.Body)
+		if !ok {
+			return
+		}
+		params.Body = cleaned.Body
+	}
+
+	var chirpRecord sqlc.Chirp
+	err = h.store.WithTx(r.Context(), func(tx database.Store) error {
+		if params.RechirpOf.Valid {
+			chirpRecord, err = tx.CreateRechirp(r.Context(), sqlc.CreateRechirpParams{
+				UserID:    params.UserID,
+				RechirpOf: params.RechirpOf,
+			})
+		} else {
+			chirpRecord, err = tx.CreateChirp(r.Context(), params)
+		}
+		if err != nil {
+			return err
+		}
+		return saveChirpContent(r.Context(), tx, chirpRecord.ID, cleaned)
 	})
+	if params.RechirpOf.Valid && errors.Is(err, sql.ErrNoRows) {
+		respond.WithError(w, http.StatusConflict, "You've already rechirped this chirp", nil)
+		return
+	}
+	if database.IsUniqueViolation(err) {
+		respond.WithError(w, http.StatusConflict, "You already have a chirp that says this", err)
+		return
+	}
 	if err != nil {
 		respond.WithError(w, http.StatusInternalServerError, "Couldn't create chirp", err)
 		return
 	}
-	respond.WithJSON(w, http.StatusCreated, Chirp{
-		ID:        chirpRecord.ID,
-		CreatedAt: chirpRecord.CreatedAt,
-		UpdatedAt: chirpRecord.UpdatedAt,
-		Body:      chirpRecord.Body,
-		UserID:    chirpRecord.UserID,
-	})
-}
-
-func GetChirps(w http.ResponseWriter, r *http.Request) {
-	switch r.URL.Query().Get("author_id") == "" {
-	case true:
-		GetAllChirps(w, r)
-	case false:
-		GetChirpsByAuthor(w, r)
+	formatted, err := h.formatOneChirp(r.Context(), chirpRecord.UserID, chirpRecord)
+	if err != nil {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't create chirp", err)
+		return
 	}
+	respond.WithJSON(w, http.StatusCreated, formatted)
 }
 
-func GetAllChirps(w http.ResponseWriter, r *http.Request) {
-	unformattedChirps, err := database.Queries().GetAllChirps(r.Context())
+// saveChirpContent stores what the content pipeline found in a chirp's
+// body, replacing whatever was stored for an earlier version of it. Callers
+// pass the Store of the transaction that wrote the body.
+func saveChirpContent(ctx context.Context, store database.Store, chirpID uuid.UUID, cleaned content.Chirp) error {
+	err := store.DeleteChirpHashtags(ctx, chirpID)
 	if err != nil {
-		respond.WithError(w, http.StatusInternalServerError, "Couldn't get chirps", err)
-		return
+		return err
 	}
-
-	var formattedChirps []Chirp
-	for _, chirp := range unformattedChirps {
-		formattedChirps = append(formattedChirps, Chirp{
-			ID:        chirp.ID,
-			CreatedAt: chirp.CreatedAt,
-			UpdatedAt: chirp.UpdatedAt,
-			Body:      chirp.Body,
-			UserID:    chirp.UserID,
-		})
+	err = store.SetChirpHashtags(ctx, sqlc.SetChirpHashtagsParams{ChirpID: chirpID, Tags: cleaned.Hashtags})
+	if err != nil {
+		return err
 	}
-
-	sortOrder := r.URL.Query().Get("sort")
-	if sortOrder == "" || sortOrder == "asc" {
-		respond.WithJSON(w, http.StatusOK, formattedChirps)
-		return
+	err = store.DeleteChirpMentions(ctx, chirpID)
+	if err != nil {
+		return err
+	}
+	err = store.SetChirpMentions(ctx, sqlc.SetChirpMentionsParams{ChirpID: chirpID, Names: cleaned.Mentions})
+	if err != nil {
+		return err
 	}
+	return flagChirp(ctx, store, chirpID, cleaned.Flags)
+}
 
-	sort.Slice(formattedChirps, func(i, j int) bool {
-		return formattedChirps[i].CreatedAt.After(formattedChirps[j].CreatedAt)
-	})
+// sharedChirp loads the chirp that a new chirp wants to reply to, rechirp or
+// quote. A plain rechirp only points at somebody else's chirp, so it stands
+// in for that chirp instead of being shared itself.
+func (h *Handler) sharedChirp(ctx context.Context, id uuid.UUID) (sqlc.Chirp, error) {
+	chirp, err := h.store.GetChirpByID(ctx, id)
+	if err != nil || !chirp.RechirpOf.Valid {
+		return chirp, err
+	}
+	return h.store.GetChirpByID(ctx, chirp.RechirpOf.UUID)
+}
 
-	respond.WithJSON(w, http.StatusOK, formattedChirps)
+type ChirpPage struct {
+	Chirps     []Chirp `json:"chirps"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+	PrevCursor string  `json:"prev_cursor,omitempty"`
 }
 
-func GetChirpsByAuthor(w http.ResponseWriter, r *http.Request) {
-	authorID, err := uuid.Parse(r.URL.Query().Get("author_id"))
+func (h *Handler) GetChirps(w http.ResponseWriter, r *http.Request) {
+	p, err := parsePage(r.URL.Query())
 	if err != nil {
-		respond.WithError(w, http.StatusBadRequest, "Couldn't parse author ID", err)
+		respond.WithError(w, http.StatusBadRequest, err.Error(), err)
 		return
 	}
-
-	unformattedChirps, err := database.Queries().GetChirpsByAuthor(r.Context(), uuid.NullUUID{
-		UUID:  authorID,
-		Valid: true,
-	})
+	filter, err := parseChirpFilter(r.URL.Query())
 	if err != nil {
-		respond.WithError(w, http.StatusNotFound, "Couldn't get chirps", err)
+		respond.WithError(w, http.StatusBadRequest, err.Error(), err)
 		return
 	}
 
-	var formattedChirps []Chirp
-	for _, chirp := range unformattedChirps {
-		formattedChirps = append(formattedChirps, Chirp{
-			ID:        chirp.ID,
-			CreatedAt: chirp.CreatedAt,
-			UpdatedAt: chirp.UpdatedAt,
-			Body:      chirp.Body,
-			UserID:    chirp.UserID,
-		})
+	var records []sqlc.Chirp
+	var next, prev string
+	switch {
+	case filter.Query.Valid && (p.ByRelevance || r.URL.Query().Get("sort") == ""):
+		p.Descending, p.ByRelevance = true, true
+		records, next, prev, err = h.searchChirps(r.Context(), p, filter)
+	case p.ByRelevance:
+		respond.WithError(w, http.StatusBadRequest, "Sorting by relevance needs a search query", nil)
+		return
+	default:
+		records, next, prev, err = fetchPage(p, func(ascending bool, from *cursor, limit int32) ([]sqlc.Chirp, error) {
+			if ascending {
+				return h.store.ListChirpsAscending(r.Context(), sqlc.ListChirpsAscendingParams{
+					AuthorID:        filter.AuthorID,
+					Query:           filter.Query,
+					CreatedAfter:    filter.CreatedAfter,
+					CreatedBefore:   filter.CreatedBefore,
+					CursorCreatedAt: from.nullCreatedAt(),
+					CursorID:        from.nullID(),
+					Limit:           limit,
+				})
+			}
+			return h.store.ListChirpsDescending(r.Context(), sqlc.ListChirpsDescendingParams{
+				AuthorID:        filter.AuthorID,
+				Query:           filter.Query,
+				CreatedAfter:    filter.CreatedAfter,
+				CreatedBefore:   filter.CreatedBefore,
+				CursorCreatedAt: from.nullCreatedAt(),
+				CursorID:        from.nullID(),
+				Limit:           limit,
+			})
+		}, chirpCursor)
 	}
-	respond.WithJSON(w, http.StatusOK, formattedChirps)
-}
-
-func GetChirpByID(w http.ResponseWriter, r *http.Request) {
-	chirp, err := database.Queries().GetChirpByID(r.Context(), uuid.MustParse(r.PathValue("chirpID")))
 	if err != nil {
-		respond.WithError(w, http.StatusNotFound, "Couldn't get chirp", err)
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't get chirps", err)
 		return
 	}
 
-	respond.WithJSON(w, http.StatusOK, Chirp{
-		ID:        chirp.ID,
-		CreatedAt: chirp.CreatedAt,
-		UpdatedAt: chirp.UpdatedAt,
-		Body:      chirp.Body,
-		UserID:    chirp.UserID,
+	formattedChirps, err := h.formatChirps(r.Context(), viewer(r), records)
+	if err != nil {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't get chirps", err)
+		return
+	}
+	respond.WithJSON(w, http.StatusOK, ChirpPage{
+		Chirps:     formattedChirps,
+		NextCursor: next,
+		PrevCursor: prev,
 	})
 }
 
-func DeleteChirp(w http.ResponseWriter, r *http.Request) {
-	token, err := auth.GetBearerToken(r.Header)
+func chirpCursor(chirp sqlc.Chirp) cursor {
+	return cursor{CreatedAt: chirp.CreatedAt, ID: chirp.ID}
+}
+
+func (h *Handler) GetChirpByID(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
 	if err != nil {
-		respond.WithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		respond.WithError(w, http.StatusBadRequest, "Couldn't parse chirp ID", err)
 		return
 	}
-	userID, err := auth.ValidateJWT(token, config.APIConfig().JWTSecret)
+	chirp, err := h.store.GetChirpByID(r.Context(), chirpID)
 	if err != nil {
-		respond.WithError(w, http.StatusUnauthorized, "Unauthorized. JWT not valid", err)
+		respond.WithError(w, http.StatusNotFound, "Couldn't get chirp", err)
 		return
 	}
 
-	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	formatted, err := h.formatOneChirp(r.Context(), viewer(r), chirp)
 	if err != nil {
-		respond.WithError(w, http.StatusBadRequest, "Couldn't parse chirp ID", err)
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't get chirp", err)
 		return
 	}
+	respond.WithJSON(w, http.StatusOK, formatted)
+}
 
-	chirp, err := database.Queries().GetChirpByID(r.Context(), chirpID)
-	if err != nil {
-		respond.WithError(w, http.StatusNotFound, "Couldn't get chirp", err)
+func (h *Handler) DeleteChirp(w http.ResponseWriter, r *http.Request) {
+	chirp, ok := h.ownChirp(w, r, "delete")
+	if !ok {
 		return
 	}
-	if chirp.UserID.UUID != userID {
-		respond.WithError(w, http.StatusForbidden, "Unauthorized. You can't delete this chirp", err)
-	}
 
-	err = database.Queries().DeleteChirp(r.Context(), sqlc.DeleteChirpParams{
-		ID: chirpID,
-		UserID: uuid.NullUUID{
-			UUID:  userID,
-			Valid: true,
-		},
-	})
+	var err error
+	if chirp.RechirpOf.Valid {
+		// A plain rechirp has nothing worth restoring, so undoing it is final.
+		err = h.store.DeleteRechirp(r.Context(), sqlc.DeleteRechirpParams{
+			ID:     chirp.ID,
+			UserID: chirp.UserID,
+		})
+	} else {
+		err = h.store.TrashChirp(r.Context(), sqlc.TrashChirpParams{
+			ID:     chirp.ID,
+			UserID: chirp.UserID,
+		})
+	}
 	if err != nil {
 		respond.WithError(w, http.StatusInternalServerError, "Couldn't delete chirp", err)
 		return
@@ -185,3 +414,30 @@ func DeleteChirp(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// ownChirp loads the chirp named in the path and checks that the caller
+// wrote it. If not, it has already responded and returns false.
+func (h *Handler) ownChirp(w http.ResponseWriter, r *http.Request, action string) (sqlc.Chirp, bool) {
+	userID, ok := caller(w, r)
+	if !ok {
+		return sqlc.Chirp{}, false
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respond.WithError(w, http.StatusBadRequest, "Couldn't parse chirp ID", err)
+		return sqlc.Chirp{}, false
+	}
+
+	chirp, err := h.store.GetChirpByID(r.Context(), chirpID)
+	if err != nil {
+		respond.WithError(w, http.StatusNotFound, "Couldn't get chirp", err)
+		return sqlc.Chirp{}, false
+	}
+	if chirp.UserID.UUID != userID {
+		respond.WithError(w, http.StatusForbidden, "Unauthorized. You can't "+action+" this chirp", err)
+		return sqlc.Chirp{}, false
+	}
+
+	return chirp, true
+}
@@ -0,0 +1,398 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/pcauce/chirpy/internal/archive"
+	"github.com/pcauce/chirpy/internal/config"
+	"github.com/pcauce/chirpy/internal/content"
+	"github.com/pcauce/chirpy/internal/database"
+	"github.com/pcauce/chirpy/internal/sqlc"
+	"github.com/pcauce/chirpy/server/respond"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+)
+
+const (
+	// exportTimeout is how long building an export can take. An export
+	// claimed longer ago than that is taken to have been abandoned by the
+	// instance building it and is built again.
+	exportTimeout = 10 * time.Minute
+	// maxImportSize is the largest archive ImportUser accepts.
+	maxImportSize = 32 << 20
+)
+
+// Export is an archive of a user's data, built in the background.
+// DownloadURL is set once it is ready.
+type Export struct {
+	ID          uuid.UUID  `json:"id"`
+	Status      string     `json:"status"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	DownloadURL string     `json:"download_url,omitempty"`
+}
+
+type ImportResult struct {
+	Imported int            `json:"imported"`
+	Skipped  []SkippedChirp `json:"skipped"`
+}
+
+// SkippedChirp is a chirp in an archive that ImportUser didn't recreate,
+// and why.
+type SkippedChirp struct {
+	ID     uuid.UUID `json:"id"`
+	Reason string    `json:"reason"`
+}
+
+// ExportUser queues an archive of the caller's profile, chirps and sessions
+// for BuildExports and responds with where to download it once it's ready.
+// If one is already queued, it responds with that one instead.
+func (h *Handler) ExportUser(w http.ResponseWriter, r *http.Request) {
+	userID, ok := caller(w, r)
+	if !ok {
+		return
+	}
+
+	latest, err := h.store.GetLatestExport(r.Context(), userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't export data", err)
+		return
+	}
+	if err == nil && latest.Status == "pending" {
+		respond.WithJSON(w, http.StatusAccepted, formatExport(latest))
+		return
+	}
+
+	export, err := h.store.CreateExport(r.Context(), userID)
+	if err != nil {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't export data", err)
+		return
+	}
+	respond.WithJSON(w, http.StatusAccepted, formatExport(export))
+}
+
+// DownloadExport serves an export archive once it's ready, with support for
+// range and conditional requests so a broken download can be resumed. Until
+// then it responds with the export's status.
+func (h *Handler) DownloadExport(w http.ResponseWriter, r *http.Request) {
+	userID, ok := caller(w, r)
+	if !ok {
+		return
+	}
+
+	exportID, err := uuid.Parse(r.PathValue("exportID"))
+	if err != nil {
+		respond.WithError(w, http.StatusBadRequest, "Couldn't parse export ID", err)
+		return
+	}
+	export, err := h.store.GetExport(r.Context(), sqlc.GetExportParams{ID: exportID, UserID: userID})
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && time.Now().After(exportExpiry(export))) {
+		respond.WithError(w, http.StatusNotFound, "Couldn't find export", err)
+		return
+	}
+	if err != nil {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't get export", err)
+		return
+	}
+
+	switch export.Status {
+	case "pending":
+		respond.WithJSON(w, http.StatusAccepted, formatExport(export))
+	case "failed":
+		respond.WithError(w, http.StatusInternalServerError, "Export failed. Request a new one", nil)
+	default:
+		name := "chirpy-export-" + export.CreatedAt.Format("2006-01-02") + ".zip"
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+		http.ServeContent(w, r, name, export.CreatedAt, bytes.NewReader(export.Archive))
+	}
+}
+
+// BuildExports builds every pending export that no other instance is
+// building, oldest first. It's meant to run as a job. An export that has
+// been started is finished even once ctx is done, so shutting down doesn't
+// leave it pending until its claim times out.
+func (h *Handler) BuildExports(ctx context.Context) error {
+	for ctx.Err() == nil {
+		claimedBefore := sql.NullTime{Time: time.Now().UTC().Add(-exportTimeout), Valid: true}
+		export, err := h.store.ClaimExport(ctx, claimedBefore)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		buildCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), exportTimeout)
+		err = h.buildExport(buildCtx, export)
+		cancel()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildExport gathers the user's data into an archive and saves it on
+// export. A failure to gather it is recorded on the export, since building
+// it again wouldn't help; only failing to save the result is returned.
+func (h *Handler) buildExport(ctx context.Context, export sqlc.Export) error {
+	status := "ready"
+	data, err := h.exportArchive(ctx, export)
+	if err != nil {
+		log.Printf("Couldn't build export %s: %v", export.ID, err)
+		status, data = "failed", nil
+	}
+	return h.store.CompleteExport(ctx, sqlc.CompleteExportParams{
+		ID:      export.ID,
+		Status:  status,
+		Archive: data,
+	})
+}
+
+func (h *Handler) exportArchive(ctx context.Context, export sqlc.Export) ([]byte, error) {
+	user, err := h.store.GetUserByID(ctx, export.UserID)
+	if err != nil {
+		return nil, err
+	}
+	owner := uuid.NullUUID{UUID: user.ID, Valid: true}
+	chirps, err := h.store.ListUserChirps(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := h.store.ListUserSessions(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	a := archive.Archive{
+		Profile: archive.Profile{
+			ID:          user.ID,
+			Email:       user.Email,
+			Handle:      nullableHandle(user.Handle),
+			DisplayName: user.DisplayName,
+			Bio:         user.Bio,
+			IsChirpyRed: user.IsChirpyRed,
+			CreatedAt:   user.CreatedAt,
+			UpdatedAt:   user.UpdatedAt,
+		},
+	}
+	for _, chirp := range chirps {
+		a.Chirps = append(a.Chirps, archive.Chirp{
+			ID:        chirp.ID,
+			CreatedAt: chirp.CreatedAt,
+			UpdatedAt: chirp.UpdatedAt,
+			Body:      chirp.Body,
+			DeletedAt: nullableTime(chirp.DeletedAt),
+			InReplyTo: chirp.InReplyTo,
+			RechirpOf: chirp.RechirpOf,
+			QuoteOf:   chirp.QuoteOf,
+		})
+	}
+	for _, session := range sessions {
+		a.Sessions = append(a.Sessions, archive.Session{
+			UserAgent:  session.UserAgent,
+			IP:         session.IP,
+			CreatedAt:  session.CreatedAt,
+			LastUsedAt: session.LastUsedAt,
+			ExpiresAt:  session.ExpiresAt,
+			RevokedAt:  nullableTime(session.RevokedAt),
+		})
+	}
+
+	var buf bytes.Buffer
+	err = archive.Write(&buf, a, export.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ImportUser recreates the chirps in an export archive under the caller's
+// account, keeping the times they were posted and edited. Replies, quotes
+// and rechirps of chirps in the same archive point at the new copies;
+// references to chirps that no longer exist are dropped. Chirps that were in
+// the trash, break a content rule or that the caller has already posted or
+// rechirped are skipped and listed in the response. Everything else in the
+// archive is left as it is.
+func (h *Handler) ImportUser(w http.ResponseWriter, r *http.Request) {
+	userID, ok := caller(w, r)
+	if !ok {
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImportSize))
+	if err != nil {
+		respond.WithError(w, http.StatusRequestEntityTooLarge, "Archive is too large", err)
+		return
+	}
+	a, _, err := archive.Read(data)
+	if err != nil {
+		respond.WithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	owner := uuid.NullUUID{UUID: userID, Valid: true}
+	pipeline, err := h.pipelineFor(r.Context(), owner)
+	if err != nil {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't import chirps", err)
+		return
+	}
+
+	chirps := slices.Clone(a.Chirps)
+	slices.SortStableFunc(chirps, func(a, b archive.Chirp) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	result := ImportResult{Skipped: []SkippedChirp{}}
+	imported := map[uuid.UUID]uuid.UUID{}
+	for _, chirp := range chirps {
+		reason, err := h.importChirp(r.Context(), pipeline, owner, chirp, imported)
+		if err != nil {
+			respond.WithError(w, http.StatusInternalServerError, "Couldn't import chirps", err)
+			return
+		}
+		if reason != "" {
+			result.Skipped = append(result.Skipped, SkippedChirp{ID: chirp.ID, Reason: reason})
+			continue
+		}
+		result.Imported++
+	}
+	respond.WithJSON(w, http.StatusOK, result)
+}
+
+// importChirp recreates one archived chirp for owner and records its new ID
+// in imported. It returns why the chirp was skipped, if it was.
+func (h *Handler) importChirp(ctx context.Context, pipeline *content.Pipeline, owner uuid.NullUUID, chirp archive.Chirp, imported map[uuid.UUID]uuid.UUID) (string, error) {
+	if chirp.DeletedAt != nil {
+		return "Chirp was in the trash", nil
+	}
+	if chirp.RechirpOf.Valid {
+		return h.importRechirp(ctx, owner, chirp, imported)
+	}
+
+	params := sqlc.ImportChirpParams{
+		CreatedAt: chirp.CreatedAt,
+		UpdatedAt: chirp.UpdatedAt,
+		Body:      chirp.Body,
+		UserID:    owner,
+	}
+	var err error
+	for _, ref := range []struct {
+		id     uuid.NullUUID
+		target *uuid.NullUUID
+	}{
+		{chirp.InReplyTo, &params.InReplyTo},
+		{chirp.QuoteOf, &params.QuoteOf},
+	} {
+		*ref.target, err = h.importedRef(ctx, ref.id, imported)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	cleaned, err := pipeline.Run(params.Body)
+	var invalid *content.ValidationError
+	if errors.As(err, &invalid) {
+		return invalid.Error(), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	params.Body = cleaned.Body
+
+	var record sqlc.Chirp
+	err = h.store.WithTx(ctx, func(tx database.Store) error {
+		record, err = tx.ImportChirp(ctx, params)
+		if err != nil {
+			return err
+		}
+		return saveChirpContent(ctx, tx, record.ID, cleaned)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return "You already have a chirp that says this", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	imported[chirp.ID] = record.ID
+	return "", nil
+}
+
+// importRechirp recreates an archived rechirp for owner, of the new copy of
+// the chirp if it was imported and of the original otherwise.
+func (h *Handler) importRechirp(ctx context.Context, owner uuid.NullUUID, chirp archive.Chirp, imported map[uuid.UUID]uuid.UUID) (string, error) {
+	rechirpOf, err := h.importedRef(ctx, chirp.RechirpOf, imported)
+	if err != nil {
+		return "", err
+	}
+	if !rechirpOf.Valid {
+		return "The rechirped chirp no longer exists", nil
+	}
+
+	record, err := h.store.ImportRechirp(ctx, sqlc.ImportRechirpParams{
+		CreatedAt: chirp.CreatedAt,
+		UpdatedAt: chirp.UpdatedAt,
+		UserID:    owner,
+		RechirpOf: rechirpOf,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return "You've already rechirped this chirp", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	imported[chirp.ID] = record.ID
+	return "", nil
+}
+
+// importedRef resolves a reference from an archived chirp: to the new copy
+// of the chirp if it was imported, to the original if it still exists, and
+// to nothing otherwise.
+func (h *Handler) importedRef(ctx context.Context, ref uuid.NullUUID, imported map[uuid.UUID]uuid.UUID) (uuid.NullUUID, error) {
+	if !ref.Valid {
+		return uuid.NullUUID{}, nil
+	}
+	if id, ok := imported[ref.UUID]; ok {
+		return uuid.NullUUID{UUID: id, Valid: true}, nil
+	}
+	_, err := h.store.GetChirpByID(ctx, ref.UUID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.NullUUID{}, nil
+	}
+	if err != nil {
+		return uuid.NullUUID{}, err
+	}
+	return ref, nil
+}
+
+func formatExport(export sqlc.Export) Export {
+	formatted := Export{
+		ID:          export.ID,
+		Status:      export.Status,
+		CreatedAt:   export.CreatedAt,
+		CompletedAt: nullableTime(export.CompletedAt),
+		ExpiresAt:   exportExpiry(export),
+	}
+	if export.Status == "ready" {
+		formatted.DownloadURL = "/api/users/me/exports/" + export.ID.String()
+	}
+	return formatted
+}
+
+// exportExpiry is when the purge job deletes an export.
+func exportExpiry(export sqlc.Export) time.Time {
+	return export.CreatedAt.Add(config.APIConfig().ExportRetention)
+}
+
+func nullableTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
@@ -0,0 +1,252 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/pcauce/chirpy/internal/content"
+	"github.com/pcauce/chirpy/internal/database"
+	"github.com/pcauce/chirpy/internal/sqlc"
+	"github.com/pcauce/chirpy/server/respond"
+	"net/http"
+	"slices"
+	"time"
+)
+
+type FilterTerm struct {
+	ID        uuid.UUID `json:"id"`
+	Term      string    `json:"term"`
+	Action    string    `json:"action"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// FlaggedChirp is a chirp waiting for a moderator, with every filter term
+// that flagged it.
+type FlaggedChirp struct {
+	Chirp     Chirp     `json:"chirp"`
+	Terms     []string  `json:"terms"`
+	FlaggedAt time.Time `json:"flagged_at"`
+}
+
+// ReloadFilter replaces the terms the content filter looks for with the ones
+// in the store. It runs at startup, on a timer so that every instance picks
+// up changes, and straight after an admin changes a term.
+func (h *Handler) ReloadFilter(ctx context.Context) error {
+	records, err := h.store.ListFilterTerms(ctx)
+	if err != nil {
+		return err
+	}
+	terms := make([]content.Term, 0, len(records))
+	for _, record := range records {
+		terms = append(terms, content.Term{Term: record.Term, Action: content.Action(record.Action)})
+	}
+	h.filter.Replace(terms)
+	return nil
+}
+
+func (h *Handler) GetFilterTerms(w http.ResponseWriter, r *http.Request) {
+	records, err := h.store.ListFilterTerms(r.Context())
+	if err != nil {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't get filter terms", err)
+		return
+	}
+	terms := []FilterTerm{}
+	for _, record := range records {
+		terms = append(terms, formatFilterTerm(record))
+	}
+	respond.WithJSON(w, http.StatusOK, terms)
+}
+
+// CreateFilterTerm adds a term to the filter. The term is stored folded, so
+// "Sh4rbert" is saved as "sharbert" and matches every way of writing it.
+func (h *Handler) CreateFilterTerm(w http.ResponseWriter, r *http.Request) {
+	var params struct {
+		Term   string `json:"term"`
+		Action string `json:"action"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&params)
+	if err != nil {
+		respond.WithError(w, http.StatusBadRequest, "Couldn't decode JSON", err)
+		return
+	}
+	term, err := content.NewTerm(params.Term, content.Action(params.Action))
+	if err != nil {
+		respond.WithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	existing, err := h.store.ListFilterTerms(r.Context())
+	if err != nil {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't create filter term", err)
+		return
+	}
+	if slices.ContainsFunc(existing, func(record sqlc.FilterTerm) bool { return record.Term == term.Term }) {
+		respond.WithError(w, http.StatusConflict, "Term is already in the filter", nil)
+		return
+	}
+
+	record, err := h.store.CreateFilterTerm(r.Context(), sqlc.CreateFilterTermParams{
+		Term:   term.Term,
+		Action: string(term.Action),
+	})
+	if err != nil {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't create filter term", err)
+		return
+	}
+	if !h.reloadAfterChange(w, r) {
+		return
+	}
+	respond.WithJSON(w, http.StatusCreated, formatFilterTerm(record))
+}
+
+// UpdateFilterTerm changes what the filter does with a term. To change the
+// term itself, delete it and create a new one.
+func (h *Handler) UpdateFilterTerm(w http.ResponseWriter, r *http.Request) {
+	termID, err := uuid.Parse(r.PathValue("termID"))
+	if err != nil {
+		respond.WithError(w, http.StatusBadRequest, "Couldn't parse term ID", err)
+		return
+	}
+	var params struct {
+		Action string `json:"action"`
+	}
+	err = json.NewDecoder(r.Body).Decode(&params)
+	if err != nil {
+		respond.WithError(w, http.StatusBadRequest, "Couldn't decode JSON", err)
+		return
+	}
+	if !content.Action(params.Action).Valid() {
+		respond.WithError(w, http.StatusBadRequest, "Action must be 'mask', 'reject' or 'flag'", nil)
+		return
+	}
+
+	record, err := h.store.UpdateFilterTerm(r.Context(), sqlc.UpdateFilterTermParams{
+		ID:     termID,
+		Action: params.Action,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respond.WithError(w, http.StatusNotFound, "Couldn't find filter term", err)
+		return
+	}
+	if err != nil {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't update filter term", err)
+		return
+	}
+	if !h.reloadAfterChange(w, r) {
+		return
+	}
+	respond.WithJSON(w, http.StatusOK, formatFilterTerm(record))
+}
+
+func (h *Handler) DeleteFilterTerm(w http.ResponseWriter, r *http.Request) {
+	termID, err := uuid.Parse(r.PathValue("termID"))
+	if err != nil {
+		respond.WithError(w, http.StatusBadRequest, "Couldn't parse term ID", err)
+		return
+	}
+	deleted, err := h.store.DeleteFilterTerm(r.Context(), termID)
+	if err != nil {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't delete filter term", err)
+		return
+	}
+	if deleted == 0 {
+		respond.WithError(w, http.StatusNotFound, "Couldn't find filter term", nil)
+		return
+	}
+	if !h.reloadAfterChange(w, r) {
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// reloadAfterChange reloads the filter so a term change applies to the next
+// chirp rather than the next tick. If it fails, it has already responded and
+// returns false; the change itself is saved either way.
+func (h *Handler) reloadAfterChange(w http.ResponseWriter, r *http.Request) bool {
+	err := h.ReloadFilter(r.Context())
+	if err != nil {
+		respond.WithError(w, http.StatusInternalServerError, "Saved, but couldn't reload the filter", err)
+		return false
+	}
+	return true
+}
+
+// GetFlaggedChirps lists the chirps a flag term was found in, oldest flag
+// first. Chirps in the trash are left out.
+func (h *Handler) GetFlaggedChirps(w http.ResponseWriter, r *http.Request) {
+	rows, err := h.store.ListFlaggedChirps(r.Context())
+	if err != nil {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't get flagged chirps", err)
+		return
+	}
+
+	var records []sqlc.Chirp
+	terms := map[uuid.UUID][]string{}
+	flaggedAt := map[uuid.UUID]time.Time{}
+	for _, row := range rows {
+		if _, seen := terms[row.Chirp.ID]; !seen {
+			records = append(records, row.Chirp)
+			flaggedAt[row.Chirp.ID] = row.FlaggedAt
+		}
+		terms[row.Chirp.ID] = append(terms[row.Chirp.ID], row.Term)
+	}
+	formatted, err := h.formatChirps(r.Context(), uuid.NullUUID{}, records)
+	if err != nil {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't get flagged chirps", err)
+		return
+	}
+
+	flagged := []FlaggedChirp{}
+	for _, chirp := range formatted {
+		flagged = append(flagged, FlaggedChirp{
+			Chirp:     chirp,
+			Terms:     terms[chirp.ID],
+			FlaggedAt: flaggedAt[chirp.ID],
+		})
+	}
+	respond.WithJSON(w, http.StatusOK, flagged)
+}
+
+// DismissChirpFlags marks a flagged chirp as reviewed. It stays up; trash it
+// to take it down.
+func (h *Handler) DismissChirpFlags(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respond.WithError(w, http.StatusBadRequest, "Couldn't parse chirp ID", err)
+		return
+	}
+	cleared, err := h.store.ClearChirpFlags(r.Context(), chirpID)
+	if err != nil {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't dismiss flags", err)
+		return
+	}
+	if cleared == 0 {
+		respond.WithError(w, http.StatusNotFound, "Chirp isn't flagged", nil)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// flagChirp records each of terms against a chirp for review.
+func flagChirp(ctx context.Context, store database.Store, chirpID uuid.UUID, terms []string) error {
+	for _, term := range terms {
+		err := store.FlagChirp(ctx, sqlc.FlagChirpParams{ChirpID: chirpID, Term: term})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatFilterTerm(record sqlc.FilterTerm) FilterTerm {
+	return FilterTerm{
+		ID:        record.ID,
+		Term:      record.Term,
+		Action:    record.Action,
+		CreatedAt: record.CreatedAt,
+		UpdatedAt: record.UpdatedAt,
+	}
+}
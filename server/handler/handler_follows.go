@@ -0,0 +1,220 @@
+package handler
+
+import (
+	"github.com/google/uuid"
+	"github.com/pcauce/chirpy/internal/sqlc"
+	"github.com/pcauce/chirpy/server/respond"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// FollowedUser is one entry in a follower or following list.
+type FollowedUser struct {
+	ID         uuid.UUID `json:"id"`
+	FollowedAt time.Time `json:"followed_at"`
+}
+
+type FollowPage struct {
+	Users      []FollowedUser `json:"users"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+	PrevCursor string         `json:"prev_cursor,omitempty"`
+}
+
+// FollowUser makes the caller follow the user in the path. Following someone
+// twice is not an error.
+func (h *Handler) FollowUser(w http.ResponseWriter, r *http.Request) {
+	h.setFollow(w, r, true)
+}
+
+func (h *Handler) UnfollowUser(w http.ResponseWriter, r *http.Request) {
+	h.setFollow(w, r, false)
+}
+
+func (h *Handler) setFollow(w http.ResponseWriter, r *http.Request, follow bool) {
+	followerID, ok := caller(w, r)
+	if !ok {
+		return
+	}
+	followee, ok := h.pathUser(w, r)
+	if !ok {
+		return
+	}
+	if followee.ID == followerID {
+		respond.WithError(w, http.StatusBadRequest, "You can't follow yourself", nil)
+		return
+	}
+
+	var err error
+	if follow {
+		err = h.store.FollowUser(r.Context(), sqlc.FollowUserParams{FollowerID: followerID, FolloweeID: followee.ID})
+	} else {
+		err = h.store.UnfollowUser(r.Context(), sqlc.UnfollowUserParams{FollowerID: followerID, FolloweeID: followee.ID})
+	}
+	if err != nil {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't update follow", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetFollowers lists who follows the user in the path, in the order they
+// followed them.
+func (h *Handler) GetFollowers(w http.ResponseWriter, r *http.Request) {
+	h.listFollows(w, r, func(userID uuid.UUID, ascending bool, from *cursor, limit int32) ([]sqlc.Follow, error) {
+		if ascending {
+			return h.store.ListFollowersAscending(r.Context(), sqlc.ListFollowersAscendingParams{
+				FolloweeID:      userID,
+				CursorCreatedAt: from.nullCreatedAt(),
+				CursorID:        from.nullID(),
+				Limit:           limit,
+			})
+		}
+		return h.store.ListFollowersDescending(r.Context(), sqlc.ListFollowersDescendingParams{
+			FolloweeID:      userID,
+			CursorCreatedAt: from.nullCreatedAt(),
+			CursorID:        from.nullID(),
+			Limit:           limit,
+		})
+	}, func(follow sqlc.Follow) uuid.UUID {
+		return follow.FollowerID
+	})
+}
+
+// GetFollowing lists who the user in the path follows, in the order they
+// followed them.
+func (h *Handler) GetFollowing(w http.ResponseWriter, r *http.Request) {
+	h.listFollows(w, r, func(userID uuid.UUID, ascending bool, from *cursor, limit int32) ([]sqlc.Follow, error) {
+		if ascending {
+			return h.store.ListFollowingAscending(r.Context(), sqlc.ListFollowingAscendingParams{
+				FollowerID:      userID,
+				CursorCreatedAt: from.nullCreatedAt(),
+				CursorID:        from.nullID(),
+				Limit:           limit,
+			})
+		}
+		return h.store.ListFollowingDescending(r.Context(), sqlc.ListFollowingDescendingParams{
+			FollowerID:      userID,
+			CursorCreatedAt: from.nullCreatedAt(),
+			CursorID:        from.nullID(),
+			Limit:           limit,
+		})
+	}, func(follow sqlc.Follow) uuid.UUID {
+		return follow.FolloweeID
+	})
+}
+
+// listFollows responds with a page of the follows query returns for the user
+// in the path. other picks the user each follow is listed as.
+func (h *Handler) listFollows(
+	w http.ResponseWriter,
+	r *http.Request,
+	query func(userID uuid.UUID, ascending bool, from *cursor, limit int32) ([]sqlc.Follow, error),
+	other func(sqlc.Follow) uuid.UUID,
+) {
+	user, ok := h.pathUser(w, r)
+	if !ok {
+		return
+	}
+	p, err := parsePage(r.URL.Query())
+	if err != nil {
+		respond.WithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	if p.ByRelevance {
+		respond.WithError(w, http.StatusBadRequest, "Sorting by relevance needs a search query", nil)
+		return
+	}
+
+	follows, next, prev, err := fetchPage(p, func(ascending bool, from *cursor, limit int32) ([]sqlc.Follow, error) {
+		return query(user.ID, ascending, from, limit)
+	}, func(follow sqlc.Follow) cursor {
+		return cursor{CreatedAt: follow.CreatedAt, ID: other(follow)}
+	})
+	if err != nil {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't get follows", err)
+		return
+	}
+
+	users := []FollowedUser{}
+	for _, follow := range follows {
+		users = append(users, FollowedUser{ID: other(follow), FollowedAt: follow.CreatedAt})
+	}
+	respond.WithJSON(w, http.StatusOK, FollowPage{
+		Users:      users,
+		NextCursor: next,
+		PrevCursor: prev,
+	})
+}
+
+// GetTimeline lists chirps by the caller and everyone they follow, newest
+// first unless ?sort=asc is given.
+func (h *Handler) GetTimeline(w http.ResponseWriter, r *http.Request) {
+	userID, ok := caller(w, r)
+	if !ok {
+		return
+	}
+	p, err := parsePage(r.URL.Query())
+	if err != nil {
+		respond.WithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	if p.ByRelevance {
+		respond.WithError(w, http.StatusBadRequest, "Sorting by relevance needs a search query", nil)
+		return
+	}
+	if r.URL.Query().Get("sort") == "" {
+		p.Descending = true
+	}
+
+	records, next, prev, err := fetchPage(p, func(ascending bool, from *cursor, limit int32) ([]sqlc.Chirp, error) {
+		if ascending {
+			return h.store.ListTimelineAscending(r.Context(), sqlc.ListTimelineAscendingParams{
+				ViewerID:        userID,
+				CursorCreatedAt: from.nullCreatedAt(),
+				CursorID:        from.nullID(),
+				Limit:           limit,
+			})
+		}
+		return h.store.ListTimelineDescending(r.Context(), sqlc.ListTimelineDescendingParams{
+			ViewerID:        userID,
+			CursorCreatedAt: from.nullCreatedAt(),
+			CursorID:        from.nullID(),
+			Limit:           limit,
+		})
+	}, chirpCursor)
+	if err != nil {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't get timeline", err)
+		return
+	}
+
+	formattedChirps, err := h.formatChirps(r.Context(), uuid.NullUUID{UUID: userID, Valid: true}, records)
+	if err != nil {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't get timeline", err)
+		return
+	}
+	respond.WithJSON(w, http.StatusOK, ChirpPage{
+		Chirps:     formattedChirps,
+		NextCursor: next,
+		PrevCursor: prev,
+	})
+}
+
+// pathUser loads the user named in the path, by ID or by handle. If there is
+// none, it has already responded and returns false.
+func (h *Handler) pathUser(w http.ResponseWriter, r *http.Request) (sqlc.User, bool) {
+	ref := r.PathValue("userID")
+	var user sqlc.User
+	var err error
+	if userID, parseErr := uuid.Parse(ref); parseErr == nil {
+		user, err = h.store.GetUserByID(r.Context(), userID)
+	} else {
+		user, err = h.store.GetUserByHandle(r.Context(), strings.TrimPrefix(ref, "@"))
+	}
+	if err != nil {
+		respond.WithError(w, http.StatusNotFound, "Couldn't get user", err)
+		return sqlc.User{}, false
+	}
+	return user, true
+}
@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"github.com/pcauce/chirpy/internal/content"
+	"github.com/pcauce/chirpy/internal/sqlc"
+	"github.com/pcauce/chirpy/server/respond"
+	"net/http"
+)
+
+// GetHashtagChirps lists the chirps tagged with the hashtag in the path,
+// paginated like GetChirps. The tag matches however it was written, so
+// /api/hashtags/Go and /api/hashtags/go list the same chirps.
+func (h *Handler) GetHashtagChirps(w http.ResponseWriter, r *http.Request) {
+	tag, ok := content.NormalizeTag(r.PathValue("tag"))
+	if !ok {
+		respond.WithError(w, http.StatusBadRequest, "Not a valid hashtag", nil)
+		return
+	}
+	p, err := parsePage(r.URL.Query())
+	if err != nil {
+		respond.WithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	if p.ByRelevance {
+		respond.WithError(w, http.StatusBadRequest, "Sorting by relevance needs a search query", nil)
+		return
+	}
+
+	records, next, prev, err := fetchPage(p, func(ascending bool, from *cursor, limit int32) ([]sqlc.Chirp, error) {
+		if ascending {
+			return h.store.ListHashtagChirpsAscending(r.Context(), sqlc.ListHashtagChirpsAscendingParams{
+				Tag:             tag,
+				CursorCreatedAt: from.nullCreatedAt(),
+				CursorID:        from.nullID(),
+				Limit:           limit,
+			})
+		}
+		return h.store.ListHashtagChirpsDescending(r.Context(), sqlc.ListHashtagChirpsDescendingParams{
+			Tag:             tag,
+			CursorCreatedAt: from.nullCreatedAt(),
+			CursorID:        from.nullID(),
+			Limit:           limit,
+		})
+	}, chirpCursor)
+	if err != nil {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't get chirps", err)
+		return
+	}
+
+	formattedChirps, err := h.formatChirps(r.Context(), viewer(r), records)
+	if err != nil {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't get chirps", err)
+		return
+	}
+	respond.WithJSON(w, http.StatusOK, ChirpPage{
+		Chirps:     formattedChirps,
+		NextCursor: next,
+		PrevCursor: prev,
+	})
+}
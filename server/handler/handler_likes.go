@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"github.com/google/uuid"
+	"github.com/pcauce/chirpy/internal/sqlc"
+	"github.com/pcauce/chirpy/server/respond"
+	"net/http"
+)
+
+// LikeChirp likes the chirp in the path for the caller. Liking a chirp twice
+// is not an error; it stays liked once.
+func (h *Handler) LikeChirp(w http.ResponseWriter, r *http.Request) {
+	h.setLike(w, r, true)
+}
+
+func (h *Handler) UnlikeChirp(w http.ResponseWriter, r *http.Request) {
+	h.setLike(w, r, false)
+}
+
+// setLike likes or unlikes the chirp in the path and responds with the chirp
+// as the caller now sees it.
+func (h *Handler) setLike(w http.ResponseWriter, r *http.Request, like bool) {
+	userID, ok := caller(w, r)
+	if !ok {
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respond.WithError(w, http.StatusBadRequest, "Couldn't parse chirp ID", err)
+		return
+	}
+	chirp, err := h.store.GetChirpByID(r.Context(), chirpID)
+	if err != nil {
+		respond.WithError(w, http.StatusNotFound, "Couldn't get chirp", err)
+		return
+	}
+
+	if like {
+		err = h.store.LikeChirp(r.Context(), sqlc.LikeChirpParams{UserID: userID, ChirpID: chirp.ID})
+	} else {
+		err = h.store.UnlikeChirp(r.Context(), sqlc.UnlikeChirpParams{UserID: userID, ChirpID: chirp.ID})
+	}
+	if err != nil {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't update like", err)
+		return
+	}
+
+	formatted, err := h.formatOneChirp(r.Context(), uuid.NullUUID{UUID: userID, Valid: true}, chirp)
+	if err != nil {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't update like", err)
+		return
+	}
+	respond.WithJSON(w, http.StatusOK, formatted)
+}
+
+// GetUserLikes lists the chirps a user has liked, in the order they liked
+// them. Chirps that have since been deleted are left out.
+func (h *Handler) GetUserLikes(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.pathUser(w, r)
+	if !ok {
+		return
+	}
+	p, err := parsePage(r.URL.Query())
+	if err != nil {
+		respond.WithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	if p.ByRelevance {
+		respond.WithError(w, http.StatusBadRequest, "Sorting by relevance needs a search query", nil)
+		return
+	}
+
+	rows, next, prev, err := fetchPage(p, func(ascending bool, from *cursor, limit int32) ([]sqlc.ListUserLikesDescendingRow, error) {
+		if !ascending {
+			return h.store.ListUserLikesDescending(r.Context(), sqlc.ListUserLikesDescendingParams{
+				UserID:          user.ID,
+				CursorCreatedAt: from.nullCreatedAt(),
+				CursorID:        from.nullID(),
+				Limit:           limit,
+			})
+		}
+		ascendingRows, err := h.store.ListUserLikesAscending(r.Context(), sqlc.ListUserLikesAscendingParams{
+			UserID:          user.ID,
+			CursorCreatedAt: from.nullCreatedAt(),
+			CursorID:        from.nullID(),
+			Limit:           limit,
+		})
+		var rows []sqlc.ListUserLikesDescendingRow
+		for _, row := range ascendingRows {
+			rows = append(rows, sqlc.ListUserLikesDescendingRow(row))
+		}
+		return rows, err
+	}, func(row sqlc.ListUserLikesDescendingRow) cursor {
+		return cursor{CreatedAt: row.LikedAt, ID: row.Chirp.ID}
+	})
+	if err != nil {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't get likes", err)
+		return
+	}
+
+	records := make([]sqlc.Chirp, 0, len(rows))
+	for _, row := range rows {
+		records = append(records, row.Chirp)
+	}
+	formattedChirps, err := h.formatChirps(r.Context(), viewer(r), records)
+	if err != nil {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't get likes", err)
+		return
+	}
+	respond.WithJSON(w, http.StatusOK, ChirpPage{
+		Chirps:     formattedChirps,
+		NextCursor: next,
+		PrevCursor: prev,
+	})
+}
@@ -5,7 +5,6 @@ import (
 	"github.com/google/uuid"
 	"github.com/pcauce/chirpy/internal/auth"
 	"github.com/pcauce/chirpy/internal/config"
-	"github.com/pcauce/chirpy/internal/database"
 	"github.com/pcauce/chirpy/server/respond"
 	"net/http"
 )
@@ -17,7 +16,7 @@ type PolkaWebhook struct {
 	} `json:"data"`
 }
 
-func PolkaWebhooks(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) PolkaWebhooks(w http.ResponseWriter, r *http.Request) {
 	apiKey, err := auth.GetAPIKey(r.Header)
 	if err != nil {
 		respond.WithError(w, http.StatusUnauthorized, "Bad Request", err)
@@ -44,7 +43,7 @@ func PolkaWebhooks(w http.ResponseWriter, r *http.Request) {
 
 	switch webhook.Event {
 	case "user.upgraded":
-		err = database.Queries().UpgradeChirpyRed(r.Context(), userID)
+		err = h.store.UpgradeChirpyRed(r.Context(), userID)
 		if err != nil {
 			respond.WithError(w, http.StatusNotFound, "Couldn't upgrade user", err)
 			return
@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/pcauce/chirpy/internal/config"
+	"github.com/pcauce/chirpy/internal/sqlc"
+	"github.com/pcauce/chirpy/server/respond"
+	"github.com/rivo/uniseg"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	maxDisplayNameLength = 50
+	maxBioLength         = 160
+)
+
+// handlePattern is what a handle can look like. It is the same as what a
+// mention can name, so every handle can be mentioned.
+var handlePattern = regexp.MustCompile(`^\w{3,30}$`)
+
+// Profile is what anyone can see about a user.
+type Profile struct {
+	ID             uuid.UUID `json:"id"`
+	Handle         *string   `json:"handle"`
+	DisplayName    string    `json:"display_name"`
+	Bio            string    `json:"bio"`
+	CreatedAt      time.Time `json:"created_at"`
+	IsChirpyRed    bool      `json:"is_chirpy_red"`
+	ChirpCount     int64     `json:"chirp_count"`
+	FollowerCount  int64     `json:"follower_count"`
+	FollowingCount int64     `json:"following_count"`
+}
+
+// GetUserProfile returns the public profile of the user in the path, who
+// can be given by handle or by ID.
+func (h *Handler) GetUserProfile(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.pathUser(w, r)
+	if !ok {
+		return
+	}
+	stats, err := h.store.GetUserStats(r.Context(), user.ID)
+	if err != nil {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't get profile", err)
+		return
+	}
+
+	respond.WithJSON(w, http.StatusOK, Profile{
+		ID:             user.ID,
+		Handle:         nullableHandle(user.Handle),
+		DisplayName:    user.DisplayName,
+		Bio:            user.Bio,
+		CreatedAt:      user.CreatedAt,
+		IsChirpyRed:    user.IsChirpyRed,
+		ChirpCount:     stats.ChirpCount,
+		FollowerCount:  stats.FollowerCount,
+		FollowingCount: stats.FollowingCount,
+	})
+}
+
+// checkProfile validates the profile user wants to change to. If it isn't
+// valid, it has already responded and returns false.
+func (h *Handler) checkProfile(w http.ResponseWriter, r *http.Request, user sqlc.User, profile sqlc.UpdateUserProfileParams) bool {
+	if uniseg.GraphemeClusterCount(profile.DisplayName) > maxDisplayNameLength {
+		respond.WithError(w, http.StatusBadRequest, "Display name is longer than "+strconv.Itoa(maxDisplayNameLength)+" characters", nil)
+		return false
+	}
+	if uniseg.GraphemeClusterCount(profile.Bio) > maxBioLength {
+		respond.WithError(w, http.StatusBadRequest, "Bio is longer than "+strconv.Itoa(maxBioLength)+" characters", nil)
+		return false
+	}
+	if !profile.Handle.Valid || (user.Handle.Valid && strings.EqualFold(profile.Handle.String, user.Handle.String)) {
+		return true
+	}
+
+	handle := profile.Handle.String
+	if !handlePattern.MatchString(handle) {
+		respond.WithError(w, http.StatusBadRequest, "Handles are 3 to 30 letters, digits or underscores", nil)
+		return false
+	}
+	_, err := h.store.GetUserByHandle(r.Context(), handle)
+	if err == nil {
+		respond.WithError(w, http.StatusConflict, "That handle is taken", nil)
+		return false
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't check handle", err)
+		return false
+	}
+
+	released, err := h.store.GetReleasedHandle(r.Context(), handle)
+	if errors.Is(err, sql.ErrNoRows) {
+		return true
+	}
+	if err != nil {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't check handle", err)
+		return false
+	}
+	reservedUntil := released.ReleasedAt.Add(config.APIConfig().HandleReserve)
+	if released.UserID.UUID != user.ID && time.Now().UTC().Before(reservedUntil) {
+		respond.WithError(w, http.StatusConflict, "That handle was given up recently and is still reserved", nil)
+		return false
+	}
+	return true
+}
+
+// updateProfile saves a profile checkProfile accepted. A handle the user gives
+// up stays reserved for them for a while, so nobody else can pose as them by
+// taking it straight away.
+func (h *Handler) updateProfile(ctx context.Context, user sqlc.User, profile sqlc.UpdateUserProfileParams) error {
+	_, err := h.store.UpdateUserProfile(ctx, profile)
+	if err != nil {
+		return err
+	}
+
+	if user.Handle.Valid && !(profile.Handle.Valid && strings.EqualFold(user.Handle.String, profile.Handle.String)) {
+		err = h.store.ReleaseHandle(ctx, sqlc.ReleaseHandleParams{
+			Handle: user.Handle.String,
+			UserID: uuid.NullUUID{UUID: user.ID, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if profile.Handle.Valid {
+		return h.store.DeleteReleasedHandle(ctx, profile.Handle.String)
+	}
+	return nil
+}
+
+func nullableHandle(handle sql.NullString) *string {
+	if !handle.Valid {
+		return nil
+	}
+	return &handle.String
+}
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/pcauce/chirpy/internal/database"
+	"github.com/pcauce/chirpy/internal/sqlc"
+	"github.com/pcauce/chirpy/server/respond"
+	"net/http"
+	"time"
+)
+
+type ChirpRevision struct {
+	ID         uuid.UUID `json:"id"`
+	Body       string    `json:"body"`
+	CreatedAt  time.Time `json:"created_at"`
+	ReplacedAt time.Time `json:"replaced_at"`
+}
+
+func (h *Handler) EditChirp(w http.ResponseWriter, r *http.Request) {
+	chirp, ok := h.ownChirp(w, r, "edit")
+	if !ok {
+		return
+	}
+	if chirp.RechirpOf.Valid {
+		respond.WithError(w, http.StatusBadRequest, "Rechirps have no body to edit", nil)
+		return
+	}
+
+	chirpData := map[string]string{}
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&chirpData)
+	if err != nil {
+		respond.WithError(w, http.StatusBadRequest, "Couldn't decode JSON", err)
+		return
+	}
+
+	body, ok := chirpData["body"]
+	if !ok {
+		respond.WithError(w, http.StatusBadRequest, "Body missing", nil)
+		return
+	}
+	cleaned, ok := h.cleanChirp(w, r, chirp.UserID, body)
+	if !ok {
+		return
+	}
+	body = cleaned.Body
+
+	if body != chirp.Body {
+		err = h.store.WithTx(r.Context(), func(tx database.Store) error {
+			chirp, err = tx.UpdateChirpBody(r.Context(), sqlc.UpdateChirpBodyParams{
+				ID:     chirp.ID,
+				UserID: chirp.UserID,
+				Body:   body,
+			})
+			if err != nil {
+				return err
+			}
+			return saveChirpContent(r.Context(), tx, chirp.ID, cleaned)
+		})
+		if database.IsUniqueViolation(err) {
+			respond.WithError(w, http.StatusConflict, "You already have a chirp that says this", err)
+			return
+		}
+		if err != nil {
+			respond.WithError(w, http.StatusInternalServerError, "Couldn't edit chirp", err)
+			return
+		}
+	}
+
+	formatted, err := h.formatOneChirp(r.Context(), chirp.UserID, chirp)
+	if err != nil {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't edit chirp", err)
+		return
+	}
+	respond.WithJSON(w, http.StatusOK, formatted)
+}
+
+func (h *Handler) GetChirpRevisions(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respond.WithError(w, http.StatusBadRequest, "Couldn't parse chirp ID", err)
+		return
+	}
+
+	_, err = h.store.GetChirpByID(r.Context(), chirpID)
+	if err != nil {
+		respond.WithError(w, http.StatusNotFound, "Couldn't get chirp", err)
+		return
+	}
+
+	revisions, err := h.store.ListChirpRevisions(r.Context(), chirpID)
+	if err != nil {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't get chirp revisions", err)
+		return
+	}
+
+	formattedRevisions := []ChirpRevision{}
+	for _, revision := range revisions {
+		formattedRevisions = append(formattedRevisions, ChirpRevision{
+			ID:         revision.ID,
+			Body:       revision.Body,
+			CreatedAt:  revision.CreatedAt,
+			ReplacedAt: revision.ReplacedAt,
+		})
+	}
+	respond.WithJSON(w, http.StatusOK, formattedRevisions)
+}
@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/pcauce/chirpy/internal/auth"
+	"github.com/pcauce/chirpy/internal/sqlc"
+	"github.com/pcauce/chirpy/server/respond"
+	"net/http"
+)
+
+// SetUserRole changes the role of the user in the path. A user whose role
+// changes is logged out everywhere, so no access token keeps the scopes of
+// the role they had.
+func (h *Handler) SetUserRole(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.pathUser(w, r)
+	if !ok {
+		return
+	}
+
+	var params struct {
+		Role string `json:"role"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&params)
+	if err != nil {
+		respond.WithError(w, http.StatusBadRequest, "Couldn't decode JSON", err)
+		return
+	}
+	role, err := auth.ParseRole(params.Role)
+	if err != nil {
+		respond.WithError(w, http.StatusBadRequest, "Role must be user or admin", err)
+		return
+	}
+
+	if user.Role != string(role) {
+		user, err = h.store.SetUserRole(r.Context(), sqlc.SetUserRoleParams{ID: user.ID, Role: string(role)})
+		if err != nil {
+			respond.WithError(w, http.StatusInternalServerError, "Couldn't set role", err)
+			return
+		}
+		err = h.revokeUserSessions(r.Context(), user.ID)
+		if err != nil {
+			respond.WithError(w, http.StatusInternalServerError, "Couldn't revoke sessions", err)
+			return
+		}
+	}
+	respond.WithJSON(w, http.StatusOK, formatUser(user))
+}
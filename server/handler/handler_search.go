@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/pcauce/chirpy/internal/search"
+	"github.com/pcauce/chirpy/internal/sqlc"
+	"github.com/pcauce/chirpy/server/respond"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// chirpFilter is the parsed ?author_id=, ?q=, ?since= and ?until= query
+// parameters. Query holds tsquery text ready for the store.
+type chirpFilter struct {
+	AuthorID      uuid.NullUUID
+	Query         sql.NullString
+	CreatedAfter  sql.NullTime
+	CreatedBefore sql.NullTime
+}
+
+func parseChirpFilter(query url.Values) (chirpFilter, error) {
+	filter := chirpFilter{}
+
+	if rawAuthorID := query.Get("author_id"); rawAuthorID != "" {
+		authorID, err := uuid.Parse(rawAuthorID)
+		if err != nil {
+			return chirpFilter{}, errors.New("Couldn't parse author ID")
+		}
+		filter.AuthorID = uuid.NullUUID{UUID: authorID, Valid: true}
+	}
+
+	if q := query.Get("q"); q != "" {
+		tsquery, err := search.Parse(q)
+		if err != nil {
+			return chirpFilter{}, errors.New("Search query has no terms")
+		}
+		filter.Query = sql.NullString{String: tsquery, Valid: true}
+	}
+
+	var err error
+	filter.CreatedAfter, err = parseDate(query.Get("since"))
+	if err != nil {
+		return chirpFilter{}, errors.New("Couldn't parse since date")
+	}
+	filter.CreatedBefore, err = parseDate(query.Get("until"))
+	if err != nil {
+		return chirpFilter{}, errors.New("Couldn't parse until date")
+	}
+
+	return filter, nil
+}
+
+// parseDate accepts an RFC 3339 timestamp or a plain YYYY-MM-DD date.
+func parseDate(raw string) (sql.NullTime, error) {
+	if raw == "" {
+		return sql.NullTime{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		t, err = time.Parse(time.DateOnly, raw)
+		if err != nil {
+			return sql.NullTime{}, err
+		}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}, nil
+}
+
+func (h *Handler) SearchChirps(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("q") == "" {
+		respond.WithError(w, http.StatusBadRequest, "Missing search query", nil)
+		return
+	}
+	h.GetChirps(w, r)
+}
+
+// searchChirps fetches one page of chirps matching filter, best match first.
+func (h *Handler) searchChirps(ctx context.Context, p page, filter chirpFilter) ([]sqlc.Chirp, string, string, error) {
+	rows, next, prev, err := fetchPage(p, func(ascending bool, from *cursor, limit int32) ([]sqlc.SearchChirpsDescendingRow, error) {
+		if !ascending {
+			return h.store.SearchChirpsDescending(ctx, sqlc.SearchChirpsDescendingParams{
+				Query:           filter.Query.String,
+				AuthorID:        filter.AuthorID,
+				CreatedAfter:    filter.CreatedAfter,
+				CreatedBefore:   filter.CreatedBefore,
+				CursorRank:      from.nullRank(),
+				CursorCreatedAt: from.nullCreatedAt(),
+				CursorID:        from.nullID(),
+				Limit:           limit,
+			})
+		}
+		ascendingRows, err := h.store.SearchChirpsAscending(ctx, sqlc.SearchChirpsAscendingParams{
+			Query:           filter.Query.String,
+			AuthorID:        filter.AuthorID,
+			CreatedAfter:    filter.CreatedAfter,
+			CreatedBefore:   filter.CreatedBefore,
+			CursorRank:      from.nullRank(),
+			CursorCreatedAt: from.nullCreatedAt(),
+			CursorID:        from.nullID(),
+			Limit:           limit,
+		})
+		var rows []sqlc.SearchChirpsDescendingRow
+		for _, row := range ascendingRows {
+			rows = append(rows, sqlc.SearchChirpsDescendingRow(row))
+		}
+		return rows, err
+	}, func(row sqlc.SearchChirpsDescendingRow) cursor {
+		return cursor{Rank: row.Rank, CreatedAt: row.CreatedAt, ID: row.ID}
+	})
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	var chirps []sqlc.Chirp
+	for _, row := range rows {
+		chirps = append(chirps, sqlc.Chirp{
+			ID:        row.ID,
+			CreatedAt: row.CreatedAt,
+			UpdatedAt: row.UpdatedAt,
+			Body:      row.Body,
+			UserID:    row.UserID,
+			InReplyTo: row.InReplyTo,
+			RechirpOf: row.RechirpOf,
+			QuoteOf:   row.QuoteOf,
+		})
+	}
+	return chirps, next, prev, nil
+}
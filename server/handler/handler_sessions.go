@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/pcauce/chirpy/internal/auth"
+	"github.com/pcauce/chirpy/internal/config"
+	"github.com/pcauce/chirpy/internal/sqlc"
+	"github.com/pcauce/chirpy/server/respond"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	// sessionTouchInterval is how stale a session's last_used_at can get
+	// before a request with one of its access tokens updates it, so that
+	// every request doesn't write to the database.
+	sessionTouchInterval = time.Minute
+	// maxUserAgentLength is how much of a User-Agent header is kept.
+	maxUserAgentLength = 512
+)
+
+// Session is one login, as the user sees it in their list of devices.
+// Current marks the session the request was made with.
+type Session struct {
+	ID         uuid.UUID `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+// GetSessions lists the caller's sessions that are still live, most
+// recently used first.
+func (h *Handler) GetSessions(w http.ResponseWriter, r *http.Request) {
+	current, ok := auth.PrincipalFrom(r.Context())
+	if !ok {
+		refuse(w, r)
+		return
+	}
+
+	sessions, err := h.store.ListActiveSessions(r.Context(), current.UserID)
+	if err != nil {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't get sessions", err)
+		return
+	}
+	formatted := make([]Session, 0, len(sessions))
+	for _, session := range sessions {
+		formatted = append(formatted, Session{
+			ID:         session.ID,
+			UserAgent:  session.UserAgent,
+			IP:         session.IP,
+			CreatedAt:  session.CreatedAt,
+			LastUsedAt: session.LastUsedAt,
+			ExpiresAt:  session.ExpiresAt,
+			Current:    session.ID == current.SessionID,
+		})
+	}
+	respond.WithJSON(w, http.StatusOK, formatted)
+}
+
+// RevokeSession logs one of the caller's sessions out.
+func (h *Handler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	userID, ok := caller(w, r)
+	if !ok {
+		return
+	}
+
+	sessionID, err := uuid.Parse(r.PathValue("sessionID"))
+	if err != nil {
+		respond.WithError(w, http.StatusBadRequest, "Couldn't parse session ID", err)
+		return
+	}
+	ended, err := h.endSession(r.Context(), userID, sessionID)
+	if err != nil {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't revoke session", err)
+		return
+	}
+	if !ended {
+		respond.WithError(w, http.StatusNotFound, "Couldn't find session", nil)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RevokeAllSessions logs the caller out everywhere, including the session
+// the request was made with.
+func (h *Handler) RevokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := caller(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.revokeUserSessions(r.Context(), userID)
+	if err != nil {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't revoke sessions", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// startSession records a new login from r.
+func (h *Handler) startSession(r *http.Request, userID uuid.UUID) (sqlc.Session, error) {
+	userAgent := r.UserAgent()
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+	return h.store.CreateSession(r.Context(), sqlc.CreateSessionParams{
+		UserID:    userID,
+		UserAgent: userAgent,
+		IP:        clientIP(r),
+		ExpiresAt: time.Now().Add(config.APIConfig().TokenDuration["refresh"]),
+	})
+}
+
+// endSession revokes a session of userID's and its refresh tokens. It
+// reports whether there was a live session to revoke.
+func (h *Handler) endSession(ctx context.Context, userID, sessionID uuid.UUID) (bool, error) {
+	revoked, err := h.store.RevokeSession(ctx, sqlc.RevokeSessionParams{ID: sessionID, UserID: userID})
+	if err != nil {
+		return false, err
+	}
+	return revoked > 0, h.store.RevokeRefreshFamily(ctx, sessionID)
+}
+
+// revokeUserSessions revokes every session of userID's and their refresh
+// tokens.
+func (h *Handler) revokeUserSessions(ctx context.Context, userID uuid.UUID) error {
+	err := h.store.RevokeUserSessions(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return h.store.RevokeUserRefreshTokens(ctx, uuid.NullUUID{UUID: userID, Valid: true})
+}
+
+// clientIP is the address r came from. Proxy headers are ignored, since
+// anyone can set them.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,1781 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/pcauce/chirpy/internal/archive"
+	"github.com/pcauce/chirpy/internal/auth"
+	"github.com/pcauce/chirpy/internal/config"
+	"github.com/pcauce/chirpy/internal/database"
+	"github.com/pcauce/chirpy/internal/jobs"
+	"github.com/pcauce/chirpy/internal/sqlc"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	return newTestServerWithStore(t, database.NewMemory())
+}
+
+// newTestServerWithStore is newTestServer backed by store, which must be
+// empty.
+func newTestServerWithStore(t *testing.T, store database.Store) *httptest.Server {
+	t.Helper()
+	config.APIConfig().RefreshTokenKey = "test-refresh-key"
+	config.APIConfig().APIKeyHashKey = "test-api-key-hash-key"
+	config.APIConfig().PolkaKey = "test-polka-key"
+
+	key, err := auth.GenerateKey("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := auth.NewKeySet(key.ID, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	createTestAdmin(t, store)
+	h := New(store, keys)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go jobs.Run(ctx, "build exports", 10*time.Millisecond, h.BuildExports)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/jwks.json", h.GetJWKS)
+	mux.Handle("POST /admin/reset", Require(auth.ScopeAdmin, h.ResetDatabase))
+	mux.Handle("PUT /admin/users/{userID}/role", Require(auth.ScopeAdmin, h.SetUserRole))
+	mux.Handle("GET /admin/filter_terms", Require(auth.ScopeAdmin, h.GetFilterTerms))
+	mux.Handle("POST /admin/filter_terms", Require(auth.ScopeAdmin, h.CreateFilterTerm))
+	mux.Handle("PUT /admin/filter_terms/{termID}", Require(auth.ScopeAdmin, h.UpdateFilterTerm))
+	mux.Handle("DELETE /admin/filter_terms/{termID}", Require(auth.ScopeAdmin, h.DeleteFilterTerm))
+	mux.Handle("GET /admin/flagged_chirps", Require(auth.ScopeAdmin, h.GetFlaggedChirps))
+	mux.Handle("DELETE /admin/flagged_chirps/{chirpID}", Require(auth.ScopeAdmin, h.DismissChirpFlags))
+	mux.HandleFunc("POST /api/users", h.CreateUser)
+	mux.Handle("PUT /api/users", Require(auth.ScopeAccount, h.ChangeUserCredentials))
+	mux.Handle("DELETE /api/users/me", Require(auth.ScopeAccount, h.DeleteUser))
+	mux.Handle("GET /api/users/me/trash", Require(auth.ScopeChirpsRead, h.GetTrash))
+	mux.Handle("GET /api/users/me/export", Require(auth.ScopeAccount, h.ExportUser))
+	mux.Handle("GET /api/users/me/exports/{exportID}", Require(auth.ScopeAccount, h.DownloadExport))
+	mux.Handle("POST /api/users/me/import", Require(auth.ScopeAccount, h.ImportUser))
+	mux.Handle("POST /api/users/me/api_keys", Require(auth.ScopeAccount, h.CreateAPIKey))
+	mux.Handle("GET /api/users/me/api_keys", Require(auth.ScopeAccount, h.GetAPIKeys))
+	mux.Handle("DELETE /api/users/me/api_keys/{keyID}", Require(auth.ScopeAccount, h.RevokeAPIKey))
+	mux.HandleFunc("GET /api/users/{userID}", h.GetUserProfile)
+	mux.HandleFunc("GET /api/users/{userID}/likes", h.GetUserLikes)
+	mux.Handle("POST /api/users/{userID}/follow", Require(auth.ScopeFollowsWrite, h.FollowUser))
+	mux.Handle("DELETE /api/users/{userID}/follow", Require(auth.ScopeFollowsWrite, h.UnfollowUser))
+	mux.HandleFunc("GET /api/users/{userID}/followers", h.GetFollowers)
+	mux.HandleFunc("GET /api/users/{userID}/following", h.GetFollowing)
+	mux.HandleFunc("POST /api/login", h.LoginUser)
+	mux.HandleFunc("POST /api/refresh", h.IssueNewAccessToken)
+	mux.HandleFunc("POST /api/revoke", h.RevokeAccessToken)
+	mux.Handle("GET /api/sessions", Require(auth.ScopeAccount, h.GetSessions))
+	mux.Handle("DELETE /api/sessions/{sessionID}", Require(auth.ScopeAccount, h.RevokeSession))
+	mux.Handle("POST /api/sessions/revoke-all", Require(auth.ScopeAccount, h.RevokeAllSessions))
+	mux.Handle("POST /api/chirps", Require(auth.ScopeChirpsWrite, h.CreateChirp))
+	mux.HandleFunc("POST /api/validate_chirp", h.ValidateChirp)
+	mux.HandleFunc("GET /api/chirps", h.GetChirps)
+	mux.HandleFunc("GET /api/search", h.SearchChirps)
+	mux.Handle("GET /api/timeline", Require(auth.ScopeChirpsRead, h.GetTimeline))
+	mux.HandleFunc("GET /api/hashtags/{tag}", h.GetHashtagChirps)
+	mux.HandleFunc("GET /api/chirps/{chirpID}", h.GetChirpByID)
+	mux.Handle("PUT /api/chirps/{chirpID}", Require(auth.ScopeChirpsWrite, h.EditChirp))
+	mux.Handle("DELETE /api/chirps/{chirpID}", Require(auth.ScopeChirpsWrite, h.DeleteChirp))
+	mux.HandleFunc("GET /api/chirps/{chirpID}/revisions", h.GetChirpRevisions)
+	mux.Handle("POST /api/chirps/{chirpID}/restore", Require(auth.ScopeChirpsWrite, h.RestoreChirp))
+	mux.HandleFunc("GET /api/chirps/{chirpID}/thread", h.GetChirpThread)
+	mux.Handle("POST /api/chirps/{chirpID}/likes", Require(auth.ScopeChirpsWrite, h.LikeChirp))
+	mux.Handle("DELETE /api/chirps/{chirpID}/likes", Require(auth.ScopeChirpsWrite, h.UnlikeChirp))
+	mux.HandleFunc("POST /api/polka/webhooks", h.PolkaWebhooks)
+
+	server := httptest.NewServer(h.Authenticate(mux))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// createTestAdmin adds the admin loginTestAdmin logs in as, the way
+// "chirpy role" would make one.
+func createTestAdmin(t *testing.T, store database.Store) {
+	t.Helper()
+	hashedPassword, err := auth.HashPassword("hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin, err := store.CreateUser(context.Background(), sqlc.CreateUserParams{Email: "admin@example.com", HashedPassword: hashedPassword})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = store.SetUserRole(context.Background(), sqlc.SetUserRoleParams{ID: admin.ID, Role: "admin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func doJSON(t *testing.T, method, url, token string, body, out any) int {
+	t.Helper()
+	authorization := ""
+	if token != "" {
+		authorization = "Bearer " + token
+	}
+	return doRequest(t, method, url, authorization, body, out)
+}
+
+func doRequest(t *testing.T, method, url, authorization string, body, out any) int {
+	t.Helper()
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req, err := http.NewRequest(method, url, &payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if out != nil && res.StatusCode < 300 && res.StatusCode != http.StatusNoContent {
+		if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return res.StatusCode
+}
+
+func loginTestAdmin(t *testing.T, server *httptest.Server) User {
+	t.Helper()
+	admin := User{}
+	credentials := map[string]string{"email": "admin@example.com", "password": "hunter2"}
+	if code := doJSON(t, "POST", server.URL+"/api/login", "", credentials, &admin); code != http.StatusOK {
+		t.Fatalf("POST /api/login as the admin = %d, want %d", code, http.StatusOK)
+	}
+	return admin
+}
+
+func loginTestUser(t *testing.T, server *httptest.Server, email string) User {
+	t.Helper()
+	credentials := map[string]string{"email": email, "password": "hunter2"}
+	if code := doJSON(t, "POST", server.URL+"/api/users", "", credentials, nil); code != http.StatusCreated {
+		t.Fatalf("POST /api/users = %d, want %d", code, http.StatusCreated)
+	}
+	user := User{}
+	if code := doJSON(t, "POST", server.URL+"/api/login", "", credentials, &user); code != http.StatusOK {
+		t.Fatalf("POST /api/login = %d, want %d", code, http.StatusOK)
+	}
+	return user
+}
+
+func TestChirpLifecycle(t *testing.T) {
+	server := newTestServer(t)
+	walt := loginTestUser(t, server, "walt@example.com")
+	jesse := loginTestUser(t, server, "jesse@example.com")
+
+	if code := doJSON(t, "POST", server.URL+"/api/chirps", "", map[string]string{"body": "hi"}, nil); code != http.StatusUnauthorized {
+		t.Errorf("POST /api/chirps without token = %d, want %d", code, http.StatusUnauthorized)
+	}
+
+	chirp := Chirp{}
+	code := doJSON(t, "POST", server.URL+"/api/chirps", walt.Token, map[string]string{"body": "say my name"}, &chirp)
+	if code != http.StatusCreated || chirp.UserID.UUID != walt.ID {
+		t.Fatalf("POST /api/chirps = %d, %+v", code, chirp)
+	}
+
+	page := ChirpPage{}
+	if code := doJSON(t, "GET", server.URL+"/api/chirps?author_id="+walt.ID.String(), "", nil, &page); code != http.StatusOK || len(page.Chirps) != 1 {
+		t.Errorf("GET /api/chirps?author_id = %d, %d chirps, want 1", code, len(page.Chirps))
+	}
+
+	chirpURL := server.URL + "/api/chirps/" + chirp.ID.String()
+	if code := doJSON(t, "GET", chirpURL, "", nil, nil); code != http.StatusOK {
+		t.Errorf("GET chirp = %d, want %d", code, http.StatusOK)
+	}
+	if code := doJSON(t, "GET", server.URL+"/api/chirps/not-a-uuid", "", nil, nil); code != http.StatusBadRequest {
+		t.Errorf("GET chirp with a malformed ID = %d, want %d", code, http.StatusBadRequest)
+	}
+	if code := doJSON(t, "DELETE", chirpURL, jesse.Token, nil, nil); code != http.StatusForbidden {
+		t.Errorf("DELETE by non-author = %d, want %d", code, http.StatusForbidden)
+	}
+	if code := doJSON(t, "DELETE", chirpURL, walt.Token, nil, nil); code != http.StatusNoContent {
+		t.Errorf("DELETE by author = %d, want %d", code, http.StatusNoContent)
+	}
+	if code := doJSON(t, "GET", chirpURL, "", nil, nil); code != http.StatusNotFound {
+		t.Errorf("GET deleted chirp = %d, want %d", code, http.StatusNotFound)
+	}
+}
+
+func TestGetChirpsPagination(t *testing.T) {
+	server := newTestServer(t)
+	walt := loginTestUser(t, server, "walt@example.com")
+
+	var bodies []string
+	for i := range 5 {
+		chirp := Chirp{}
+		body := "chirp " + strconv.Itoa(i)
+		if code := doJSON(t, "POST", server.URL+"/api/chirps", walt.Token, map[string]string{"body": body}, &chirp); code != http.StatusCreated {
+			t.Fatalf("POST /api/chirps = %d", code)
+		}
+		bodies = append(bodies, chirp.Body)
+	}
+
+	getPage := func(query string) ChirpPage {
+		t.Helper()
+		page := ChirpPage{}
+		if code := doJSON(t, "GET", server.URL+"/api/chirps?"+query, "", nil, &page); code != http.StatusOK {
+			t.Fatalf("GET /api/chirps?%s = %d", query, code)
+		}
+		return page
+	}
+	pageBodies := func(page ChirpPage) []string {
+		var got []string
+		for _, chirp := range page.Chirps {
+			got = append(got, chirp.Body)
+		}
+		return got
+	}
+
+	first := getPage("limit=2&sort=desc")
+	if got := pageBodies(first); !slices.Equal(got, []string{bodies[4], bodies[3]}) || first.PrevCursor != "" {
+		t.Fatalf("first page = %v, prev %q", got, first.PrevCursor)
+	}
+	second := getPage("limit=2&sort=desc&cursor=" + first.NextCursor)
+	if got := pageBodies(second); !slices.Equal(got, []string{bodies[2], bodies[1]}) {
+		t.Fatalf("second page = %v", got)
+	}
+	last := getPage("limit=2&sort=desc&cursor=" + second.NextCursor)
+	if got := pageBodies(last); !slices.Equal(got, []string{bodies[0]}) || last.NextCursor != "" {
+		t.Fatalf("last page = %v, next %q", got, last.NextCursor)
+	}
+	back := getPage("limit=2&sort=desc&cursor=" + last.PrevCursor)
+	if got := pageBodies(back); !slices.Equal(got, []string{bodies[2], bodies[1]}) || back.NextCursor == "" {
+		t.Fatalf("previous page = %v", got)
+	}
+	start := getPage("limit=2&sort=desc&cursor=" + back.PrevCursor)
+	if got := pageBodies(start); !slices.Equal(got, []string{bodies[4], bodies[3]}) || start.PrevCursor != "" {
+		t.Fatalf("back to first page = %v, prev %q", got, start.PrevCursor)
+	}
+
+	for _, query := range []string{"limit=0", "limit=abc", "sort=sideways", "cursor=garbage"} {
+		if code := doJSON(t, "GET", server.URL+"/api/chirps?"+query, "", nil, nil); code != http.StatusBadRequest {
+			t.Errorf("GET /api/chirps?%s = %d, want %d", query, code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestSearchChirps(t *testing.T) {
+	server := newTestServer(t)
+	walt := loginTestUser(t, server, "walt@example.com")
+	jesse := loginTestUser(t, server, "jesse@example.com")
+
+	for _, post := range []struct {
+		user User
+		body string
+	}{
+		{walt, "Say my name"},
+		{walt, "I am the one who knocks"},
+		{jesse, "Yeah science! Science, science, science"},
+		{jesse, "Yo, Mr White, I know your name"},
+	} {
+		if code := doJSON(t, "POST", server.URL+"/api/chirps", post.user.Token, map[string]string{"body": post.body}, nil); code != http.StatusCreated {
+			t.Fatalf("POST /api/chirps = %d", code)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{
+			name:  "Word",
+			query: "q=name&sort=asc",
+			want:  []string{"Say my name", "Yo, Mr White, I know your name"},
+		},
+		{
+			name:  "Phrase",
+			query: `q="who knocks"`,
+			want:  []string{"I am the one who knocks"},
+		},
+		{
+			name:  "Prefix",
+			query: "q=sci*",
+			want:  []string{"Yeah science! Science, science, science"},
+		},
+		{
+			name:  "Combined with author",
+			query: "q=name&author_id=" + jesse.ID.String(),
+			want:  []string{"Yo, Mr White, I know your name"},
+		},
+		{
+			name:  "Ranked by relevance",
+			query: "q=science OR name",
+			want:  []string{"Yeah science! Science, science, science", "Say my name", "Yo, Mr White, I know your name"},
+		},
+		{
+			name:  "Date range excludes everything",
+			query: "q=name&until=2000-01-01",
+			want:  nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := ChirpPage{}
+			searchURL := server.URL + "/api/search?" + strings.ReplaceAll(tt.query, " ", "+")
+			if code := doJSON(t, "GET", searchURL, "", nil, &page); code != http.StatusOK {
+				t.Fatalf("GET /api/search?%s = %d", tt.query, code)
+			}
+			var got []string
+			for _, chirp := range page.Chirps {
+				got = append(got, chirp.Body)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("GET /api/search?%s = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+
+	if code := doJSON(t, "GET", server.URL+"/api/search", "", nil, nil); code != http.StatusBadRequest {
+		t.Errorf("GET /api/search without q = %d, want %d", code, http.StatusBadRequest)
+	}
+	if code := doJSON(t, "GET", server.URL+"/api/chirps?sort=relevance", "", nil, nil); code != http.StatusBadRequest {
+		t.Errorf("GET /api/chirps?sort=relevance without q = %d, want %d", code, http.StatusBadRequest)
+	}
+}
+
+func TestEditChirp(t *testing.T) {
+	server := newTestServer(t)
+	walt := loginTestUser(t, server, "walt@example.com")
+	jesse := loginTestUser(t, server, "jesse@example.com")
+
+	chirp := Chirp{}
+	if code := doJSON(t, "POST", server.URL+"/api/chirps", walt.Token, map[string]string{"body": "first draft"}, &chirp); code != http.StatusCreated {
+		t.Fatalf("POST /api/chirps = %d", code)
+	}
+	if chirp.Edited {
+		t.Error("new chirp is marked as edited")
+	}
+
+	chirpURL := server.URL + "/api/chirps/" + chirp.ID.String()
+	if code := doJSON(t, "PUT", chirpURL, jesse.Token, map[string]string{"body": "hijacked"}, nil); code != http.StatusForbidden {
+		t.Errorf("PUT by non-author = %d, want %d", code, http.StatusForbidden)
+	}
+
+	edited := Chirp{}
+	if code := doJSON(t, "PUT", chirpURL, walt.Token, map[string]string{"body": "final draft"}, &edited); code != http.StatusOK {
+		t.Fatalf("PUT by author = %d, want %d", code, http.StatusOK)
+	}
+	if edited.Body != "final draft" || !edited.Edited {
+		t.Errorf("PUT by author = %+v", edited)
+	}
+
+	other := Chirp{}
+	if code := doJSON(t, "POST", server.URL+"/api/chirps", walt.Token, map[string]string{"body": "another chirp"}, &other); code != http.StatusCreated {
+		t.Fatalf("POST /api/chirps = %d", code)
+	}
+	if code := doJSON(t, "PUT", chirpURL, walt.Token, map[string]string{"body": "another chirp"}, nil); code != http.StatusConflict {
+		t.Errorf("PUT with the body of another of the author's chirps = %d, want %d", code, http.StatusConflict)
+	}
+
+	var revisions []ChirpRevision
+	if code := doJSON(t, "GET", chirpURL+"/revisions", "", nil, &revisions); code != http.StatusOK {
+		t.Fatalf("GET revisions = %d", code)
+	}
+	if len(revisions) != 1 || revisions[0].Body != "first draft" {
+		t.Errorf("GET revisions = %+v, want the first draft", revisions)
+	}
+}
+
+func TestTrashAndRestore(t *testing.T) {
+	server := newTestServer(t)
+	walt := loginTestUser(t, server, "walt@example.com")
+	jesse := loginTestUser(t, server, "jesse@example.com")
+
+	chirp := Chirp{}
+	if code := doJSON(t, "POST", server.URL+"/api/chirps", walt.Token, map[string]string{"body": "oops"}, &chirp); code != http.StatusCreated {
+		t.Fatalf("POST /api/chirps = %d", code)
+	}
+	chirpURL := server.URL + "/api/chirps/" + chirp.ID.String()
+	if code := doJSON(t, "DELETE", chirpURL, walt.Token, nil, nil); code != http.StatusNoContent {
+		t.Fatalf("DELETE = %d", code)
+	}
+
+	var trash []Chirp
+	if code := doJSON(t, "GET", server.URL+"/api/users/me/trash", walt.Token, nil, &trash); code != http.StatusOK {
+		t.Fatalf("GET trash = %d", code)
+	}
+	if len(trash) != 1 || trash[0].ID != chirp.ID || trash[0].DeletedAt == nil {
+		t.Errorf("GET trash = %+v, want the deleted chirp", trash)
+	}
+
+	if code := doJSON(t, "POST", chirpURL+"/restore", jesse.Token, nil, nil); code != http.StatusNotFound {
+		t.Errorf("restore by non-author = %d, want %d", code, http.StatusNotFound)
+	}
+
+	again := Chirp{}
+	if code := doJSON(t, "POST", server.URL+"/api/chirps", walt.Token, map[string]string{"body": "oops"}, &again); code != http.StatusCreated {
+		t.Fatalf("POST /api/chirps with the body of a trashed chirp = %d, want %d", code, http.StatusCreated)
+	}
+	if code := doJSON(t, "POST", server.URL+"/api/chirps", walt.Token, map[string]string{"body": "oops"}, nil); code != http.StatusConflict {
+		t.Errorf("POST /api/chirps with the body of a live chirp = %d, want %d", code, http.StatusConflict)
+	}
+	if code := doJSON(t, "POST", chirpURL+"/restore", walt.Token, nil, nil); code != http.StatusConflict {
+		t.Errorf("restore while a live chirp says the same = %d, want %d", code, http.StatusConflict)
+	}
+	if code := doJSON(t, "DELETE", server.URL+"/api/chirps/"+again.ID.String(), walt.Token, nil, nil); code != http.StatusNoContent {
+		t.Fatalf("DELETE = %d", code)
+	}
+
+	restored := Chirp{}
+	if code := doJSON(t, "POST", chirpURL+"/restore", walt.Token, nil, &restored); code != http.StatusOK || restored.DeletedAt != nil {
+		t.Fatalf("restore by author = %d, %+v", code, restored)
+	}
+	if code := doJSON(t, "GET", chirpURL, "", nil, nil); code != http.StatusOK {
+		t.Errorf("GET restored chirp = %d, want %d", code, http.StatusOK)
+	}
+}
+
+func TestReplyThreads(t *testing.T) {
+	server := newTestServer(t)
+	walt := loginTestUser(t, server, "walt@example.com")
+	jesse := loginTestUser(t, server, "jesse@example.com")
+
+	post := func(t *testing.T, token, body string, inReplyTo *Chirp) Chirp {
+		t.Helper()
+		payload := map[string]any{"body": body}
+		if inReplyTo != nil {
+			payload["in_reply_to"] = inReplyTo.ID
+		}
+		chirp := Chirp{}
+		if code := doJSON(t, "POST", server.URL+"/api/chirps", token, payload, &chirp); code != http.StatusCreated {
+			t.Fatalf("POST /api/chirps %q = %d", body, code)
+		}
+		return chirp
+	}
+	root := post(t, walt.Token, "say my name", nil)
+	answer := post(t, jesse.Token, "heisenberg", &root)
+	nested := post(t, walt.Token, "you're goddamn right", &answer)
+	other := post(t, jesse.Token, "yo", &root)
+	if !answer.InReplyTo.Valid || answer.InReplyTo.UUID != root.ID {
+		t.Errorf("POST reply in_reply_to = %v, want %v", answer.InReplyTo, root.ID)
+	}
+
+	missing := map[string]any{"body": "hello?", "in_reply_to": "00000000-0000-0000-0000-000000000001"}
+	if code := doJSON(t, "POST", server.URL+"/api/chirps", walt.Token, missing, nil); code != http.StatusBadRequest {
+		t.Errorf("POST reply to a missing chirp = %d, want %d", code, http.StatusBadRequest)
+	}
+
+	got := Chirp{}
+	doJSON(t, "GET", server.URL+"/api/chirps/"+root.ID.String(), "", nil, &got)
+	if got.ReplyCount != 2 {
+		t.Errorf("GET root reply_count = %d, want 2", got.ReplyCount)
+	}
+
+	thread := Thread{}
+	if code := doJSON(t, "GET", server.URL+"/api/chirps/"+root.ID.String()+"/thread?limit=1", "", nil, &thread); code != http.StatusOK {
+		t.Fatalf("GET thread = %d", code)
+	}
+	if len(thread.Ancestors) != 0 || thread.Chirp.ID != root.ID || thread.NextCursor == "" {
+		t.Errorf("GET thread = %+v", thread)
+	}
+	if len(thread.Replies) != 1 || thread.Replies[0].ID != answer.ID ||
+		len(thread.Replies[0].Replies) != 1 || thread.Replies[0].Replies[0].ID != nested.ID {
+		t.Fatalf("GET thread replies = %+v, want answer with nested below it", thread.Replies)
+	}
+
+	next := Thread{}
+	doJSON(t, "GET", server.URL+"/api/chirps/"+root.ID.String()+"/thread?limit=1&cursor="+thread.NextCursor, "", nil, &next)
+	if len(next.Replies) != 1 || next.Replies[0].ID != other.ID || next.NextCursor != "" {
+		t.Errorf("GET thread second page = %+v, want only the other reply", next.Replies)
+	}
+
+	thread = Thread{}
+	doJSON(t, "GET", server.URL+"/api/chirps/"+nested.ID.String()+"/thread", "", nil, &thread)
+	if len(thread.Ancestors) != 2 || thread.Ancestors[0].ID != root.ID || thread.Ancestors[1].ID != answer.ID {
+		t.Errorf("GET nested thread ancestors = %+v, want root then answer", thread.Ancestors)
+	}
+
+	for _, chirp := range []Chirp{answer, other} {
+		if code := doJSON(t, "DELETE", server.URL+"/api/chirps/"+chirp.ID.String(), jesse.Token, nil, nil); code != http.StatusNoContent {
+			t.Fatalf("DELETE reply = %d", code)
+		}
+	}
+	thread = Thread{}
+	doJSON(t, "GET", server.URL+"/api/chirps/"+root.ID.String()+"/thread", "", nil, &thread)
+	if len(thread.Replies) != 1 {
+		t.Fatalf("GET thread after delete = %d replies, want only the deleted parent of a live reply", len(thread.Replies))
+	}
+	placeholder := thread.Replies[0]
+	if placeholder.ID != answer.ID || placeholder.Body != "" || placeholder.DeletedAt == nil || len(placeholder.Replies) != 1 {
+		t.Errorf("GET thread deleted parent = %+v, want a placeholder holding the nested reply", placeholder)
+	}
+	if thread.Chirp.ReplyCount != 0 {
+		t.Errorf("GET thread reply_count = %d, want 0 once both replies are deleted", thread.Chirp.ReplyCount)
+	}
+}
+
+func TestLikes(t *testing.T) {
+	server := newTestServer(t)
+	walt := loginTestUser(t, server, "walt@example.com")
+	jesse := loginTestUser(t, server, "jesse@example.com")
+
+	chirp := Chirp{}
+	if code := doJSON(t, "POST", server.URL+"/api/chirps", walt.Token, map[string]string{"body": "say my name"}, &chirp); code != http.StatusCreated {
+		t.Fatalf("POST /api/chirps = %d", code)
+	}
+	if chirp.LikeCount != 0 || chirp.LikedByMe == nil || *chirp.LikedByMe {
+		t.Errorf("POST /api/chirps = %+v, want no likes", chirp)
+	}
+	likesURL := server.URL + "/api/chirps/" + chirp.ID.String() + "/likes"
+
+	if code := doJSON(t, "POST", likesURL, "", nil, nil); code != http.StatusUnauthorized {
+		t.Errorf("POST likes without token = %d, want %d", code, http.StatusUnauthorized)
+	}
+	for range 2 {
+		liked := Chirp{}
+		if code := doJSON(t, "POST", likesURL, jesse.Token, nil, &liked); code != http.StatusOK {
+			t.Fatalf("POST likes = %d", code)
+		}
+		if liked.LikeCount != 1 || liked.LikedByMe == nil || !*liked.LikedByMe {
+			t.Errorf("POST likes = %+v, want one like by me", liked)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		token     string
+		wantLiked string
+	}{
+		{name: "Anonymous", token: "", wantLiked: "<nil>"},
+		{name: "Liker", token: jesse.Token, wantLiked: "true"},
+		{name: "Someone else", token: walt.Token, wantLiked: "false"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Chirp{}
+			doJSON(t, "GET", server.URL+"/api/chirps/"+chirp.ID.String(), tt.token, nil, &got)
+			if got.LikeCount != 1 {
+				t.Errorf("like_count = %d, want 1", got.LikeCount)
+			}
+			liked := "<nil>"
+			if got.LikedByMe != nil {
+				liked = strconv.FormatBool(*got.LikedByMe)
+			}
+			if liked != tt.wantLiked {
+				t.Errorf("liked_by_me = %s, want %s", liked, tt.wantLiked)
+			}
+		})
+	}
+
+	page := ChirpPage{}
+	if code := doJSON(t, "GET", server.URL+"/api/users/"+jesse.ID.String()+"/likes", "", nil, &page); code != http.StatusOK {
+		t.Fatalf("GET user likes = %d", code)
+	}
+	if len(page.Chirps) != 1 || page.Chirps[0].ID != chirp.ID {
+		t.Errorf("GET user likes = %+v, want the liked chirp", page.Chirps)
+	}
+
+	unliked := Chirp{}
+	if code := doJSON(t, "DELETE", likesURL, jesse.Token, nil, &unliked); code != http.StatusOK || unliked.LikeCount != 0 {
+		t.Errorf("DELETE likes = %d, %+v", code, unliked)
+	}
+	page = ChirpPage{}
+	doJSON(t, "GET", server.URL+"/api/users/"+jesse.ID.String()+"/likes", "", nil, &page)
+	if len(page.Chirps) != 0 {
+		t.Errorf("GET user likes after unlike = %d chirps, want 0", len(page.Chirps))
+	}
+}
+
+func TestRechirpsAndQuotes(t *testing.T) {
+	server := newTestServer(t)
+	walt := loginTestUser(t, server, "walt@example.com")
+	jesse := loginTestUser(t, server, "jesse@example.com")
+
+	original := Chirp{}
+	if code := doJSON(t, "POST", server.URL+"/api/chirps", walt.Token, map[string]string{"body": "say my name"}, &original); code != http.StatusCreated {
+		t.Fatalf("POST /api/chirps = %d", code)
+	}
+	rechirp := Chirp{}
+	if code := doJSON(t, "POST", server.URL+"/api/chirps", jesse.Token, map[string]any{"rechirp_of": original.ID}, &rechirp); code != http.StatusCreated {
+		t.Fatalf("POST rechirp = %d", code)
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		body     map[string]any
+		wantCode int
+	}{
+		{name: "Rechirp twice", token: jesse.Token, body: map[string]any{"rechirp_of": original.ID}, wantCode: http.StatusConflict},
+		{name: "Rechirp a rechirp", token: jesse.Token, body: map[string]any{"rechirp_of": rechirp.ID}, wantCode: http.StatusConflict},
+		{name: "Rechirp with a body", token: walt.Token, body: map[string]any{"rechirp_of": original.ID, "body": "hi"}, wantCode: http.StatusBadRequest},
+		{name: "Quote without a body", token: walt.Token, body: map[string]any{"quote_of": original.ID}, wantCode: http.StatusBadRequest},
+		{name: "Quote and reply", token: walt.Token, body: map[string]any{"quote_of": original.ID, "in_reply_to": original.ID, "body": "hi"}, wantCode: http.StatusBadRequest},
+		{name: "Quote a missing chirp", token: walt.Token, body: map[string]any{"quote_of": "00000000-0000-0000-0000-000000000001", "body": "hi"}, wantCode: http.StatusBadRequest},
+		{name: "Quote through a rechirp", token: walt.Token, body: map[string]any{"quote_of": rechirp.ID, "body": "you're goddamn right"}, wantCode: http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Chirp{}
+			if code := doJSON(t, "POST", server.URL+"/api/chirps", tt.token, tt.body, &got); code != tt.wantCode {
+				t.Fatalf("POST /api/chirps = %d, want %d", code, tt.wantCode)
+			}
+			if tt.wantCode == http.StatusCreated && got.QuoteOf.UUID != original.ID {
+				t.Errorf("quote_of = %v, want the original %v", got.QuoteOf, original.ID)
+			}
+		})
+	}
+
+	got := Chirp{}
+	doJSON(t, "GET", server.URL+"/api/chirps/"+original.ID.String(), "", nil, &got)
+	if got.RechirpCount != 1 || got.QuoteCount != 1 {
+		t.Errorf("GET original = %d rechirps and %d quotes, want 1 and 1", got.RechirpCount, got.QuoteCount)
+	}
+
+	rechirpURL := server.URL + "/api/chirps/" + rechirp.ID.String()
+	if code := doJSON(t, "PUT", rechirpURL, jesse.Token, map[string]string{"body": "edited"}, nil); code != http.StatusBadRequest {
+		t.Errorf("PUT rechirp = %d, want %d", code, http.StatusBadRequest)
+	}
+	if code := doJSON(t, "DELETE", rechirpURL, jesse.Token, nil, nil); code != http.StatusNoContent {
+		t.Fatalf("DELETE rechirp = %d", code)
+	}
+	var trash []Chirp
+	doJSON(t, "GET", server.URL+"/api/users/me/trash", jesse.Token, nil, &trash)
+	if len(trash) != 0 {
+		t.Errorf("GET trash after undoing a rechirp = %d chirps, want 0", len(trash))
+	}
+	if code := doJSON(t, "POST", server.URL+"/api/chirps", jesse.Token, map[string]any{"rechirp_of": original.ID}, nil); code != http.StatusCreated {
+		t.Errorf("POST rechirp after undoing it = %d, want %d", code, http.StatusCreated)
+	}
+}
+
+func TestFollowsAndTimeline(t *testing.T) {
+	server := newTestServer(t)
+	walt := loginTestUser(t, server, "walt@example.com")
+	jesse := loginTestUser(t, server, "jesse@example.com")
+	skyler := loginTestUser(t, server, "skyler@example.com")
+	waltURL := server.URL + "/api/users/" + walt.ID.String()
+
+	tests := []struct {
+		name     string
+		method   string
+		url      string
+		token    string
+		wantCode int
+	}{
+		{name: "Without token", method: "POST", url: waltURL + "/follow", token: "", wantCode: http.StatusUnauthorized},
+		{name: "Yourself", method: "POST", url: waltURL + "/follow", token: walt.Token, wantCode: http.StatusBadRequest},
+		{name: "Missing user", method: "POST", url: server.URL + "/api/users/00000000-0000-0000-0000-000000000001/follow", token: walt.Token, wantCode: http.StatusNotFound},
+		{name: "Follow", method: "POST", url: waltURL + "/follow", token: jesse.Token, wantCode: http.StatusNoContent},
+		{name: "Follow again", method: "POST", url: waltURL + "/follow", token: jesse.Token, wantCode: http.StatusNoContent},
+		{name: "Another follower", method: "POST", url: waltURL + "/follow", token: skyler.Token, wantCode: http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := doJSON(t, tt.method, tt.url, tt.token, nil, nil); code != tt.wantCode {
+				t.Errorf("%s %s = %d, want %d", tt.method, tt.url, code, tt.wantCode)
+			}
+		})
+	}
+
+	page := FollowPage{}
+	if code := doJSON(t, "GET", waltURL+"/followers?limit=1", "", nil, &page); code != http.StatusOK {
+		t.Fatalf("GET followers = %d", code)
+	}
+	if len(page.Users) != 1 || page.Users[0].ID != jesse.ID || page.NextCursor == "" {
+		t.Errorf("GET followers = %+v, want jesse and a next page", page)
+	}
+	page = FollowPage{}
+	doJSON(t, "GET", server.URL+"/api/users/"+jesse.ID.String()+"/following", "", nil, &page)
+	if len(page.Users) != 1 || page.Users[0].ID != walt.ID {
+		t.Errorf("GET following = %+v, want walt", page)
+	}
+
+	for _, post := range []struct {
+		user User
+		body string
+	}{
+		{walt, "say my name"},
+		{skyler, "I'm not in danger"},
+		{jesse, "yeah science"},
+	} {
+		if code := doJSON(t, "POST", server.URL+"/api/chirps", post.user.Token, map[string]string{"body": post.body}, nil); code != http.StatusCreated {
+			t.Fatalf("POST /api/chirps = %d", code)
+		}
+	}
+
+	timeline := ChirpPage{}
+	if code := doJSON(t, "GET", server.URL+"/api/timeline", jesse.Token, nil, &timeline); code != http.StatusOK {
+		t.Fatalf("GET timeline = %d", code)
+	}
+	var bodies []string
+	for _, chirp := range timeline.Chirps {
+		bodies = append(bodies, chirp.Body)
+	}
+	if want := []string{"yeah science", "say my name"}; !slices.Equal(bodies, want) {
+		t.Errorf("GET timeline = %q, want %q", bodies, want)
+	}
+
+	if code := doJSON(t, "DELETE", waltURL+"/follow", jesse.Token, nil, nil); code != http.StatusNoContent {
+		t.Fatalf("DELETE follow = %d", code)
+	}
+	timeline = ChirpPage{}
+	doJSON(t, "GET", server.URL+"/api/timeline", jesse.Token, nil, &timeline)
+	if len(timeline.Chirps) != 1 {
+		t.Errorf("GET timeline after unfollowing = %d chirps, want 1", len(timeline.Chirps))
+	}
+}
+
+func TestChirpContentPipeline(t *testing.T) {
+	server := newTestServer(t)
+	walt := loginTestUser(t, server, "walt@example.com")
+
+	type violationResponse struct {
+		Error      string `json:"error"`
+		Violations []struct {
+			Rule string `json:"rule"`
+		} `json:"violations"`
+	}
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		body      string
+		wantCode  int
+		wantBody  string
+		wantRules []string
+	}{
+		{name: "Create masks profanity", method: "POST", path: "/api/chirps", body: "  what a Kerfuffle ", wantCode: http.StatusCreated, wantBody: "what a ****"},
+		{name: "Create rejects long chirps", method: "POST", path: "/api/chirps", body: strings.Repeat("a", 141), wantCode: http.StatusBadRequest, wantRules: []string{"max_length"}},
+		{name: "Create rejects empty chirps", method: "POST", path: "/api/chirps", body: "   ", wantCode: http.StatusBadRequest, wantRules: []string{"not_empty"}},
+		{name: "Validate applies the same rules", method: "POST", path: "/api/validate_chirp", body: strings.Repeat("a", 141), wantCode: http.StatusBadRequest, wantRules: []string{"max_length"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var payload bytes.Buffer
+			json.NewEncoder(&payload).Encode(map[string]string{"body": tt.body})
+			req, _ := http.NewRequest(tt.method, server.URL+tt.path, &payload)
+			req.Header.Set("Authorization", "Bearer "+walt.Token)
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if res.StatusCode != tt.wantCode {
+				t.Fatalf("%s %s = %d, want %d", tt.method, tt.path, res.StatusCode, tt.wantCode)
+			}
+
+			if tt.wantRules == nil {
+				chirp := Chirp{}
+				json.NewDecoder(res.Body).Decode(&chirp)
+				if chirp.Body != tt.wantBody {
+					t.Errorf("body = %q, want %q", chirp.Body, tt.wantBody)
+				}
+				return
+			}
+			invalid := violationResponse{}
+			json.NewDecoder(res.Body).Decode(&invalid)
+			var rules []string
+			for _, v := range invalid.Violations {
+				rules = append(rules, v.Rule)
+			}
+			if !slices.Equal(rules, tt.wantRules) || invalid.Error == "" {
+				t.Errorf("violations = %+v, want rules %v", invalid, tt.wantRules)
+			}
+		})
+	}
+
+	cleaned := struct {
+		Body     string   `json:"cleaned_body"`
+		Links    []string `json:"links"`
+		Mentions []string `json:"mentions"`
+	}{}
+	body := map[string]string{"body": "@jesse fornax https://example.com"}
+	if code := doJSON(t, "POST", server.URL+"/api/validate_chirp", "", body, &cleaned); code != http.StatusOK {
+		t.Fatalf("POST /api/validate_chirp = %d", code)
+	}
+	if cleaned.Body != "@jesse **** https://example.com" || !slices.Equal(cleaned.Links, []string{"https://example.com"}) || !slices.Equal(cleaned.Mentions, []string{"jesse"}) {
+		t.Errorf("POST /api/validate_chirp = %+v", cleaned)
+	}
+
+	chirp := Chirp{}
+	doJSON(t, "POST", server.URL+"/api/chirps", walt.Token, map[string]string{"body": "tidy"}, &chirp)
+	chirpURL := server.URL + "/api/chirps/" + chirp.ID.String()
+	if code := doJSON(t, "PUT", chirpURL, walt.Token, map[string]string{"body": strings.Repeat("a", 141)}, nil); code != http.StatusBadRequest {
+		t.Errorf("PUT too long = %d, want %d", code, http.StatusBadRequest)
+	}
+	edited := Chirp{}
+	doJSON(t, "PUT", chirpURL, walt.Token, map[string]string{"body": "sharbert"}, &edited)
+	if edited.Body != "****" {
+		t.Errorf("PUT body = %q, want it masked", edited.Body)
+	}
+}
+
+func TestFilterTerms(t *testing.T) {
+	server := newTestServer(t)
+	walt := loginTestUser(t, server, "walt@example.com")
+	termsURL := server.URL + "/admin/filter_terms"
+
+	admin := loginTestAdmin(t, server)
+
+	if code := doJSON(t, "GET", termsURL, "", nil, nil); code != http.StatusUnauthorized {
+		t.Errorf("GET /admin/filter_terms without token = %d, want %d", code, http.StatusUnauthorized)
+	}
+	if code := doJSON(t, "GET", termsURL, walt.Token, nil, nil); code != http.StatusForbidden {
+		t.Errorf("GET /admin/filter_terms as a user = %d, want %d", code, http.StatusForbidden)
+	}
+
+	tests := []struct {
+		name     string
+		body     map[string]string
+		wantCode int
+	}{
+		{name: "Reject term", body: map[string]string{"term": "Cr1key", "action": "reject"}, wantCode: http.StatusCreated},
+		{name: "Flag term", body: map[string]string{"term": "blimey", "action": "flag"}, wantCode: http.StatusCreated},
+		{name: "Same term written differently", body: map[string]string{"term": "CRIKEY", "action": "mask"}, wantCode: http.StatusConflict},
+		{name: "Unknown action", body: map[string]string{"term": "gosh", "action": "delete"}, wantCode: http.StatusBadRequest},
+		{name: "More than one word", body: map[string]string{"term": "oh no", "action": "mask"}, wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := doJSON(t, "POST", termsURL, admin.Token, tt.body, nil); code != tt.wantCode {
+				t.Errorf("POST /admin/filter_terms = %d, want %d", code, tt.wantCode)
+			}
+		})
+	}
+
+	if code := doJSON(t, "POST", server.URL+"/api/chirps", walt.Token, map[string]string{"body": "crikey!"}, nil); code != http.StatusBadRequest {
+		t.Errorf("POST rejected term = %d, want %d", code, http.StatusBadRequest)
+	}
+	flagged := Chirp{}
+	if code := doJSON(t, "POST", server.URL+"/api/chirps", walt.Token, map[string]string{"body": "Bl1mey, a fornax."}, &flagged); code != http.StatusCreated {
+		t.Fatalf("POST flagged term = %d, want %d", code, http.StatusCreated)
+	}
+	if flagged.Body != "Bl1mey, a ****." {
+		t.Errorf("flagged body = %q, want only the mask term masked", flagged.Body)
+	}
+
+	var queue []FlaggedChirp
+	doJSON(t, "GET", server.URL+"/admin/flagged_chirps", admin.Token, nil, &queue)
+	if len(queue) != 1 || queue[0].Chirp.ID != flagged.ID || !slices.Equal(queue[0].Terms, []string{"blimey"}) {
+		t.Errorf("GET /admin/flagged_chirps = %+v, want the flagged chirp", queue)
+	}
+	if code := doJSON(t, "DELETE", server.URL+"/admin/flagged_chirps/"+flagged.ID.String(), admin.Token, nil, nil); code != http.StatusNoContent {
+		t.Errorf("DELETE /admin/flagged_chirps = %d, want %d", code, http.StatusNoContent)
+	}
+	queue = nil
+	doJSON(t, "GET", server.URL+"/admin/flagged_chirps", admin.Token, nil, &queue)
+	if len(queue) != 0 {
+		t.Errorf("GET /admin/flagged_chirps after dismissing = %+v, want none", queue)
+	}
+
+	var terms []FilterTerm
+	doJSON(t, "GET", termsURL, admin.Token, nil, &terms)
+	i := slices.IndexFunc(terms, func(term FilterTerm) bool { return term.Term == "crikey" })
+	if i < 0 {
+		t.Fatalf("GET /admin/filter_terms = %+v, want crikey", terms)
+	}
+	termURL := termsURL + "/" + terms[i].ID.String()
+	if code := doJSON(t, "PUT", termURL, admin.Token, map[string]string{"action": "mask"}, nil); code != http.StatusOK {
+		t.Errorf("PUT /admin/filter_terms = %d, want %d", code, http.StatusOK)
+	}
+	masked := Chirp{}
+	doJSON(t, "POST", server.URL+"/api/chirps", walt.Token, map[string]string{"body": "crikey!"}, &masked)
+	if masked.Body != "****!" {
+		t.Errorf("body after switching to mask = %q, want %q", masked.Body, "****!")
+	}
+
+	if code := doJSON(t, "DELETE", termURL, admin.Token, nil, nil); code != http.StatusNoContent {
+		t.Errorf("DELETE /admin/filter_terms = %d, want %d", code, http.StatusNoContent)
+	}
+	if code := doJSON(t, "DELETE", termURL, admin.Token, nil, nil); code != http.StatusNotFound {
+		t.Errorf("DELETE /admin/filter_terms twice = %d, want %d", code, http.StatusNotFound)
+	}
+	kept := Chirp{}
+	doJSON(t, "POST", server.URL+"/api/chirps", walt.Token, map[string]string{"body": "crikey, again"}, &kept)
+	if kept.Body != "crikey, again" {
+		t.Errorf("body after deleting the term = %q, want it untouched", kept.Body)
+	}
+}
+
+func TestChirpLengthTiers(t *testing.T) {
+	server := newTestServer(t)
+	walt := loginTestUser(t, server, "walt@example.com")
+	if walt.ChirpLengthLimit != 140 {
+		t.Errorf("chirp_length_limit = %d, want 140", walt.ChirpLengthLimit)
+	}
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+	}{
+		{name: "Emoji count as one character", body: strings.Repeat("🐦", 70), wantCode: http.StatusCreated},
+		{name: "Links count as 23", body: "read https://example.com/" + strings.Repeat("a", 150), wantCode: http.StatusCreated},
+		{name: "Over the standard limit", body: strings.Repeat("b", 141), wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := doJSON(t, "POST", server.URL+"/api/chirps", walt.Token, map[string]string{"body": tt.body}, nil); code != tt.wantCode {
+				t.Errorf("POST /api/chirps = %d, want %d", code, tt.wantCode)
+			}
+		})
+	}
+
+	upgrade := map[string]any{"event": "user.upgraded", "data": map[string]string{"user_id": walt.ID.String()}}
+	if code := doRequest(t, "POST", server.URL+"/api/polka/webhooks", "ApiKey test-polka-key", upgrade, nil); code != http.StatusNoContent {
+		t.Fatalf("POST /api/polka/webhooks = %d, want %d", code, http.StatusNoContent)
+	}
+	red := User{}
+	doJSON(t, "POST", server.URL+"/api/login", "", map[string]string{"email": "walt@example.com", "password": "hunter2"}, &red)
+	if !red.IsChirpyRed || red.ChirpLengthLimit != 280 {
+		t.Errorf("after upgrading, is_chirpy_red = %v and chirp_length_limit = %d, want true and 280", red.IsChirpyRed, red.ChirpLengthLimit)
+	}
+	if code := doJSON(t, "POST", server.URL+"/api/chirps", walt.Token, map[string]string{"body": strings.Repeat("b", 280)}, nil); code != http.StatusCreated {
+		t.Errorf("POST 280 characters as Chirpy Red = %d, want %d", code, http.StatusCreated)
+	}
+	if code := doJSON(t, "POST", server.URL+"/api/chirps", walt.Token, map[string]string{"body": strings.Repeat("c", 281)}, nil); code != http.StatusBadRequest {
+		t.Errorf("POST 281 characters as Chirpy Red = %d, want %d", code, http.StatusBadRequest)
+	}
+}
+
+func TestHashtagsAndEntities(t *testing.T) {
+	server := newTestServer(t)
+	walt := loginTestUser(t, server, "walt@example.com")
+
+	tagged := Chirp{}
+	body := map[string]string{"body": "café #Go with @Jesse https://go.dev"}
+	if code := doJSON(t, "POST", server.URL+"/api/chirps", walt.Token, body, &tagged); code != http.StatusCreated {
+		t.Fatalf("POST /api/chirps = %d, want %d", code, http.StatusCreated)
+	}
+	want := ChirpEntities{
+		Hashtags: []Entity{{Text: "go", Start: 5, End: 8}},
+		Mentions: []Entity{{Text: "jesse", Start: 14, End: 20}},
+		Links:    []Entity{{Text: "https://go.dev", Start: 21, End: 35}},
+	}
+	if !slices.Equal(tagged.Entities.Hashtags, want.Hashtags) || !slices.Equal(tagged.Entities.Mentions, want.Mentions) || !slices.Equal(tagged.Entities.Links, want.Links) {
+		t.Errorf("entities = %+v, want %+v", tagged.Entities, want)
+	}
+
+	var ids []uuid.UUID
+	for _, text := range []string{"#GO again", "#go and more", "#golang is different"} {
+		chirp := Chirp{}
+		doJSON(t, "POST", server.URL+"/api/chirps", walt.Token, map[string]string{"body": text}, &chirp)
+		ids = append(ids, chirp.ID)
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		wantCode int
+		wantIDs  []uuid.UUID
+	}{
+		{name: "Tag in any case", path: "/api/hashtags/Go", wantCode: http.StatusOK, wantIDs: []uuid.UUID{tagged.ID, ids[0], ids[1]}},
+		{name: "First page", path: "/api/hashtags/go?limit=2", wantCode: http.StatusOK, wantIDs: []uuid.UUID{tagged.ID, ids[0]}},
+		{name: "Newest first", path: "/api/hashtags/go?sort=desc&limit=1", wantCode: http.StatusOK, wantIDs: []uuid.UUID{ids[1]}},
+		{name: "Unused tag", path: "/api/hashtags/nothing", wantCode: http.StatusOK, wantIDs: nil},
+		{name: "Not a tag", path: "/api/hashtags/123", wantCode: http.StatusBadRequest},
+		{name: "No relevance without a query", path: "/api/hashtags/go?sort=relevance", wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := ChirpPage{}
+			if code := doJSON(t, "GET", server.URL+tt.path, "", nil, &page); code != tt.wantCode {
+				t.Fatalf("GET %s = %d, want %d", tt.path, code, tt.wantCode)
+			}
+			var got []uuid.UUID
+			for _, chirp := range page.Chirps {
+				got = append(got, chirp.ID)
+			}
+			if !slices.Equal(got, tt.wantIDs) {
+				t.Errorf("GET %s = %v, want %v", tt.path, got, tt.wantIDs)
+			}
+		})
+	}
+
+	edited := Chirp{}
+	doJSON(t, "PUT", server.URL+"/api/chirps/"+ids[0].String(), walt.Token, map[string]string{"body": "#rust now"}, &edited)
+	page := ChirpPage{}
+	doJSON(t, "GET", server.URL+"/api/hashtags/go", "", nil, &page)
+	if len(page.Chirps) != 2 {
+		t.Errorf("GET /api/hashtags/go after editing the tag out = %d chirps, want 2", len(page.Chirps))
+	}
+	doJSON(t, "GET", server.URL+"/api/hashtags/rust", "", nil, &page)
+	if len(page.Chirps) != 1 || page.Chirps[0].ID != ids[0] {
+		t.Errorf("GET /api/hashtags/rust after editing the tag in = %+v, want the edited chirp", page.Chirps)
+	}
+}
+
+// mentionFailingStore fails to save mentions, so writing a chirp that
+// mentions someone fails after its body and hashtags are saved.
+type mentionFailingStore struct {
+	database.Store
+}
+
+func (s mentionFailingStore) WithTx(ctx context.Context, fn func(database.Store) error) error {
+	return s.Store.WithTx(ctx, func(tx database.Store) error {
+		return fn(mentionFailingStore{tx})
+	})
+}
+
+func (s mentionFailingStore) SetChirpMentions(ctx context.Context, arg sqlc.SetChirpMentionsParams) error {
+	if len(arg.Names) > 0 {
+		return errors.New("mentions are down")
+	}
+	return s.Store.SetChirpMentions(ctx, arg)
+}
+
+func TestChirpWritesAreAtomic(t *testing.T) {
+	server := newTestServerWithStore(t, mentionFailingStore{database.NewMemory()})
+	walt := loginTestUser(t, server, "walt@example.com")
+
+	if code := doJSON(t, "POST", server.URL+"/api/chirps", walt.Token, map[string]string{"body": "#go with @jesse"}, nil); code != http.StatusInternalServerError {
+		t.Fatalf("POST /api/chirps = %d, want %d", code, http.StatusInternalServerError)
+	}
+	page := ChirpPage{}
+	doJSON(t, "GET", server.URL+"/api/chirps", "", nil, &page)
+	if len(page.Chirps) != 0 {
+		t.Errorf("GET /api/chirps after a failed post = %+v, want none", page.Chirps)
+	}
+	doJSON(t, "GET", server.URL+"/api/hashtags/go", "", nil, &page)
+	if len(page.Chirps) != 0 {
+		t.Errorf("GET /api/hashtags/go after a failed post = %+v, want none", page.Chirps)
+	}
+
+	chirp := Chirp{}
+	if code := doJSON(t, "POST", server.URL+"/api/chirps", walt.Token, map[string]string{"body": "first draft"}, &chirp); code != http.StatusCreated {
+		t.Fatalf("POST /api/chirps without mentions = %d, want %d", code, http.StatusCreated)
+	}
+	chirpURL := server.URL + "/api/chirps/" + chirp.ID.String()
+	if code := doJSON(t, "PUT", chirpURL, walt.Token, map[string]string{"body": "#go with @jesse"}, nil); code != http.StatusInternalServerError {
+		t.Fatalf("PUT %s = %d, want %d", chirpURL, code, http.StatusInternalServerError)
+	}
+	got := Chirp{}
+	doJSON(t, "GET", chirpURL, "", nil, &got)
+	if got.Body != "first draft" || got.Edited {
+		t.Errorf("GET %s after a failed edit = %+v, want the first draft", chirpURL, got)
+	}
+	var revisions []ChirpRevision
+	doJSON(t, "GET", chirpURL+"/revisions", "", nil, &revisions)
+	if len(revisions) != 0 {
+		t.Errorf("GET revisions after a failed edit = %+v, want none", revisions)
+	}
+	doJSON(t, "GET", server.URL+"/api/hashtags/go", "", nil, &page)
+	if len(page.Chirps) != 0 {
+		t.Errorf("GET /api/hashtags/go after a failed edit = %+v, want none", page.Chirps)
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	server := newTestServer(t)
+	walt := loginTestUser(t, server, "walt@example.com")
+	jesse := loginTestUser(t, server, "jesse@example.com")
+	usersURL := server.URL + "/api/users"
+
+	tests := []struct {
+		name     string
+		token    string
+		body     map[string]string
+		wantCode int
+	}{
+		{name: "Set a profile", token: walt.Token, body: map[string]string{"handle": "Heisenberg", "display_name": "Walter White", "bio": "Chemistry teacher"}, wantCode: http.StatusOK},
+		{name: "Handle taken in another case", token: jesse.Token, body: map[string]string{"handle": "heisenberg"}, wantCode: http.StatusConflict},
+		{name: "Handle too short", token: jesse.Token, body: map[string]string{"handle": "jp"}, wantCode: http.StatusBadRequest},
+		{name: "Handle with a dash", token: jesse.Token, body: map[string]string{"handle": "cap-n-cook"}, wantCode: http.StatusBadRequest},
+		{name: "Bio too long", token: jesse.Token, body: map[string]string{"bio": strings.Repeat("b", 161)}, wantCode: http.StatusBadRequest},
+		{name: "Nothing to update", token: jesse.Token, body: map[string]string{}, wantCode: http.StatusBadRequest},
+		{name: "Not logged in", body: map[string]string{"handle": "pinkman"}, wantCode: http.StatusUnauthorized},
+		{name: "Own handle in another case", token: walt.Token, body: map[string]string{"handle": "HEISENBERG"}, wantCode: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := doJSON(t, "PUT", usersURL, tt.token, tt.body, nil); code != tt.wantCode {
+				t.Errorf("PUT /api/users = %d, want %d", code, tt.wantCode)
+			}
+		})
+	}
+
+	doJSON(t, "POST", server.URL+"/api/users/"+walt.ID.String()+"/follow", jesse.Token, nil, nil)
+	doJSON(t, "POST", server.URL+"/api/chirps", walt.Token, map[string]string{"body": "Say my name"}, nil)
+	for _, ref := range []string{"heisenberg", "@Heisenberg", walt.ID.String()} {
+		profile := Profile{}
+		if code := doJSON(t, "GET", usersURL+"/"+ref, "", nil, &profile); code != http.StatusOK {
+			t.Fatalf("GET /api/users/%s = %d, want %d", ref, code, http.StatusOK)
+		}
+		if profile.ID != walt.ID || profile.Handle == nil || *profile.Handle != "HEISENBERG" || profile.DisplayName != "Walter White" ||
+			profile.ChirpCount != 1 || profile.FollowerCount != 1 || profile.FollowingCount != 0 || profile.IsChirpyRed {
+			t.Errorf("GET /api/users/%s = %+v", ref, profile)
+		}
+	}
+	if code := doJSON(t, "GET", usersURL+"/nobody", "", nil, nil); code != http.StatusNotFound {
+		t.Errorf("GET /api/users/nobody = %d, want %d", code, http.StatusNotFound)
+	}
+
+	updated := User{}
+	doJSON(t, "PUT", usersURL, walt.Token, map[string]string{"handle": "walt"}, &updated)
+	if updated.Handle == nil || *updated.Handle != "walt" || updated.Bio != "Chemistry teacher" {
+		t.Errorf("PUT /api/users handle only = %+v, want the rest of the profile kept", updated)
+	}
+	if code := doJSON(t, "PUT", usersURL, jesse.Token, map[string]string{"handle": "Heisenberg"}, nil); code != http.StatusConflict {
+		t.Errorf("PUT /api/users with a recently released handle = %d, want %d", code, http.StatusConflict)
+	}
+	if code := doJSON(t, "PUT", usersURL, walt.Token, map[string]string{"handle": "Heisenberg"}, nil); code != http.StatusOK {
+		t.Errorf("PUT /api/users taking back your own released handle = %d, want %d", code, http.StatusOK)
+	}
+
+	config.APIConfig().HandleReserve = 0
+	t.Cleanup(func() { config.APIConfig().HandleReserve = 30 * 24 * time.Hour })
+	doJSON(t, "PUT", usersURL, walt.Token, map[string]string{"handle": ""}, nil)
+	if code := doJSON(t, "PUT", usersURL, jesse.Token, map[string]string{"handle": "Heisenberg"}, nil); code != http.StatusOK {
+		t.Errorf("PUT /api/users with a handle whose reservation ran out = %d, want %d", code, http.StatusOK)
+	}
+}
+
+func TestChangeCredentialsToTakenEmail(t *testing.T) {
+	server := newTestServer(t)
+	walt := loginTestUser(t, server, "walt@example.com")
+	loginTestUser(t, server, "jesse@example.com")
+
+	body := map[string]string{"email": "jesse@example.com", "password": "hunter3"}
+	if code := doJSON(t, "PUT", server.URL+"/api/users", walt.Token, body, nil); code != http.StatusConflict {
+		t.Fatalf("PUT /api/users with a taken email = %d, want %d", code, http.StatusConflict)
+	}
+	if code := doJSON(t, "GET", server.URL+"/api/timeline", walt.Token, nil, nil); code != http.StatusOK {
+		t.Errorf("GET /api/timeline after a refused change = %d, want %d", code, http.StatusOK)
+	}
+	login := map[string]string{"email": "walt@example.com", "password": "hunter2"}
+	if code := doJSON(t, "POST", server.URL+"/api/login", "", login, nil); code != http.StatusOK {
+		t.Errorf("POST /api/login with the old email and password after a refused change = %d, want %d", code, http.StatusOK)
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	server := newTestServer(t)
+	walt := loginTestUser(t, server, "walt@example.com")
+	meURL := server.URL + "/api/users/me"
+
+	if code := doJSON(t, "DELETE", meURL, "", map[string]string{"password": "hunter2"}, nil); code != http.StatusUnauthorized {
+		t.Errorf("DELETE /api/users/me without token = %d, want %d", code, http.StatusUnauthorized)
+	}
+	if code := doJSON(t, "DELETE", meURL, walt.Token, map[string]string{"password": "wrong"}, nil); code != http.StatusUnauthorized {
+		t.Errorf("DELETE /api/users/me with the wrong password = %d, want %d", code, http.StatusUnauthorized)
+	}
+
+	deleted := User{}
+	if code := doJSON(t, "DELETE", meURL, walt.Token, map[string]string{"password": "hunter2"}, &deleted); code != http.StatusAccepted {
+		t.Fatalf("DELETE /api/users/me = %d, want %d", code, http.StatusAccepted)
+	}
+	if deleted.DeleteAfter == nil || time.Until(*deleted.DeleteAfter) < config.APIConfig().DeletionGrace-time.Minute {
+		t.Errorf("DELETE /api/users/me delete_after = %v, want the grace period from now", deleted.DeleteAfter)
+	}
+	if code := doRequest(t, "POST", server.URL+"/api/refresh", "Bearer "+walt.Refresh, nil, nil); code != http.StatusUnauthorized {
+		t.Errorf("POST /api/refresh after deleting = %d, want %d", code, http.StatusUnauthorized)
+	}
+
+	if code := doJSON(t, "DELETE", meURL, walt.Token, map[string]string{"password": "hunter2"}, nil); code != http.StatusUnauthorized {
+		t.Errorf("DELETE /api/users/me with an access token from before deleting = %d, want %d", code, http.StatusUnauthorized)
+	}
+
+	loggedIn := User{}
+	credentials := map[string]string{"email": "walt@example.com", "password": "hunter2"}
+	if code := doJSON(t, "POST", server.URL+"/api/login", "", credentials, &loggedIn); code != http.StatusOK || loggedIn.DeleteAfter != nil {
+		t.Errorf("POST /api/login during the grace period = %d, delete_after %v, want the deletion cancelled", code, loggedIn.DeleteAfter)
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	server := newTestServer(t)
+	walt := loginTestUser(t, server, "walt@example.com")
+	jesse := loginTestUser(t, server, "jesse@example.com")
+
+	original := Chirp{}
+	doJSON(t, "POST", server.URL+"/api/chirps", walt.Token, map[string]string{"body": "Say my #name"}, &original)
+	reply := Chirp{}
+	doJSON(t, "POST", server.URL+"/api/chirps", walt.Token, map[string]any{"body": "You're goddamn right", "in_reply_to": original.ID}, &reply)
+	trashed := Chirp{}
+	doJSON(t, "POST", server.URL+"/api/chirps", walt.Token, map[string]string{"body": "Tread lightly"}, &trashed)
+	doJSON(t, "DELETE", server.URL+"/api/chirps/"+trashed.ID.String(), walt.Token, nil, nil)
+	danger := Chirp{}
+	doJSON(t, "POST", server.URL+"/api/chirps", jesse.Token, map[string]string{"body": "I am the danger"}, &danger)
+	rechirp := Chirp{}
+	doJSON(t, "POST", server.URL+"/api/chirps", walt.Token, map[string]any{"rechirp_of": danger.ID}, &rechirp)
+
+	if code := doJSON(t, "GET", server.URL+"/api/users/me/export", "", nil, nil); code != http.StatusUnauthorized {
+		t.Errorf("GET /api/users/me/export without token = %d, want %d", code, http.StatusUnauthorized)
+	}
+	export := Export{}
+	if code := doJSON(t, "GET", server.URL+"/api/users/me/export", walt.Token, nil, &export); code != http.StatusAccepted || export.Status != "pending" {
+		t.Fatalf("GET /api/users/me/export = %d, %+v, want a pending export", code, export)
+	}
+	exportURL := server.URL + "/api/users/me/exports/" + export.ID.String()
+	if code, _ := doRaw(t, "GET", exportURL, jesse.Token, nil); code != http.StatusNotFound {
+		t.Errorf("GET someone else's export = %d, want %d", code, http.StatusNotFound)
+	}
+
+	var data []byte
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		code, body := doRaw(t, "GET", exportURL, walt.Token, nil)
+		if code == http.StatusOK {
+			data = body
+			break
+		}
+		if code != http.StatusAccepted || time.Now().After(deadline) {
+			t.Fatalf("GET %s = %d, want the archive", exportURL, code)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	req, err := http.NewRequest("GET", exportURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+walt.Token)
+	req.Header.Set("Range", "bytes=0-3")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	if res.StatusCode != http.StatusPartialContent || !bytes.Equal(part, data[:4]) || res.ContentLength != 4 {
+		t.Errorf("GET %s with a range = %d, %q, want %d and the first 4 bytes", exportURL, res.StatusCode, part, http.StatusPartialContent)
+	}
+
+	a, _, err := archive.Read(data)
+	if err != nil {
+		t.Fatalf("archive.Read() error = %v", err)
+	}
+	if a.Profile.ID != walt.ID || a.Profile.Email != "walt@example.com" || len(a.Chirps) != 4 || len(a.Sessions) != 1 {
+		t.Errorf("exported archive = %+v, want walt's profile, 4 chirps and 1 session", a)
+	}
+
+	// Import into a new account while walt's still has the same chirps.
+	heisenberg := loginTestUser(t, server, "heisenberg@example.com")
+	importURL := server.URL + "/api/users/me/import"
+	result := ImportResult{}
+	if code := doRawJSON(t, importURL, heisenberg.Token, data, &result); code != http.StatusOK {
+		t.Fatalf("POST /api/users/me/import = %d, want %d", code, http.StatusOK)
+	}
+	if result.Imported != 3 || len(result.Skipped) != 1 || result.Skipped[0].ID != trashed.ID {
+		t.Errorf("POST /api/users/me/import = %+v, want 3 imported and the trashed chirp skipped", result)
+	}
+
+	page := ChirpPage{}
+	doJSON(t, "GET", server.URL+"/api/chirps?sort=asc&author_id="+heisenberg.ID.String(), "", nil, &page)
+	if len(page.Chirps) != 3 {
+		t.Fatalf("GET /api/chirps imported = %d chirps, want 3", len(page.Chirps))
+	}
+	first, second, third := page.Chirps[0], page.Chirps[1], page.Chirps[2]
+	if first.Body != original.Body || !first.CreatedAt.Equal(original.CreatedAt) || len(first.Entities.Hashtags) != 1 {
+		t.Errorf("imported chirp = %+v, want %+v with its timestamp and hashtag", first, original)
+	}
+	if second.InReplyTo.UUID != first.ID || !second.CreatedAt.Equal(reply.CreatedAt) {
+		t.Errorf("imported reply = %+v, want it to reply to the imported %s", second, first.ID)
+	}
+	if third.RechirpOf.UUID != danger.ID || !third.CreatedAt.Equal(rechirp.CreatedAt) {
+		t.Errorf("imported rechirp = %+v, want a rechirp of %s", third, danger.ID)
+	}
+
+	again := ImportResult{}
+	doRawJSON(t, importURL, heisenberg.Token, data, &again)
+	if again.Imported != 0 || len(again.Skipped) != 4 {
+		t.Errorf("POST /api/users/me/import again = %+v, want everything skipped", again)
+	}
+	for _, skipped := range again.Skipped {
+		if skipped.ID == original.ID && skipped.Reason != "You already have a chirp that says this" {
+			t.Errorf("POST /api/users/me/import again skipped %s because %q, want it already posted", skipped.ID, skipped.Reason)
+		}
+	}
+	if code := doRawJSON(t, importURL, heisenberg.Token, []byte("not a zip"), nil); code != http.StatusBadRequest {
+		t.Errorf("POST /api/users/me/import garbage = %d, want %d", code, http.StatusBadRequest)
+	}
+}
+
+// doRaw sends body as is and returns the raw response, for endpoints that
+// don't speak JSON.
+func doRaw(t *testing.T, method, url, token string, body []byte) (int, []byte) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res.StatusCode, data
+}
+
+func doRawJSON(t *testing.T, url, token string, body []byte, out any) int {
+	t.Helper()
+	code, data := doRaw(t, "POST", url, token, body)
+	if out != nil && code < 300 {
+		if err := json.Unmarshal(data, out); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return code
+}
+
+func TestRefreshRotation(t *testing.T) {
+	server := newTestServer(t)
+	walt := loginTestUser(t, server, "walt@example.com")
+	refreshURL := server.URL + "/api/refresh"
+
+	type tokens struct {
+		Token   string `json:"token"`
+		Refresh string `json:"refresh_token"`
+	}
+	first := tokens{}
+	if code := doRequest(t, "POST", refreshURL, "Bearer "+walt.Refresh, nil, &first); code != http.StatusOK {
+		t.Fatalf("POST /api/refresh = %d, want %d", code, http.StatusOK)
+	}
+	if first.Token == "" || first.Refresh == "" || first.Refresh == walt.Refresh {
+		t.Fatalf("POST /api/refresh = %+v, want a new access and refresh token", first)
+	}
+	if code := doJSON(t, "GET", server.URL+"/api/timeline", first.Token, nil, nil); code != http.StatusOK {
+		t.Errorf("GET /api/timeline with the new access token = %d, want %d", code, http.StatusOK)
+	}
+	second := tokens{}
+	if code := doRequest(t, "POST", refreshURL, "Bearer "+first.Refresh, nil, &second); code != http.StatusOK {
+		t.Fatalf("POST /api/refresh with the rotated token = %d, want %d", code, http.StatusOK)
+	}
+
+	// Another login is a separate family and survives the reuse below.
+	other := User{}
+	doJSON(t, "POST", server.URL+"/api/login", "", map[string]string{"email": "walt@example.com", "password": "hunter2"}, &other)
+
+	if code := doRequest(t, "POST", refreshURL, "Bearer "+walt.Refresh, nil, nil); code != http.StatusUnauthorized {
+		t.Errorf("POST /api/refresh reusing a rotated token = %d, want %d", code, http.StatusUnauthorized)
+	}
+	if code := doRequest(t, "POST", refreshURL, "Bearer "+second.Refresh, nil, nil); code != http.StatusUnauthorized {
+		t.Errorf("POST /api/refresh with the latest token after reuse = %d, want %d", code, http.StatusUnauthorized)
+	}
+	if code := doJSON(t, "GET", server.URL+"/api/timeline", second.Token, nil, nil); code != http.StatusUnauthorized {
+		t.Errorf("GET /api/timeline with an access token from the revoked session = %d, want %d", code, http.StatusUnauthorized)
+	}
+	if code := doRequest(t, "POST", refreshURL, "Bearer "+other.Refresh, nil, nil); code != http.StatusOK {
+		t.Errorf("POST /api/refresh from another login = %d, want %d", code, http.StatusOK)
+	}
+	if code := doRequest(t, "POST", refreshURL, "Bearer unknown", nil, nil); code != http.StatusUnauthorized {
+		t.Errorf("POST /api/refresh with an unknown token = %d, want %d", code, http.StatusUnauthorized)
+	}
+}
+
+func TestSessions(t *testing.T) {
+	server := newTestServer(t)
+	laptop := loginTestUser(t, server, "walt@example.com")
+	jesse := loginTestUser(t, server, "jesse@example.com")
+	sessionsURL := server.URL + "/api/sessions"
+
+	body, err := json.Marshal(map[string]string{"email": "walt@example.com", "password": "hunter2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest("POST", server.URL+"/api/login", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("User-Agent", "Chirpy for iOS")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	phone := User{}
+	err = json.NewDecoder(res.Body).Decode(&phone)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if code := doJSON(t, "GET", sessionsURL, "", nil, nil); code != http.StatusUnauthorized {
+		t.Errorf("GET /api/sessions without token = %d, want %d", code, http.StatusUnauthorized)
+	}
+	var sessions []Session
+	if code := doJSON(t, "GET", sessionsURL, laptop.Token, nil, &sessions); code != http.StatusOK || len(sessions) != 2 {
+		t.Fatalf("GET /api/sessions = %d, %+v, want 2 sessions", code, sessions)
+	}
+	if sessions[0].UserAgent != "Chirpy for iOS" || sessions[0].Current || !sessions[1].Current || sessions[1].IP != "127.0.0.1" {
+		t.Errorf("GET /api/sessions = %+v, want the phone first and the laptop current", sessions)
+	}
+	phoneURL := sessionsURL + "/" + sessions[0].ID.String()
+
+	if code := doJSON(t, "DELETE", phoneURL, jesse.Token, nil, nil); code != http.StatusNotFound {
+		t.Errorf("DELETE /api/sessions/{sessionID} someone else's = %d, want %d", code, http.StatusNotFound)
+	}
+	if code := doJSON(t, "DELETE", phoneURL, laptop.Token, nil, nil); code != http.StatusNoContent {
+		t.Fatalf("DELETE /api/sessions/{sessionID} = %d, want %d", code, http.StatusNoContent)
+	}
+	if code := doJSON(t, "GET", sessionsURL, phone.Token, nil, nil); code != http.StatusUnauthorized {
+		t.Errorf("GET /api/sessions with the revoked session's access token = %d, want %d", code, http.StatusUnauthorized)
+	}
+	if code := doRequest(t, "POST", server.URL+"/api/refresh", "Bearer "+phone.Refresh, nil, nil); code != http.StatusUnauthorized {
+		t.Errorf("POST /api/refresh with the revoked session's refresh token = %d, want %d", code, http.StatusUnauthorized)
+	}
+	if code := doJSON(t, "DELETE", phoneURL, laptop.Token, nil, nil); code != http.StatusNotFound {
+		t.Errorf("DELETE /api/sessions/{sessionID} again = %d, want %d", code, http.StatusNotFound)
+	}
+
+	if code := doRequest(t, "POST", server.URL+"/api/revoke", "Bearer "+jesse.Refresh, nil, nil); code != http.StatusNoContent {
+		t.Errorf("POST /api/revoke = %d, want %d", code, http.StatusNoContent)
+	}
+	if code := doJSON(t, "GET", sessionsURL, jesse.Token, nil, nil); code != http.StatusUnauthorized {
+		t.Errorf("GET /api/sessions after logging out = %d, want %d", code, http.StatusUnauthorized)
+	}
+
+	other := User{}
+	doJSON(t, "POST", server.URL+"/api/login", "", map[string]string{"email": "walt@example.com", "password": "hunter2"}, &other)
+	if code := doJSON(t, "POST", sessionsURL+"/revoke-all", laptop.Token, nil, nil); code != http.StatusNoContent {
+		t.Fatalf("POST /api/sessions/revoke-all = %d, want %d", code, http.StatusNoContent)
+	}
+	for _, token := range []string{laptop.Token, other.Token} {
+		if code := doJSON(t, "GET", sessionsURL, token, nil, nil); code != http.StatusUnauthorized {
+			t.Errorf("GET /api/sessions after logging out everywhere = %d, want %d", code, http.StatusUnauthorized)
+		}
+	}
+}
+
+func TestRevokeAccessToken(t *testing.T) {
+	server := newTestServer(t)
+	walt := loginTestUser(t, server, "walt@example.com")
+	revokeURL := server.URL + "/api/revoke"
+	timelineURL := server.URL + "/api/timeline"
+
+	if code := doRequest(t, "POST", revokeURL, "Bearer "+walt.Token, nil, nil); code != http.StatusNoContent {
+		t.Fatalf("POST /api/revoke with an access token = %d, want %d", code, http.StatusNoContent)
+	}
+	if code := doJSON(t, "GET", timelineURL, walt.Token, nil, nil); code != http.StatusUnauthorized {
+		t.Errorf("GET /api/timeline with a revoked access token = %d, want %d", code, http.StatusUnauthorized)
+	}
+	refreshed := User{}
+	if code := doRequest(t, "POST", server.URL+"/api/refresh", "Bearer "+walt.Refresh, nil, &refreshed); code != http.StatusOK {
+		t.Fatalf("POST /api/refresh after revoking the access token = %d, want %d", code, http.StatusOK)
+	}
+	if code := doJSON(t, "GET", timelineURL, refreshed.Token, nil, nil); code != http.StatusOK {
+		t.Errorf("GET /api/timeline with a new access token from the same session = %d, want %d", code, http.StatusOK)
+	}
+}
+
+func TestChangePasswordRevokesTokens(t *testing.T) {
+	server := newTestServer(t)
+	walt := loginTestUser(t, server, "walt@example.com")
+	other := User{}
+	doJSON(t, "POST", server.URL+"/api/login", "", map[string]string{"email": "walt@example.com", "password": "hunter2"}, &other)
+	timelineURL := server.URL + "/api/timeline"
+
+	updated := User{}
+	if code := doJSON(t, "PUT", server.URL+"/api/users", walt.Token, map[string]string{"password": "hunter3"}, &updated); code != http.StatusOK {
+		t.Fatalf("PUT /api/users with a new password = %d, want %d", code, http.StatusOK)
+	}
+	for _, token := range []string{walt.Token, other.Token} {
+		if code := doJSON(t, "GET", timelineURL, token, nil, nil); code != http.StatusUnauthorized {
+			t.Errorf("GET /api/timeline with an access token from before the password change = %d, want %d", code, http.StatusUnauthorized)
+		}
+	}
+	if code := doRequest(t, "POST", server.URL+"/api/refresh", "Bearer "+other.Refresh, nil, nil); code != http.StatusUnauthorized {
+		t.Errorf("POST /api/refresh with a refresh token from before the password change = %d, want %d", code, http.StatusUnauthorized)
+	}
+	if code := doJSON(t, "GET", timelineURL, updated.Token, nil, nil); code != http.StatusOK || updated.Refresh == "" {
+		t.Errorf("GET /api/timeline with the token from the password change = %d, want %d", code, http.StatusOK)
+	}
+
+	unchanged := User{}
+	doJSON(t, "PUT", server.URL+"/api/users", updated.Token, map[string]string{"bio": "I am the one who knocks"}, &unchanged)
+	if unchanged.Token != "" {
+		t.Error("PUT /api/users without a new password should not issue tokens")
+	}
+	if code := doJSON(t, "GET", timelineURL, updated.Token, nil, nil); code != http.StatusOK {
+		t.Errorf("GET /api/timeline after a profile change = %d, want %d", code, http.StatusOK)
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	server := newTestServer(t)
+	walt := loginTestUser(t, server, "walt@example.com")
+
+	var jwks struct {
+		Keys []auth.JWK `json:"keys"`
+	}
+	if code := doJSON(t, "GET", server.URL+"/.well-known/jwks.json", "", nil, &jwks); code != http.StatusOK {
+		t.Fatalf("GET /.well-known/jwks.json = %d, want %d", code, http.StatusOK)
+	}
+	if len(jwks.Keys) != 1 || jwks.Keys[0].KeyID != "test" || jwks.Keys[0].Algorithm != "EdDSA" || jwks.Keys[0].X == "" {
+		t.Fatalf("GET /.well-known/jwks.json = %+v, want the test key", jwks.Keys)
+	}
+
+	x, err := base64.RawURLEncoding.DecodeString(jwks.Keys[0].X)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := jwt.Parse(walt.Token, func(token *jwt.Token) (any, error) {
+		return ed25519.PublicKey(x), nil
+	}, jwt.WithValidMethods([]string{jwks.Keys[0].Algorithm}))
+	if err != nil || token.Header["kid"] != jwks.Keys[0].KeyID {
+		t.Errorf("jwt.Parse() of an access token with the published key = %v, %v", token, err)
+	}
+}
+
+func TestAccessTokenRefusals(t *testing.T) {
+	server := newTestServer(t)
+	loginTestUser(t, server, "walt@example.com")
+	cfg := config.APIConfig()
+	login := func() string {
+		t.Helper()
+		user := User{}
+		if code := doJSON(t, "POST", server.URL+"/api/login", "", map[string]string{"email": "walt@example.com", "password": "hunter2"}, &user); code != http.StatusOK {
+			t.Fatalf("POST /api/login = %d, want %d", code, http.StatusOK)
+		}
+		return user.Token
+	}
+
+	accessDuration := cfg.TokenDuration["access"]
+	cfg.TokenDuration["access"] = -time.Hour
+	expired := login()
+	cfg.TokenDuration["access"] = accessDuration
+
+	audiences := cfg.JWTAudiences
+	cfg.JWTAudiences = []string{"los-pollos"}
+	wrongAudience := login()
+	cfg.JWTAudiences = audiences
+
+	issuer := cfg.JWTIssuer
+	cfg.JWTIssuer = "heisenberg"
+	wrongIssuer := login()
+	cfg.JWTIssuer = issuer
+
+	valid := login()
+	tampered := valid[:len(valid)-4] + "AAAA"
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantError     string
+		wantChallenge string
+	}{
+		{name: "No token", wantError: "Unauthorized", wantChallenge: "Bearer"},
+		{name: "Expired", authorization: "Bearer " + expired, wantError: "Access token has expired"},
+		{name: "Wrong audience", authorization: "Bearer " + wrongAudience, wantError: "Access token is not meant for Chirpy"},
+		{name: "Wrong issuer", authorization: "Bearer " + wrongIssuer, wantError: "Access token was not issued by Chirpy"},
+		{name: "Bad signature", authorization: "Bearer " + tampered, wantError: "Access token signature is invalid"},
+		{name: "Garbage", authorization: "Bearer not-a-jwt", wantError: "Unauthorized. JWT not valid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", server.URL+"/api/timeline", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			var body struct {
+				Error string `json:"error"`
+			}
+			if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+
+			wantChallenge := tt.wantChallenge
+			if wantChallenge == "" {
+				wantChallenge = `Bearer, error="invalid_token", error_description="` + tt.wantError + `"`
+			}
+			if res.StatusCode != http.StatusUnauthorized || body.Error != tt.wantError {
+				t.Errorf("GET /api/timeline = %d %q, want %d %q", res.StatusCode, body.Error, http.StatusUnauthorized, tt.wantError)
+			}
+			if got := res.Header.Get("WWW-Authenticate"); got != wantChallenge {
+				t.Errorf("WWW-Authenticate = %q, want %q", got, wantChallenge)
+			}
+		})
+	}
+
+	if code := doJSON(t, "GET", server.URL+"/api/timeline", valid, nil, nil); code != http.StatusOK {
+		t.Errorf("GET /api/timeline with a valid token = %d, want %d", code, http.StatusOK)
+	}
+}
+
+func TestRoles(t *testing.T) {
+	server := newTestServer(t)
+	admin := loginTestAdmin(t, server)
+	walt := loginTestUser(t, server, "walt@example.com")
+	roleURL := server.URL + "/admin/users/" + walt.ID.String() + "/role"
+	termsURL := server.URL + "/admin/filter_terms"
+
+	if admin.Role != "admin" || walt.Role != "user" {
+		t.Errorf("POST /api/login roles = %q and %q, want admin and user", admin.Role, walt.Role)
+	}
+	req, err := http.NewRequest("POST", server.URL+"/admin/reset", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+walt.Token)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	challenge := `Bearer, error="insufficient_scope", scope="admin"`
+	if res.StatusCode != http.StatusForbidden || res.Header.Get("WWW-Authenticate") != challenge {
+		t.Errorf("POST /admin/reset as a user = %d %q, want %d %q", res.StatusCode, res.Header.Get("WWW-Authenticate"), http.StatusForbidden, challenge)
+	}
+	if code := doJSON(t, "PUT", roleURL, walt.Token, map[string]string{"role": "admin"}, nil); code != http.StatusForbidden {
+		t.Errorf("PUT role as a user = %d, want %d", code, http.StatusForbidden)
+	}
+	if code := doJSON(t, "PUT", roleURL, admin.Token, map[string]string{"role": "kingpin"}, nil); code != http.StatusBadRequest {
+		t.Errorf("PUT an unknown role = %d, want %d", code, http.StatusBadRequest)
+	}
+
+	promoted := User{}
+	if code := doJSON(t, "PUT", roleURL, admin.Token, map[string]string{"role": "admin"}, &promoted); code != http.StatusOK || promoted.Role != "admin" {
+		t.Fatalf("PUT role = %d %q, want %d admin", code, promoted.Role, http.StatusOK)
+	}
+	if code := doJSON(t, "GET", server.URL+"/api/timeline", walt.Token, nil, nil); code != http.StatusUnauthorized {
+		t.Errorf("GET /api/timeline with a token from before the role change = %d, want %d", code, http.StatusUnauthorized)
+	}
+	walt = User{}
+	doJSON(t, "POST", server.URL+"/api/login", "", map[string]string{"email": "walt@example.com", "password": "hunter2"}, &walt)
+	if code := doJSON(t, "GET", termsURL, walt.Token, nil, nil); code != http.StatusOK {
+		t.Errorf("GET /admin/filter_terms as a new admin = %d, want %d", code, http.StatusOK)
+	}
+
+	doJSON(t, "PUT", roleURL, admin.Token, map[string]string{"role": "user"}, nil)
+	refreshed := User{}
+	if code := doRequest(t, "POST", server.URL+"/api/refresh", "Bearer "+walt.Refresh, nil, &refreshed); code != http.StatusUnauthorized {
+		t.Errorf("POST /api/refresh after losing the admin role = %d, want %d", code, http.StatusUnauthorized)
+	}
+}
+
+func TestAPIKeys(t *testing.T) {
+	server := newTestServer(t)
+	walt := loginTestUser(t, server, "walt@example.com")
+	jesse := loginTestUser(t, server, "jesse@example.com")
+	keysURL := server.URL + "/api/users/me/api_keys"
+	timelineURL := server.URL + "/api/timeline"
+
+	tests := []struct {
+		name string
+		body map[string]any
+	}{
+		{name: "No name", body: map[string]any{"name": " ", "scopes": []string{"chirps:read"}}},
+		{name: "No scopes", body: map[string]any{"name": "bot", "scopes": []string{}}},
+		{name: "Account scope", body: map[string]any{"name": "bot", "scopes": []string{"chirps:read", "account"}}},
+		{name: "Admin scope as a user", body: map[string]any{"name": "bot", "scopes": []string{"admin"}}},
+		{name: "Unknown scope", body: map[string]any{"name": "bot", "scopes": []string{"chirps:delete"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := doJSON(t, "POST", keysURL, walt.Token, tt.body, nil); code != http.StatusBadRequest {
+				t.Errorf("POST /api/users/me/api_keys = %d, want %d", code, http.StatusBadRequest)
+			}
+		})
+	}
+
+	created := APIKey{}
+	body := map[string]any{"name": "Cook bot", "scopes": []string{"chirps:write", "chirps:read", "chirps:write"}}
+	if code := doJSON(t, "POST", keysURL, walt.Token, body, &created); code != http.StatusCreated {
+		t.Fatalf("POST /api/users/me/api_keys = %d, want %d", code, http.StatusCreated)
+	}
+	if !strings.HasPrefix(created.Key, created.Prefix+"_") || !slices.Equal(created.Scopes, []string{"chirps:read", "chirps:write"}) {
+		t.Errorf("POST /api/users/me/api_keys = %+v, want a key starting with its prefix and each scope once", created)
+	}
+	apiKey := "ApiKey " + created.Key
+
+	if code := doRequest(t, "POST", server.URL+"/api/chirps", apiKey, map[string]string{"body": "Say my name"}, nil); code != http.StatusCreated {
+		t.Errorf("POST /api/chirps with an API key = %d, want %d", code, http.StatusCreated)
+	}
+	if code := doRequest(t, "GET", timelineURL, apiKey, nil, nil); code != http.StatusOK {
+		t.Errorf("GET /api/timeline with an API key = %d, want %d", code, http.StatusOK)
+	}
+	if code := doRequest(t, "POST", server.URL+"/api/users/"+jesse.ID.String()+"/follow", apiKey, nil, nil); code != http.StatusForbidden {
+		t.Errorf("POST follow with an API key without follows:write = %d, want %d", code, http.StatusForbidden)
+	}
+	if code := doRequest(t, "GET", keysURL, apiKey, nil, nil); code != http.StatusForbidden {
+		t.Errorf("GET /api/users/me/api_keys with an API key = %d, want %d", code, http.StatusForbidden)
+	}
+
+	var keys []APIKey
+	if code := doJSON(t, "GET", keysURL, walt.Token, nil, &keys); code != http.StatusOK {
+		t.Fatalf("GET /api/users/me/api_keys = %d, want %d", code, http.StatusOK)
+	}
+	if len(keys) != 1 || keys[0].ID != created.ID || keys[0].Key != "" || keys[0].Name != "Cook bot" || keys[0].LastUsedAt == nil {
+		t.Errorf("GET /api/users/me/api_keys = %+v, want the key, used, without the key itself", keys)
+	}
+	keys = nil
+	doJSON(t, "GET", keysURL, jesse.Token, nil, &keys)
+	if len(keys) != 0 {
+		t.Errorf("GET /api/users/me/api_keys as someone else = %+v, want none", keys)
+	}
+
+	keyURL := keysURL + "/" + created.ID.String()
+	if code := doJSON(t, "DELETE", keyURL, jesse.Token, nil, nil); code != http.StatusNotFound {
+		t.Errorf("DELETE someone else's API key = %d, want %d", code, http.StatusNotFound)
+	}
+	if code := doJSON(t, "DELETE", keyURL, walt.Token, nil, nil); code != http.StatusNoContent {
+		t.Errorf("DELETE /api/users/me/api_keys/{keyID} = %d, want %d", code, http.StatusNoContent)
+	}
+	if code := doJSON(t, "DELETE", keyURL, walt.Token, nil, nil); code != http.StatusNotFound {
+		t.Errorf("DELETE an API key twice = %d, want %d", code, http.StatusNotFound)
+	}
+
+	refusals := []struct {
+		authorization string
+		wantError     string
+	}{
+		{authorization: apiKey, wantError: "API key has been revoked"},
+		{authorization: "ApiKey chirpy_00000000_nope", wantError: "API key is not valid"},
+		{authorization: "ApiKey test-polka-key", wantError: "API key is not valid"},
+	}
+	for _, refusal := range refusals {
+		req, err := http.NewRequest("GET", timelineURL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", refusal.authorization)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(res.Body).Decode(&got)
+		res.Body.Close()
+		if res.StatusCode != http.StatusUnauthorized || got.Error != refusal.wantError || !strings.HasPrefix(res.Header.Get("WWW-Authenticate"), "ApiKey") {
+			t.Errorf("GET /api/timeline with %q = %d %q, want %d %q", refusal.authorization, res.StatusCode, got.Error, http.StatusUnauthorized, refusal.wantError)
+		}
+	}
+
+	doJSON(t, "POST", keysURL, walt.Token, map[string]any{"name": "Backup", "scopes": []string{"chirps:read"}}, &created)
+	if code := doJSON(t, "DELETE", server.URL+"/api/users/me", walt.Token, map[string]string{"password": "hunter2"}, nil); code != http.StatusAccepted {
+		t.Fatalf("DELETE /api/users/me = %d, want %d", code, http.StatusAccepted)
+	}
+	if code := doRequest(t, "GET", timelineURL, "ApiKey "+created.Key, nil, nil); code != http.StatusUnauthorized {
+		t.Errorf("GET /api/timeline with an API key while the account is being deleted = %d, want %d", code, http.StatusUnauthorized)
+	}
+}
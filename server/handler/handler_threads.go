@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"github.com/google/uuid"
+	"github.com/pcauce/chirpy/internal/sqlc"
+	"github.com/pcauce/chirpy/server/respond"
+	"net/http"
+)
+
+// maxThreadDepth is how many levels of replies a thread includes below the
+// chirp it was requested for. Deeper replies show up in reply_count and can
+// be read by asking for the thread of their parent.
+const maxThreadDepth = 5
+
+type ThreadReply struct {
+	Chirp
+	Replies []ThreadReply `json:"replies"`
+}
+
+type Thread struct {
+	Ancestors  []Chirp       `json:"ancestors"`
+	Chirp      Chirp         `json:"chirp"`
+	Replies    []ThreadReply `json:"replies"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+	PrevCursor string        `json:"prev_cursor,omitempty"`
+}
+
+// GetChirpThread returns the chain of chirps a chirp replies to, root first,
+// and a page of its direct replies, each with their own replies nested below.
+// Trashed chirps are kept as placeholders without a body when the thread
+// would otherwise come apart without them.
+func (h *Handler) GetChirpThread(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respond.WithError(w, http.StatusBadRequest, "Couldn't parse chirp ID", err)
+		return
+	}
+	p, err := parsePage(r.URL.Query())
+	if err != nil {
+		respond.WithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	if p.ByRelevance {
+		respond.WithError(w, http.StatusBadRequest, "Sorting by relevance needs a search query", nil)
+		return
+	}
+
+	chirp, err := h.store.GetChirpByID(r.Context(), chirpID)
+	if err != nil {
+		respond.WithError(w, http.StatusNotFound, "Couldn't get chirp", err)
+		return
+	}
+	ancestors, err := h.store.GetChirpAncestors(r.Context(), chirp.ID)
+	if err != nil {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't get thread", err)
+		return
+	}
+
+	inReplyTo := uuid.NullUUID{UUID: chirp.ID, Valid: true}
+	replies, next, prev, err := fetchPage(p, func(ascending bool, from *cursor, limit int32) ([]sqlc.Chirp, error) {
+		if ascending {
+			return h.store.ListRepliesAscending(r.Context(), sqlc.ListRepliesAscendingParams{
+				InReplyTo:       inReplyTo,
+				CursorCreatedAt: from.nullCreatedAt(),
+				CursorID:        from.nullID(),
+				Limit:           limit,
+			})
+		}
+		return h.store.ListRepliesDescending(r.Context(), sqlc.ListRepliesDescendingParams{
+			InReplyTo:       inReplyTo,
+			CursorCreatedAt: from.nullCreatedAt(),
+			CursorID:        from.nullID(),
+			Limit:           limit,
+		})
+	}, chirpCursor)
+	if err != nil {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't get thread", err)
+		return
+	}
+
+	var descendants []sqlc.Chirp
+	if len(replies) > 0 {
+		rootIDs := make([]uuid.UUID, 0, len(replies))
+		for _, reply := range replies {
+			rootIDs = append(rootIDs, reply.ID)
+		}
+		descendants, err = h.store.GetChirpDescendants(r.Context(), sqlc.GetChirpDescendantsParams{
+			RootIds:  rootIDs,
+			MaxDepth: maxThreadDepth - 1,
+		})
+		if err != nil {
+			respond.WithError(w, http.StatusInternalServerError, "Couldn't get thread", err)
+			return
+		}
+	}
+
+	records := append([]sqlc.Chirp{chirp}, ancestors...)
+	records = append(records, replies...)
+	records = append(records, descendants...)
+	formatted, err := h.formatChirps(r.Context(), viewer(r), records)
+	if err != nil {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't get thread", err)
+		return
+	}
+	byID := map[uuid.UUID]Chirp{}
+	for _, f := range formatted {
+		if f.DeletedAt != nil {
+			f.Body = ""
+			f.Entities = formatEntities("")
+		}
+		byID[f.ID] = f
+	}
+
+	thread := Thread{
+		Ancestors:  []Chirp{},
+		Chirp:      byID[chirp.ID],
+		Replies:    []ThreadReply{},
+		NextCursor: next,
+		PrevCursor: prev,
+	}
+	for _, ancestor := range ancestors {
+		thread.Ancestors = append(thread.Ancestors, byID[ancestor.ID])
+	}
+
+	children := map[uuid.UUID][]sqlc.Chirp{}
+	for _, descendant := range descendants {
+		children[descendant.InReplyTo.UUID] = append(children[descendant.InReplyTo.UUID], descendant)
+	}
+	for _, reply := range replies {
+		if node, ok := buildReply(reply, children, byID); ok {
+			thread.Replies = append(thread.Replies, node)
+		}
+	}
+
+	respond.WithJSON(w, http.StatusOK, thread)
+}
+
+// buildReply nests the replies to chirp below it. Trashed chirps are dropped
+// unless something below them is still visible, which buildReply reports by
+// returning false.
+func buildReply(chirp sqlc.Chirp, children map[uuid.UUID][]sqlc.Chirp, byID map[uuid.UUID]Chirp) (ThreadReply, bool) {
+	node := ThreadReply{Chirp: byID[chirp.ID], Replies: []ThreadReply{}}
+	for _, child := range children[chirp.ID] {
+		if reply, ok := buildReply(child, children, byID); ok {
+			node.Replies = append(node.Replies, reply)
+		}
+	}
+	return node, !chirp.DeletedAt.Valid || len(node.Replies) > 0
+}
@@ -1,55 +1,213 @@
 package handler
 
 import (
+	"context"
+	"github.com/google/uuid"
 	"github.com/pcauce/chirpy/internal/auth"
 	"github.com/pcauce/chirpy/internal/config"
-	"github.com/pcauce/chirpy/internal/database"
 	"github.com/pcauce/chirpy/internal/sqlc"
 	"github.com/pcauce/chirpy/server/respond"
 	"net/http"
 	"time"
 )
 
-func IssueNewAccessToken(w http.ResponseWriter, r *http.Request) {
+// IssueNewAccessToken trades a refresh token for a new access token and a
+// new refresh token in the same session. Each refresh token works once:
+// presenting one that was already traded in means it was copied, so the
+// session is revoked and the user has to log in again.
+func (h *Handler) IssueNewAccessToken(w http.ResponseWriter, r *http.Request) {
 	token, err := auth.GetBearerToken(r.Header)
 	if err != nil {
 		respond.WithError(w, http.StatusUnauthorized, err.Error(), err)
 		return
 	}
 
-	userID, err := database.Queries().GetUserFromRefresh(r.Context(), token)
+	tokenHash, err := hashRefreshToken(token)
 	if err != nil {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't check refresh token", err)
+		return
+	}
+	refresh, err := h.store.GetRefreshToken(r.Context(), tokenHash)
+	if err != nil || refresh.RevokedAt.Valid || !refresh.ExpiresAt.After(time.Now()) || !refresh.UserID.Valid {
 		respond.WithError(w, http.StatusUnauthorized, "Unauthorized", err)
 		return
 	}
+	session, err := h.store.GetSession(r.Context(), refresh.FamilyID)
+	if err != nil || session.RevokedAt.Valid {
+		respond.WithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+	rotated := int64(0)
+	if !refresh.RotatedAt.Valid {
+		rotated, err = h.store.RotateRefresh(r.Context(), tokenHash)
+		if err != nil {
+			respond.WithError(w, http.StatusInternalServerError, "Couldn't rotate refresh token", err)
+			return
+		}
+	}
+	if rotated == 0 {
+		_, err = h.endSession(r.Context(), session.UserID, session.ID)
+		if err != nil {
+			respond.WithError(w, http.StatusInternalServerError, "Couldn't revoke session", err)
+			return
+		}
+		respond.WithError(w, http.StatusUnauthorized, "Refresh token was already used. Log in again", nil)
+		return
+	}
 
-	newAccess, err := auth.MakeJWT(userID.UUID, config.APIConfig().JWTSecret, config.APIConfig().TokenDuration["access"])
+	// The role is looked up again rather than carried over, so a changed
+	// role takes effect from the next refresh.
+	user, err := h.store.GetUserByID(r.Context(), session.UserID)
+	if err != nil {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't get user", err)
+		return
+	}
+	newAccess, err := auth.MakeJWT(auth.NewPrincipal(user.ID, session.ID, auth.Role(user.Role)), h.keys, tokenValidation(), config.APIConfig().TokenDuration["access"])
 	if err != nil {
 		respond.WithError(w, http.StatusInternalServerError, "Couldn't create JWT Access Token", err)
+		return
+	}
+	newRefresh, err := h.issueRefreshToken(r.Context(), session.UserID, session.ID)
+	if err != nil {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't create refresh token", err)
+		return
+	}
+	err = h.store.RenewSession(r.Context(), sqlc.RenewSessionParams{
+		ID:        session.ID,
+		IP:        clientIP(r),
+		ExpiresAt: time.Now().Add(config.APIConfig().TokenDuration["refresh"]),
+	})
+	if err != nil {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't update session", err)
+		return
 	}
 
 	response := struct {
-		Token string `json:"token"`
+		Token   string `json:"token"`
+		Refresh string `json:"refresh_token"`
 	}{
-		Token: newAccess,
+		Token:   newAccess,
+		Refresh: newRefresh,
 	}
 	respond.WithJSON(w, http.StatusOK, response)
 }
 
-func RevokeAccessToken(w http.ResponseWriter, r *http.Request) {
+// RevokeAccessToken revokes the token on the request. An access token is
+// put on the denylist until it expires, leaving its session logged in. A
+// refresh token logs out the session it belongs to, which also stops that
+// session's access tokens from working.
+func (h *Handler) RevokeAccessToken(w http.ResponseWriter, r *http.Request) {
 	token, err := auth.GetBearerToken(r.Header)
 	if err != nil {
 		w.WriteHeader(http.StatusUnauthorized)
 		return
 	}
 
-	err = database.Queries().RevokeRefresh(r.Context(), sqlc.RevokeRefreshParams{
-		Token:     token,
+	access, err := auth.ParseJWT(token, h.keys, tokenValidation())
+	if err == nil {
+		err = h.store.RevokeAccessToken(r.Context(), sqlc.RevokeAccessTokenParams{
+			JTI:       access.ID,
+			UserID:    access.UserID,
+			ExpiresAt: access.ExpiresAt,
+		})
+		if err != nil {
+			respond.WithError(w, http.StatusInternalServerError, "Couldn't revoke token", err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	tokenHash, err := hashRefreshToken(token)
+	if err != nil {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't revoke token", err)
+		return
+	}
+	err = h.store.RevokeRefresh(r.Context(), sqlc.RevokeRefreshParams{
+		TokenHash: tokenHash,
 		UpdatedAt: time.Now(),
 	})
 	if err != nil {
 		respond.WithError(w, http.StatusInternalServerError, "Token doesn't exist", err)
+		return
+	}
+	refresh, err := h.store.GetRefreshToken(r.Context(), tokenHash)
+	if err == nil && refresh.UserID.Valid {
+		_, err = h.endSession(r.Context(), refresh.UserID.UUID, refresh.FamilyID)
+		if err != nil {
+			respond.WithError(w, http.StatusInternalServerError, "Couldn't revoke session", err)
+			return
+		}
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// GetJWKS publishes the public keys access tokens are signed with, so other
+// services can verify them without being able to issue them.
+func (h *Handler) GetJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	respond.WithJSON(w, http.StatusOK, struct {
+		Keys []auth.JWK `json:"keys"`
+	}{
+		Keys: h.keys.JWKS(),
+	})
+}
+
+// issueTokens starts a session for user from r and returns an access token
+// with the scopes of their role and a refresh token for it.
+func (h *Handler) issueTokens(r *http.Request, user sqlc.User) (string, string, error) {
+	session, err := h.startSession(r, user.ID)
+	if err != nil {
+		return "", "", err
+	}
+	access, err := auth.MakeJWT(auth.NewPrincipal(user.ID, session.ID, auth.Role(user.Role)), h.keys, tokenValidation(), config.APIConfig().TokenDuration["access"])
+	if err != nil {
+		return "", "", err
+	}
+	refresh, err := h.issueRefreshToken(r.Context(), user.ID, session.ID)
+	if err != nil {
+		return "", "", err
+	}
+	return access, refresh, nil
+}
+
+// issueRefreshToken creates a refresh token for userID in a session and
+// stores its hash. The session ID is the token's family ID.
+func (h *Handler) issueRefreshToken(ctx context.Context, userID, sessionID uuid.UUID) (string, error) {
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	tokenHash, err := hashRefreshToken(token)
+	if err != nil {
+		return "", err
+	}
+	err = h.store.StoreRefresh(ctx, sqlc.StoreRefreshParams{
+		TokenHash: tokenHash,
+		UserID:    uuid.NullUUID{UUID: userID, Valid: true},
+		ExpiresAt: time.Now().Add(config.APIConfig().TokenDuration["refresh"]),
+		FamilyID:  sessionID,
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// tokenValidation is the configured issuer, audiences, algorithms and
+// leeway of access tokens.
+func tokenValidation() auth.Validation {
+	return auth.Validation{
+		Issuer:     config.APIConfig().JWTIssuer,
+		Audiences:  config.APIConfig().JWTAudiences,
+		Algorithms: config.APIConfig().JWTAlgorithms,
+		Leeway:     config.APIConfig().JWTLeeway,
+	}
+}
+
+// hashRefreshToken is the only form of a refresh token that reaches the
+// store.
+func hashRefreshToken(token string) (string, error) {
+	return auth.HashRefreshToken(token, config.APIConfig().RefreshTokenKey)
+}
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/pcauce/chirpy/internal/config"
+	"github.com/pcauce/chirpy/internal/database"
+	"github.com/pcauce/chirpy/internal/sqlc"
+	"github.com/pcauce/chirpy/server/respond"
+	"net/http"
+	"time"
+)
+
+// restoreDeadline is the earliest deleted_at a chirp can have and still be
+// restored from the trash.
+func restoreDeadline() sql.NullTime {
+	return sql.NullTime{
+		Time:  time.Now().UTC().Add(-config.APIConfig().TrashRetention),
+		Valid: true,
+	}
+}
+
+func (h *Handler) GetTrash(w http.ResponseWriter, r *http.Request) {
+	userID, ok := caller(w, r)
+	if !ok {
+		return
+	}
+
+	trashed, err := h.store.ListTrashedChirps(r.Context(), sqlc.ListTrashedChirpsParams{
+		UserID:    uuid.NullUUID{UUID: userID, Valid: true},
+		DeletedAt: restoreDeadline(),
+	})
+	if err != nil {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't get trash", err)
+		return
+	}
+
+	formattedChirps, err := h.formatChirps(r.Context(), uuid.NullUUID{UUID: userID, Valid: true}, trashed)
+	if err != nil {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't get trash", err)
+		return
+	}
+	respond.WithJSON(w, http.StatusOK, formattedChirps)
+}
+
+func (h *Handler) RestoreChirp(w http.ResponseWriter, r *http.Request) {
+	userID, ok := caller(w, r)
+	if !ok {
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respond.WithError(w, http.StatusBadRequest, "Couldn't parse chirp ID", err)
+		return
+	}
+
+	chirp, err := h.store.RestoreChirp(r.Context(), sqlc.RestoreChirpParams{
+		ID:        chirpID,
+		UserID:    uuid.NullUUID{UUID: userID, Valid: true},
+		DeletedAt: restoreDeadline(),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respond.WithError(w, http.StatusNotFound, "Chirp isn't in your trash", err)
+		return
+	}
+	if database.IsUniqueViolation(err) {
+		respond.WithError(w, http.StatusConflict, "You've since posted a chirp that says this", err)
+		return
+	}
+	if err != nil {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't restore chirp", err)
+		return
+	}
+
+	formatted, err := h.formatOneChirp(r.Context(), chirp.UserID, chirp)
+	if err != nil {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't restore chirp", err)
+		return
+	}
+	respond.WithJSON(w, http.StatusOK, formatted)
+}
@@ -1,28 +1,64 @@
 package handler
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"github.com/google/uuid"
 	"github.com/pcauce/chirpy/internal/auth"
 	"github.com/pcauce/chirpy/internal/config"
-	"github.com/pcauce/chirpy/internal/database"
+	"github.com/pcauce/chirpy/internal/content"
 	"github.com/pcauce/chirpy/internal/sqlc"
 	"github.com/pcauce/chirpy/server/respond"
 	"net/http"
+	"strings"
 	"time"
 )
 
 type User struct {
-	ID          uuid.UUID `json:"id"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	Email       string    `json:"email"`
-	Token       string    `json:"token"`
-	Refresh     string    `json:"refresh_token"`
-	IsChirpyRed bool      `json:"is_chirpy_red"`
+	ID               uuid.UUID  `json:"id"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+	Email            string     `json:"email"`
+	Token            string     `json:"token"`
+	Refresh          string     `json:"refresh_token"`
+	IsChirpyRed      bool       `json:"is_chirpy_red"`
+	ChirpLengthLimit int        `json:"chirp_length_limit"`
+	Handle           *string    `json:"handle"`
+	DisplayName      string     `json:"display_name"`
+	Bio              string     `json:"bio"`
+	DeleteAfter      *time.Time `json:"delete_after"`
+	Role             string     `json:"role"`
 }
 
-func CreateUser(w http.ResponseWriter, r *http.Request) {
+// formatUser converts a user row for the API, without any tokens.
+func formatUser(user sqlc.User) User {
+	return User{
+		ID:               user.ID,
+		CreatedAt:        user.CreatedAt,
+		UpdatedAt:        user.UpdatedAt,
+		Email:            user.Email,
+		IsChirpyRed:      user.IsChirpyRed,
+		ChirpLengthLimit: content.LengthLimit(user.IsChirpyRed),
+		Handle:           nullableHandle(user.Handle),
+		DisplayName:      user.DisplayName,
+		Bio:              user.Bio,
+		DeleteAfter:      deleteAfter(user),
+		Role:             user.Role,
+	}
+}
+
+// deleteAfter is when a user who asked to delete their account will be
+// purged, or nil if they haven't asked.
+func deleteAfter(user sqlc.User) *time.Time {
+	if !user.DeletionRequestedAt.Valid {
+		return nil
+	}
+	after := user.DeletionRequestedAt.Time.Add(config.APIConfig().DeletionGrace)
+	return &after
+}
+
+func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	userData := map[string]string{}
 	decoder := json.NewDecoder(r.Body)
 	err := decoder.Decode(&userData)
@@ -37,7 +73,7 @@ func CreateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	createdUser, err := database.Queries().CreateUser(r.Context(), sqlc.CreateUserParams{
+	createdUser, err := h.store.CreateUser(r.Context(), sqlc.CreateUserParams{
 		Email:          userData["email"],
 		HashedPassword: hashedPassword,
 	})
@@ -46,16 +82,10 @@ func CreateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	respond.WithJSON(w, http.StatusCreated, User{
-		ID:          createdUser.ID,
-		CreatedAt:   createdUser.CreatedAt,
-		UpdatedAt:   createdUser.UpdatedAt,
-		Email:       createdUser.Email,
-		IsChirpyRed: createdUser.IsChirpyRed,
-	})
+	respond.WithJSON(w, http.StatusCreated, formatUser(createdUser))
 }
 
-func LoginUser(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) LoginUser(w http.ResponseWriter, r *http.Request) {
 	loginData := struct {
 		Email    string `json:"email"`
 		Password string `json:"password"`
@@ -67,95 +97,193 @@ func LoginUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := database.Queries().GetUserByEmail(r.Context(), loginData.Email)
+	user, err := h.store.GetUserByEmail(r.Context(), loginData.Email)
 	if err != nil || auth.CheckPasswordHash(user.HashedPassword, loginData.Password) != nil {
 		respond.WithError(w, http.StatusUnauthorized, "Incorrect email or password", err)
 		return
 	}
-
-	newJwtToken, err := auth.MakeJWT(user.ID, config.APIConfig().JWTSecret, time.Hour)
-	if err != nil {
-		respond.WithError(w, http.StatusInternalServerError, "Couldn't create JWT", err)
+	if user.DeletionRequestedAt.Valid {
+		err = h.store.CancelUserDeletion(r.Context(), user.ID)
+		if err != nil {
+			respond.WithError(w, http.StatusInternalServerError, "Couldn't cancel account deletion", err)
+			return
+		}
+		user.DeletionRequestedAt = sql.NullTime{}
 	}
 
-	refreshToken, err := auth.MakeRefreshToken()
-	if err != nil {
-		respond.WithError(w, http.StatusInternalServerError, "Couldn't create refresh token", err)
-	}
-	err = database.Queries().StoreRefresh(r.Context(), sqlc.StoreRefreshParams{
-		Token:     refreshToken,
-		UserID:    uuid.NullUUID{UUID: user.ID, Valid: true},
-		ExpiresAt: time.Now().Add(config.APIConfig().TokenDuration["refresh"]),
-	})
+	access, refresh, err := h.issueTokens(r, user)
 	if err != nil {
-		respond.WithError(w, http.StatusInternalServerError, "Couldn't store refresh token in sqlc", err)
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't log in", err)
+		return
 	}
 
-	respond.WithJSON(w, http.StatusOK, User{
-		ID:          user.ID,
-		CreatedAt:   user.CreatedAt,
-		UpdatedAt:   user.UpdatedAt,
-		Email:       user.Email,
-		Token:       newJwtToken,
-		Refresh:     refreshToken,
-		IsChirpyRed: user.IsChirpyRed,
-	})
+	loggedIn := formatUser(user)
+	loggedIn.Token = access
+	loggedIn.Refresh = refresh
+	respond.WithJSON(w, http.StatusOK, loggedIn)
 }
 
-func ChangeUserCredentials(w http.ResponseWriter, r *http.Request) {
-	token, err := auth.GetBearerToken(r.Header)
+// ChangeUserCredentials updates whichever of the caller's email, password,
+// handle, display name and bio are in the request, checking all of them
+// before changing any. The password is changed last, so a request that
+// fails part way doesn't leave the caller logged out. Changing it logs out
+// every session, which stops all outstanding access tokens from working,
+// and responds with tokens for a new one.
+func (h *Handler) ChangeUserCredentials(w http.ResponseWriter, r *http.Request) {
+	userID, ok := caller(w, r)
+	if !ok {
+		return
+	}
+
+	var params struct {
+		Email       *string `json:"email"`
+		Password    *string `json:"password"`
+		Handle      *string `json:"handle"`
+		DisplayName *string `json:"display_name"`
+		Bio         *string `json:"bio"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&params)
 	if err != nil {
-		respond.WithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		respond.WithError(w, http.StatusBadRequest, "Couldn't decode JSON", err)
+		return
+	}
+	if params.Email == nil && params.Password == nil && params.Handle == nil && params.DisplayName == nil && params.Bio == nil {
+		respond.WithError(w, http.StatusBadRequest, "Nothing to update", nil)
 		return
 	}
-	userID, err := auth.ValidateJWT(token, config.APIConfig().JWTSecret)
+
+	user, err := h.store.GetUserByID(r.Context(), userID)
 	if err != nil {
-		respond.WithError(w, http.StatusUnauthorized, "Unauthorized. JWT not valid", err)
+		respond.WithError(w, http.StatusNotFound, "Couldn't get user", err)
+		return
+	}
+	profile := sqlc.UpdateUserProfileParams{
+		ID:          user.ID,
+		Handle:      user.Handle,
+		DisplayName: user.DisplayName,
+		Bio:         user.Bio,
+	}
+	if params.Handle != nil {
+		profile.Handle = sql.NullString{String: *params.Handle, Valid: *params.Handle != ""}
+	}
+	if params.DisplayName != nil {
+		profile.DisplayName = strings.TrimSpace(*params.DisplayName)
+	}
+	if params.Bio != nil {
+		profile.Bio = strings.TrimSpace(*params.Bio)
+	}
+	if !h.checkProfile(w, r, user, profile) {
 		return
 	}
 
-	credentials := map[string]string{}
-	decoder := json.NewDecoder(r.Body)
-	err = decoder.Decode(&credentials)
+	if params.Email != nil && *params.Email != user.Email {
+		_, err = h.store.GetUserByEmail(r.Context(), *params.Email)
+		if err == nil {
+			respond.WithError(w, http.StatusConflict, "That email is taken", nil)
+			return
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			respond.WithError(w, http.StatusInternalServerError, "Couldn't check email", err)
+			return
+		}
+	}
+	var hashPassword string
+	if params.Password != nil {
+		hashPassword, err = auth.HashPassword(*params.Password)
+		if err != nil {
+			respond.WithError(w, http.StatusInternalServerError, "Couldn't hash password", err)
+			return
+		}
+	}
+
+	if params.Email != nil {
+		_, err = h.store.UpdateUserEmail(r.Context(), sqlc.UpdateUserEmailParams{
+			ID:    userID,
+			Email: *params.Email,
+		})
+		if err != nil {
+			respond.WithError(w, http.StatusInternalServerError, "Couldn't update user email", err)
+			return
+		}
+	}
+
+	if params.Handle != nil || params.DisplayName != nil || params.Bio != nil {
+		err = h.updateProfile(r.Context(), user, profile)
+		if err != nil {
+			respond.WithError(w, http.StatusInternalServerError, "Couldn't update profile", err)
+			return
+		}
+	}
+
+	if params.Password != nil {
+		_, err = h.store.UpdateUserPassword(r.Context(), sqlc.UpdateUserPasswordParams{
+			ID:             userID,
+			HashedPassword: hashPassword,
+		})
+		if err != nil {
+			respond.WithError(w, http.StatusInternalServerError, "Couldn't update user password", err)
+			return
+		}
+		err = h.revokeUserSessions(r.Context(), userID)
+		if err != nil {
+			respond.WithError(w, http.StatusInternalServerError, "Couldn't revoke sessions", err)
+			return
+		}
+	}
+
+	user, err = h.store.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't get user", err)
+		return
+	}
+	updated := formatUser(user)
+	if params.Password != nil {
+		updated.Token, updated.Refresh, err = h.issueTokens(r, user)
+		if err != nil {
+			respond.WithError(w, http.StatusInternalServerError, "Couldn't log in again", err)
+			return
+		}
+	}
+	respond.WithJSON(w, http.StatusOK, updated)
+}
 
-	rawPassword, ok := credentials["password"]
+// DeleteUser schedules the caller's account for deletion, which the purge
+// job carries out once DeletionGrace has passed. The password is asked for
+// again so a stolen access token isn't enough. Every session is logged out,
+// and logging in again before the grace period ends cancels the deletion.
+func (h *Handler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	userID, ok := caller(w, r)
 	if !ok {
-		respond.WithError(w, http.StatusBadRequest, "Password missing", err)
 		return
 	}
-	hashPassword, err := auth.HashPassword(rawPassword)
+
+	var params struct {
+		Password string `json:"password"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&params)
 	if err != nil {
-		respond.WithError(w, http.StatusInternalServerError, "Couldn't hash password", err)
+		respond.WithError(w, http.StatusBadRequest, "Couldn't decode JSON", err)
 		return
 	}
-	_, err = database.Queries().UpdateUserPassword(r.Context(), sqlc.UpdateUserPasswordParams{
-		ID:             userID,
-		HashedPassword: hashPassword,
-	})
+	user, err := h.store.GetUserByID(r.Context(), userID)
 	if err != nil {
-		respond.WithError(w, http.StatusInternalServerError, "Couldn't update user password", err)
+		respond.WithError(w, http.StatusNotFound, "Couldn't get user", err)
+		return
+	}
+	if auth.CheckPasswordHash(user.HashedPassword, params.Password) != nil {
+		respond.WithError(w, http.StatusUnauthorized, "Incorrect password", nil)
 		return
 	}
 
-	email, ok := credentials["email"]
-	if !ok {
-		respond.WithError(w, http.StatusBadRequest, "Email missing", err)
+	user, err = h.store.RequestUserDeletion(r.Context(), userID)
+	if err != nil {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't delete account", err)
 		return
 	}
-	user, err := database.Queries().UpdateUserEmail(r.Context(), sqlc.UpdateUserEmailParams{
-		ID:    userID,
-		Email: email,
-	})
+	err = h.revokeUserSessions(r.Context(), userID)
 	if err != nil {
-		respond.WithError(w, http.StatusInternalServerError, "Couldn't update user password", err)
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't revoke sessions", err)
 		return
 	}
-
-	respond.WithJSON(w, http.StatusOK, User{
-		ID:          user.ID,
-		CreatedAt:   user.CreatedAt,
-		UpdatedAt:   user.UpdatedAt,
-		Email:       user.Email,
-		IsChirpyRed: user.IsChirpyRed,
-	})
+	respond.WithJSON(w, http.StatusAccepted, formatUser(user))
 }
@@ -1,18 +1,25 @@
 package handler
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/pcauce/chirpy/internal/content"
 	"github.com/pcauce/chirpy/server/respond"
 	"net/http"
-	"strings"
 )
 
-func ValidateChirp(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) ValidateChirp(w http.ResponseWriter, r *http.Request) {
 	type chirpData struct {
 		Body string `json:"body"`
 	}
 	type cleanedResponse struct {
-		Body string `json:"cleaned_body"`
+		Body     string   `json:"cleaned_body"`
+		Links    []string `json:"links"`
+		Mentions []string `json:"mentions"`
+		Hashtags []string `json:"hashtags"`
 	}
 
 	decoder := json.NewDecoder(r.Body)
@@ -23,29 +30,60 @@ func ValidateChirp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	const maxChirpLength = 140
-	if len(chirp.Body) > maxChirpLength {
-		respond.WithError(w, http.StatusBadRequest, "Chirp is too long", err)
+	cleaned, ok := h.cleanChirp(w, r, viewer(r), chirp.Body)
+	if !ok {
 		return
 	}
 
-	badWords := map[string]struct{}{
-		"kerfuffle": {}, "sharbert": {}, "fornax": {},
-	}
-	cleanRes := cleanedResponse{Body: clean(chirp.Body, badWords)}
-
-	respond.WithJSON(w, http.StatusOK, cleanRes)
-	return
+	respond.WithJSON(w, http.StatusOK, cleanedResponse{
+		Body:     cleaned.Body,
+		Links:    append([]string{}, cleaned.Links...),
+		Mentions: append([]string{}, cleaned.Mentions...),
+		Hashtags: append([]string{}, cleaned.Hashtags...),
+	})
 }
 
-func clean(message string, badWords map[string]struct{}) string {
-	words := strings.Split(message, " ")
+// cleanChirp runs body through the content pipeline for author's tier, or
+// the standard one if there is no author. If it breaks any rule, cleanChirp
+// has already responded with every violation and returns false.
+func (h *Handler) cleanChirp(w http.ResponseWriter, r *http.Request, author uuid.NullUUID, body string) (content.Chirp, bool) {
+	pipeline, err := h.pipelineFor(r.Context(), author)
+	if err != nil {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't check chirp", err)
+		return content.Chirp{}, false
+	}
 
-	for i, word := range words {
-		if _, forbidden := badWords[strings.ToLower(word)]; forbidden {
-			words[i] = "****"
-		}
+	cleaned, err := pipeline.Run(body)
+	var invalid *content.ValidationError
+	if errors.As(err, &invalid) {
+		respond.WithJSON(w, http.StatusBadRequest, struct {
+			Error      string              `json:"error"`
+			Violations []content.Violation `json:"violations"`
+		}{
+			Error:      invalid.Error(),
+			Violations: invalid.Violations,
+		})
+		return content.Chirp{}, false
+	}
+	if err != nil {
+		respond.WithError(w, http.StatusInternalServerError, "Couldn't check chirp", err)
+		return content.Chirp{}, false
 	}
+	return cleaned, true
+}
 
-	return strings.Join(words, " ")
+// pipelineFor returns the pipeline that checks chirps by author, which
+// depends on whether they have Chirpy Red.
+func (h *Handler) pipelineFor(ctx context.Context, author uuid.NullUUID) (*content.Pipeline, error) {
+	if !author.Valid {
+		return h.content, nil
+	}
+	user, err := h.store.GetUserByID(ctx, author.UUID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if user.IsChirpyRed {
+		return h.redContent, nil
+	}
+	return h.content, nil
 }
@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// cursor is a position in a list ordered by (created_at, id), or by
+// (rank, created_at, id) for search results. Clients only ever see it as an
+// opaque string.
+type cursor struct {
+	Rank      float32   `json:"r,omitempty"`
+	CreatedAt time.Time `json:"t"`
+	ID        uuid.UUID `json:"id"`
+	Backward  bool      `json:"b,omitempty"`
+}
+
+func (c cursor) String() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func parseCursor(s string) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.New("malformed cursor")
+	}
+	c := cursor{}
+	err = json.Unmarshal(data, &c)
+	if err != nil || c.ID == uuid.Nil {
+		return nil, errors.New("malformed cursor")
+	}
+	return &c, nil
+}
+
+func (c *cursor) nullCreatedAt() sql.NullTime {
+	if c == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: c.CreatedAt, Valid: true}
+}
+
+func (c *cursor) nullRank() sql.NullFloat64 {
+	if c == nil {
+		return sql.NullFloat64{}
+	}
+	return sql.NullFloat64{Float64: float64(c.Rank), Valid: true}
+}
+
+func (c *cursor) nullID() uuid.NullUUID {
+	if c == nil {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: c.ID, Valid: true}
+}
+
+// page is a parsed set of ?limit=, ?cursor= and ?sort= query parameters.
+// Sorting by relevance orders by descending search rank.
+type page struct {
+	Limit       int32
+	Cursor      *cursor
+	Descending  bool
+	ByRelevance bool
+}
+
+func parsePage(query url.Values) (page, error) {
+	p := page{Limit: defaultPageLimit}
+
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxPageLimit {
+			return page{}, errors.New("limit must be between 1 and " + strconv.Itoa(maxPageLimit))
+		}
+		p.Limit = int32(n)
+	}
+
+	switch query.Get("sort") {
+	case "", "asc":
+	case "desc":
+		p.Descending = true
+	case "relevance":
+		p.Descending, p.ByRelevance = true, true
+	default:
+		return page{}, errors.New("sort must be 'asc', 'desc' or 'relevance'")
+	}
+
+	if c := query.Get("cursor"); c != "" {
+		parsed, err := parseCursor(c)
+		if err != nil {
+			return page{}, err
+		}
+		p.Cursor = parsed
+	}
+
+	return p, nil
+}
+
+// keysetQuery returns up to limit rows strictly after from in ascending
+// (created_at, id) order, or strictly before it in descending order. A nil
+// from starts at the beginning of the list.
+type keysetQuery[T any] func(ascending bool, from *cursor, limit int32) ([]T, error)
+
+// fetchPage runs query for p and returns the rows in the requested sort order
+// along with the cursors for the neighbouring pages, which are empty when
+// there is nothing more in that direction.
+func fetchPage[T any](p page, query keysetQuery[T], key func(T) cursor) (rows []T, next, prev string, err error) {
+	backward := p.Cursor != nil && p.Cursor.Backward
+	ascending := p.Descending == backward
+
+	rows, err = query(ascending, p.Cursor, p.Limit+1)
+	if err != nil {
+		return nil, "", "", err
+	}
+	more := len(rows) > int(p.Limit)
+	if more {
+		rows = rows[:p.Limit]
+	}
+	if backward {
+		slices.Reverse(rows)
+	}
+
+	hasNext, hasPrev := more, p.Cursor != nil
+	if backward {
+		hasNext, hasPrev = true, more
+	}
+
+	first, last := p.Cursor, p.Cursor
+	if len(rows) > 0 {
+		firstKey, lastKey := key(rows[0]), key(rows[len(rows)-1])
+		first, last = &firstKey, &lastKey
+	}
+	if hasNext && last != nil {
+		nextCursor := *last
+		nextCursor.Backward = false
+		next = nextCursor.String()
+	}
+	if hasPrev && first != nil {
+		prevCursor := *first
+		prevCursor.Backward = true
+		prev = prevCursor.String()
+	}
+	return rows, next, prev, nil
+}
@@ -1,22 +1,51 @@
 -- name: StoreRefresh :exec
-INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at)
+INSERT INTO refresh_tokens (token_hash, created_at, updated_at, user_id, expires_at, family_id)
 VALUES (
         $1,
         now(),
         now(),
         $2,
-        $3
+        $3,
+        $4
        );
 
 -- name: RevokeRefresh :exec
 UPDATE refresh_tokens
 SET updated_at = $2, revoked_at = $2
-WHERE token = $1;
+WHERE token_hash = $1;
 
--- name: GetUserFromRefresh :one
--- name: GetUserFromRefresh :one
-SELECT user_id
+-- name: RevokeUserRefreshTokens :exec
+UPDATE refresh_tokens
+SET updated_at = now(), revoked_at = now()
+WHERE user_id = $1 AND revoked_at IS NULL;
+
+-- name: GetRefreshToken :one
+SELECT *
 FROM refresh_tokens
-WHERE token = $1
-  AND revoked_at IS NULL
-  AND expires_at > NOW();
\ No newline at end of file
+WHERE token_hash = $1;
+
+-- name: RotateRefresh :execrows
+UPDATE refresh_tokens
+SET updated_at = now(), rotated_at = now()
+WHERE token_hash = $1 AND rotated_at IS NULL AND revoked_at IS NULL;
+
+-- name: RevokeRefreshFamily :exec
+UPDATE refresh_tokens
+SET updated_at = now(), revoked_at = now()
+WHERE family_id = $1 AND revoked_at IS NULL;
+
+-- name: RevokeAccessToken :exec
+INSERT INTO revoked_access_tokens (jti, user_id, expires_at, revoked_at)
+VALUES ($1, $2, $3, now())
+ON CONFLICT DO NOTHING;
+
+-- name: IsAccessTokenRevoked :one
+SELECT EXISTS (
+    SELECT 1
+    FROM revoked_access_tokens
+    WHERE jti = $1
+) AS revoked;
+
+-- name: PurgeRevokedAccessTokens :execrows
+DELETE FROM revoked_access_tokens
+WHERE expires_at <= $1;
@@ -1,29 +1,78 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, in_reply_to, rechirp_of, quote_of)
 VALUES (
            gen_random_uuid(),
            now(),
            now(),
            $1,
-           $2
+           $2,
+           $3,
+           $4,
+           $5
        )
 RETURNING *;
 
--- name: GetAllChirps :many
+-- name: ListChirpsAscending :many
 SELECT *
 FROM chirps
-ORDER BY created_at;
+WHERE deleted_at IS NULL
+  AND (sqlc.narg('author_id')::uuid IS NULL OR user_id = sqlc.narg('author_id')::uuid)
+  AND (sqlc.narg('query')::text IS NULL
+    OR to_tsvector('english', body) @@ to_tsquery('english', sqlc.narg('query')::text))
+  AND (sqlc.narg('created_after')::timestamp IS NULL OR created_at >= sqlc.narg('created_after')::timestamp)
+  AND (sqlc.narg('created_before')::timestamp IS NULL OR created_at < sqlc.narg('created_before')::timestamp)
+  AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, id) > (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY created_at, id
+LIMIT sqlc.arg('limit');
 
--- name: GetChirpByID :one
+-- name: ListChirpsDescending :many
 SELECT *
 FROM chirps
-WHERE id = $1;
+WHERE deleted_at IS NULL
+  AND (sqlc.narg('author_id')::uuid IS NULL OR user_id = sqlc.narg('author_id')::uuid)
+  AND (sqlc.narg('query')::text IS NULL
+    OR to_tsvector('english', body) @@ to_tsquery('english', sqlc.narg('query')::text))
+  AND (sqlc.narg('created_after')::timestamp IS NULL OR created_at >= sqlc.narg('created_after')::timestamp)
+  AND (sqlc.narg('created_before')::timestamp IS NULL OR created_at < sqlc.narg('created_before')::timestamp)
+  AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: SearchChirpsAscending :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, rechirp_of, quote_of, rank
+FROM (
+    SELECT *, ts_rank(to_tsvector('english', body), to_tsquery('english', sqlc.arg('query'))) AS rank
+    FROM chirps
+    WHERE deleted_at IS NULL
+      AND to_tsvector('english', body) @@ to_tsquery('english', sqlc.arg('query'))
+      AND (sqlc.narg('author_id')::uuid IS NULL OR user_id = sqlc.narg('author_id')::uuid)
+      AND (sqlc.narg('created_after')::timestamp IS NULL OR created_at >= sqlc.narg('created_after')::timestamp)
+      AND (sqlc.narg('created_before')::timestamp IS NULL OR created_at < sqlc.narg('created_before')::timestamp)
+) AS ranked
+WHERE (sqlc.narg('cursor_rank')::real IS NULL
+    OR (rank, created_at, id) > (sqlc.narg('cursor_rank')::real, sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY rank, created_at, id
+LIMIT sqlc.arg('limit');
+
+-- name: SearchChirpsDescending :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, rechirp_of, quote_of, rank
+FROM (
+    SELECT *, ts_rank(to_tsvector('english', body), to_tsquery('english', sqlc.arg('query'))) AS rank
+    FROM chirps
+    WHERE deleted_at IS NULL
+      AND to_tsvector('english', body) @@ to_tsquery('english', sqlc.arg('query'))
+      AND (sqlc.narg('author_id')::uuid IS NULL OR user_id = sqlc.narg('author_id')::uuid)
+      AND (sqlc.narg('created_after')::timestamp IS NULL OR created_at >= sqlc.narg('created_after')::timestamp)
+      AND (sqlc.narg('created_before')::timestamp IS NULL OR created_at < sqlc.narg('created_before')::timestamp)
+) AS ranked
+WHERE (sqlc.narg('cursor_rank')::real IS NULL
+    OR (rank, created_at, id) < (sqlc.narg('cursor_rank')::real, sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY rank DESC, created_at DESC, id DESC
+LIMIT sqlc.arg('limit');
 
--- name: GetChirpsByAuthor :many
+-- name: GetChirpByID :one
 SELECT *
 FROM chirps
-WHERE user_id = $1;
-
--- name: DeleteChirp :exec
-DELETE FROM chirps
-WHERE id = $1 AND user_id = $2;
\ No newline at end of file
+WHERE id = $1 AND deleted_at IS NULL;
\ No newline at end of file
@@ -0,0 +1,76 @@
+-- name: CreateExport :one
+INSERT INTO exports (id, user_id, status, created_at)
+VALUES (
+           gen_random_uuid(),
+           $1,
+           'pending',
+           now()
+       )
+RETURNING *;
+
+-- name: ClaimExport :one
+UPDATE exports
+SET claimed_at = now()
+WHERE id = (
+    SELECT id
+    FROM exports
+    WHERE status = 'pending' AND (claimed_at IS NULL OR claimed_at <= $1)
+    ORDER BY created_at, id
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: CompleteExport :exec
+UPDATE exports
+SET status = $2, archive = $3, completed_at = now()
+WHERE id = $1;
+
+-- name: GetExport :one
+SELECT *
+FROM exports
+WHERE id = $1 AND user_id = $2;
+
+-- name: GetLatestExport :one
+SELECT *
+FROM exports
+WHERE user_id = $1
+ORDER BY created_at DESC
+LIMIT 1;
+
+-- name: PurgeExports :execrows
+DELETE FROM exports
+WHERE created_at <= $1;
+
+-- name: ListUserChirps :many
+SELECT *
+FROM chirps
+WHERE user_id = $1
+ORDER BY created_at, id;
+
+-- name: ImportChirp :one
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, in_reply_to, quote_of)
+VALUES (
+           gen_random_uuid(),
+           $1,
+           $2,
+           $3,
+           $4,
+           $5,
+           $6
+       )
+ON CONFLICT (user_id, body) WHERE rechirp_of IS NULL AND deleted_at IS NULL DO NOTHING
+RETURNING *;
+
+-- name: ImportRechirp :one
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, rechirp_of)
+VALUES (
+           gen_random_uuid(),
+           $1,
+           $2,
+           '',
+           $3,
+           $4
+       )
+ON CONFLICT (user_id, rechirp_of) DO NOTHING
+RETURNING *;
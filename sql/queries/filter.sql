@@ -0,0 +1,35 @@
+-- name: ListFilterTerms :many
+SELECT *
+FROM filter_terms
+ORDER BY term;
+
+-- name: CreateFilterTerm :one
+INSERT INTO filter_terms (id, term, action, created_at, updated_at)
+VALUES (gen_random_uuid(), $1, $2, now(), now())
+RETURNING *;
+
+-- name: UpdateFilterTerm :one
+UPDATE filter_terms
+SET action = $2, updated_at = now()
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteFilterTerm :execrows
+DELETE FROM filter_terms
+WHERE id = $1;
+
+-- name: FlagChirp :exec
+INSERT INTO chirp_flags (chirp_id, term, created_at)
+VALUES ($1, $2, now())
+ON CONFLICT DO NOTHING;
+
+-- name: ClearChirpFlags :execrows
+DELETE FROM chirp_flags
+WHERE chirp_id = $1;
+
+-- name: ListFlaggedChirps :many
+SELECT sqlc.embed(chirps), chirp_flags.term, chirp_flags.created_at AS flagged_at
+FROM chirp_flags
+JOIN chirps ON chirps.id = chirp_flags.chirp_id
+WHERE chirps.deleted_at IS NULL
+ORDER BY chirp_flags.created_at, chirps.id, chirp_flags.term;
@@ -0,0 +1,66 @@
+-- name: FollowUser :exec
+INSERT INTO follows (follower_id, followee_id, created_at)
+VALUES ($1, $2, now())
+ON CONFLICT DO NOTHING;
+
+-- name: UnfollowUser :exec
+DELETE FROM follows
+WHERE follower_id = $1 AND followee_id = $2;
+
+-- name: ListFollowersAscending :many
+SELECT *
+FROM follows
+WHERE followee_id = sqlc.arg('followee_id')
+  AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, follower_id) > (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY created_at, follower_id
+LIMIT sqlc.arg('limit');
+
+-- name: ListFollowersDescending :many
+SELECT *
+FROM follows
+WHERE followee_id = sqlc.arg('followee_id')
+  AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, follower_id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY created_at DESC, follower_id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: ListFollowingAscending :many
+SELECT *
+FROM follows
+WHERE follower_id = sqlc.arg('follower_id')
+  AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, followee_id) > (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY created_at, followee_id
+LIMIT sqlc.arg('limit');
+
+-- name: ListFollowingDescending :many
+SELECT *
+FROM follows
+WHERE follower_id = sqlc.arg('follower_id')
+  AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, followee_id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY created_at DESC, followee_id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: ListTimelineAscending :many
+SELECT *
+FROM chirps
+WHERE deleted_at IS NULL
+  AND (user_id = sqlc.arg('viewer_id')::uuid
+    OR user_id IN (SELECT followee_id FROM follows WHERE follower_id = sqlc.arg('viewer_id')::uuid))
+  AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, id) > (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY created_at, id
+LIMIT sqlc.arg('limit');
+
+-- name: ListTimelineDescending :many
+SELECT *
+FROM chirps
+WHERE deleted_at IS NULL
+  AND (user_id = sqlc.arg('viewer_id')::uuid
+    OR user_id IN (SELECT followee_id FROM follows WHERE follower_id = sqlc.arg('viewer_id')::uuid))
+  AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('limit');
@@ -0,0 +1,14 @@
+-- name: ReleaseHandle :exec
+INSERT INTO released_handles (handle, user_id, released_at)
+VALUES (lower(sqlc.arg('handle')), sqlc.arg('user_id'), now())
+ON CONFLICT (handle) DO UPDATE
+SET user_id = EXCLUDED.user_id, released_at = EXCLUDED.released_at;
+
+-- name: GetReleasedHandle :one
+SELECT *
+FROM released_handles
+WHERE handle = lower(sqlc.arg('handle'));
+
+-- name: DeleteReleasedHandle :exec
+DELETE FROM released_handles
+WHERE handle = lower(sqlc.arg('handle'));
@@ -0,0 +1,17 @@
+-- name: UpdateChirpBody :one
+WITH revision AS (
+    INSERT INTO chirp_revisions (id, chirp_id, body, created_at, replaced_at)
+    SELECT gen_random_uuid(), id, body, updated_at, now()
+    FROM chirps
+    WHERE chirps.id = $1 AND chirps.user_id = $2 AND chirps.deleted_at IS NULL
+)
+UPDATE chirps
+SET body = $3, updated_at = now()
+WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL
+RETURNING *;
+
+-- name: ListChirpRevisions :many
+SELECT *
+FROM chirp_revisions
+WHERE chirp_id = $1
+ORDER BY created_at;
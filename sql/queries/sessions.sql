@@ -0,0 +1,49 @@
+-- name: CreateSession :one
+INSERT INTO sessions (id, user_id, user_agent, ip, created_at, last_used_at, expires_at)
+VALUES (
+           gen_random_uuid(),
+           $1,
+           $2,
+           $3,
+           now(),
+           now(),
+           $4
+       )
+RETURNING *;
+
+-- name: GetSession :one
+SELECT *
+FROM sessions
+WHERE id = $1;
+
+-- name: ListActiveSessions :many
+SELECT *
+FROM sessions
+WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > now()
+ORDER BY last_used_at DESC, id;
+
+-- name: ListUserSessions :many
+SELECT *
+FROM sessions
+WHERE user_id = $1
+ORDER BY created_at, id;
+
+-- name: TouchSession :exec
+UPDATE sessions
+SET last_used_at = now(), ip = $2
+WHERE id = $1;
+
+-- name: RenewSession :exec
+UPDATE sessions
+SET last_used_at = now(), ip = $2, expires_at = $3
+WHERE id = $1;
+
+-- name: RevokeSession :execrows
+UPDATE sessions
+SET revoked_at = now()
+WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL;
+
+-- name: RevokeUserSessions :exec
+UPDATE sessions
+SET revoked_at = now()
+WHERE user_id = $1 AND revoked_at IS NULL;
@@ -0,0 +1,25 @@
+-- name: CreateRechirp :one
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, rechirp_of)
+VALUES (
+           gen_random_uuid(),
+           now(),
+           now(),
+           '',
+           $1,
+           $2
+       )
+ON CONFLICT (user_id, rechirp_of) DO NOTHING
+RETURNING *;
+
+-- name: DeleteRechirp :exec
+DELETE FROM chirps
+WHERE id = $1 AND user_id = $2 AND rechirp_of IS NOT NULL;
+
+-- name: CountShares :many
+SELECT coalesce(rechirp_of, quote_of) AS chirp_id,
+       count(*) FILTER (WHERE rechirp_of IS NOT NULL) AS rechirp_count,
+       count(*) FILTER (WHERE quote_of IS NOT NULL) AS quote_count
+FROM chirps
+WHERE (rechirp_of = ANY(sqlc.arg('chirp_ids')::uuid[]) OR quote_of = ANY(sqlc.arg('chirp_ids')::uuid[]))
+  AND deleted_at IS NULL
+GROUP BY coalesce(rechirp_of, quote_of);
@@ -0,0 +1,55 @@
+-- name: CountReplies :many
+SELECT in_reply_to AS chirp_id, count(*) AS reply_count
+FROM chirps
+WHERE in_reply_to = ANY(sqlc.arg('chirp_ids')::uuid[]) AND deleted_at IS NULL
+GROUP BY in_reply_to;
+
+-- name: GetChirpAncestors :many
+WITH RECURSIVE ancestors AS (
+    SELECT parent.*, 1 AS depth
+    FROM chirps parent
+    JOIN chirps child ON child.in_reply_to = parent.id
+    WHERE child.id = $1
+    UNION ALL
+    SELECT parent.*, ancestors.depth + 1
+    FROM chirps parent
+    JOIN ancestors ON ancestors.in_reply_to = parent.id
+)
+SELECT id, created_at, updated_at, body, user_id, deleted_at, in_reply_to, rechirp_of, quote_of
+FROM ancestors
+ORDER BY depth DESC;
+
+-- name: ListRepliesAscending :many
+SELECT *
+FROM chirps
+WHERE in_reply_to = sqlc.arg('in_reply_to')
+  AND (deleted_at IS NULL OR EXISTS (SELECT 1 FROM chirps reply WHERE reply.in_reply_to = chirps.id))
+  AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, id) > (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY created_at, id
+LIMIT sqlc.arg('limit');
+
+-- name: ListRepliesDescending :many
+SELECT *
+FROM chirps
+WHERE in_reply_to = sqlc.arg('in_reply_to')
+  AND (deleted_at IS NULL OR EXISTS (SELECT 1 FROM chirps reply WHERE reply.in_reply_to = chirps.id))
+  AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: GetChirpDescendants :many
+WITH RECURSIVE descendants AS (
+    SELECT chirps.*, 1 AS depth
+    FROM chirps
+    WHERE in_reply_to = ANY(sqlc.arg('root_ids')::uuid[])
+    UNION ALL
+    SELECT chirps.*, descendants.depth + 1
+    FROM chirps
+    JOIN descendants ON chirps.in_reply_to = descendants.id
+    WHERE descendants.depth < sqlc.arg('max_depth')::int
+)
+SELECT id, created_at, updated_at, body, user_id, deleted_at, in_reply_to, rechirp_of, quote_of
+FROM descendants
+ORDER BY created_at, id;
@@ -0,0 +1,20 @@
+-- name: TrashChirp :exec
+UPDATE chirps
+SET deleted_at = now()
+WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL;
+
+-- name: ListTrashedChirps :many
+SELECT *
+FROM chirps
+WHERE user_id = $1 AND deleted_at > $2
+ORDER BY deleted_at DESC, id DESC;
+
+-- name: RestoreChirp :one
+UPDATE chirps
+SET deleted_at = NULL
+WHERE id = $1 AND user_id = $2 AND deleted_at > $3
+RETURNING *;
+
+-- name: PurgeTrashedChirps :execrows
+DELETE FROM chirps
+WHERE deleted_at <= $1;
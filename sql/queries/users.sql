@@ -29,4 +29,48 @@ RETURNING id, created_at, updated_at, email, is_chirpy_red;
 -- name: UpgradeChirpyRed :exec
 UPDATE users
 SET is_chirpy_red = true
-WHERE id = $1;
\ No newline at end of file
+WHERE id = $1;
+
+-- name: GetUserByID :one
+SELECT *
+FROM users
+WHERE id = $1;
+
+-- name: GetUserByHandle :one
+SELECT *
+FROM users
+WHERE lower(handle) = lower(sqlc.arg('handle'));
+
+-- name: UpdateUserProfile :one
+UPDATE users
+SET handle = $2, display_name = $3, bio = $4, updated_at = now()
+WHERE id = $1
+RETURNING *;
+
+-- name: GetUserStats :one
+SELECT
+    (SELECT count(*) FROM chirps WHERE chirps.user_id = sqlc.arg('user_id')::uuid AND chirps.deleted_at IS NULL) AS chirp_count,
+    (SELECT count(*) FROM follows WHERE follows.followee_id = sqlc.arg('user_id')::uuid) AS follower_count,
+    (SELECT count(*) FROM follows WHERE follows.follower_id = sqlc.arg('user_id')::uuid) AS following_count;
+
+-- name: RequestUserDeletion :one
+UPDATE users
+SET deletion_requested_at = coalesce(deletion_requested_at, now())
+WHERE id = $1
+RETURNING *;
+
+-- name: CancelUserDeletion :exec
+UPDATE users
+SET deletion_requested_at = NULL
+WHERE id = $1;
+
+-- name: PurgeDeletedUsers :many
+DELETE FROM users
+WHERE deletion_requested_at <= $1
+RETURNING *;
+
+-- name: SetUserRole :one
+UPDATE users
+SET role = $2, updated_at = now()
+WHERE id = $1
+RETURNING *;
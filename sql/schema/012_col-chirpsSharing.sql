@@ -0,0 +1,23 @@
+-- +goose Up
+ALTER TABLE chirps
+    ADD COLUMN rechirp_of UUID REFERENCES chirps ON DELETE CASCADE,
+    ADD COLUMN quote_of UUID REFERENCES chirps ON DELETE SET NULL,
+    DROP CONSTRAINT chirps_body_key;
+-- Plain rechirps have no body of their own, so only original chirps and
+-- quotes need a unique one.
+CREATE UNIQUE INDEX chirps_body_key ON chirps (body) WHERE rechirp_of IS NULL;
+CREATE UNIQUE INDEX chirps_user_id_rechirp_of_key ON chirps (user_id, rechirp_of);
+CREATE INDEX chirps_rechirp_of_idx ON chirps (rechirp_of);
+CREATE INDEX chirps_quote_of_idx ON chirps (quote_of);
+
+-- +goose Down
+DELETE FROM chirps
+WHERE rechirp_of IS NOT NULL;
+DROP INDEX chirps_quote_of_idx;
+DROP INDEX chirps_rechirp_of_idx;
+DROP INDEX chirps_user_id_rechirp_of_key;
+DROP INDEX chirps_body_key;
+ALTER TABLE chirps
+    DROP COLUMN quote_of,
+    DROP COLUMN rechirp_of,
+    ADD CONSTRAINT chirps_body_key UNIQUE (body);
@@ -0,0 +1,24 @@
+-- +goose Up
+CREATE TABLE filter_terms (
+    id UUID PRIMARY KEY,
+    term TEXT NOT NULL UNIQUE,
+    action TEXT NOT NULL CHECK (action IN ('mask', 'reject', 'flag')),
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+INSERT INTO filter_terms (id, term, action, created_at, updated_at)
+VALUES (gen_random_uuid(), 'kerfuffle', 'mask', now(), now()),
+       (gen_random_uuid(), 'sharbert', 'mask', now(), now()),
+       (gen_random_uuid(), 'fornax', 'mask', now(), now());
+
+CREATE TABLE chirp_flags (
+    chirp_id UUID NOT NULL REFERENCES chirps ON DELETE CASCADE,
+    term TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (chirp_id, term)
+);
+CREATE INDEX chirp_flags_created_at_idx ON chirp_flags (created_at, chirp_id);
+
+-- +goose Down
+DROP TABLE chirp_flags;
+DROP TABLE filter_terms;
@@ -0,0 +1,20 @@
+-- +goose Up
+ALTER TABLE users
+    ADD COLUMN handle TEXT,
+    ADD COLUMN display_name TEXT NOT NULL DEFAULT '',
+    ADD COLUMN bio TEXT NOT NULL DEFAULT '';
+CREATE UNIQUE INDEX users_handle_key ON users (lower(handle));
+
+CREATE TABLE released_handles (
+    handle TEXT PRIMARY KEY,
+    user_id UUID REFERENCES users ON DELETE SET NULL,
+    released_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE released_handles;
+DROP INDEX users_handle_key;
+ALTER TABLE users
+    DROP COLUMN bio,
+    DROP COLUMN display_name,
+    DROP COLUMN handle;
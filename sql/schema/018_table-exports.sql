@@ -0,0 +1,17 @@
+-- +goose Up
+-- Exports are built by a background job on whichever instance claims them
+-- first. A claim that's too old is taken to mean that instance went away.
+CREATE TABLE exports (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users ON DELETE CASCADE,
+    status TEXT NOT NULL CHECK (status IN ('pending', 'ready', 'failed')),
+    archive BYTEA,
+    created_at TIMESTAMP NOT NULL,
+    completed_at TIMESTAMP,
+    claimed_at TIMESTAMP
+);
+CREATE INDEX exports_user_id_created_at_idx ON exports (user_id, created_at);
+CREATE INDEX exports_pending_created_at_idx ON exports (created_at, id) WHERE status = 'pending';
+
+-- +goose Down
+DROP TABLE exports;
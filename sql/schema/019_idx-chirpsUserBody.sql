@@ -0,0 +1,16 @@
+-- +goose Up
+-- Only the same author saying the same thing twice is refused, so an
+-- archive can be imported into a new account while the old one still
+-- exists. Chirps in the trash don't count, so an author can post again
+-- what they deleted.
+DROP INDEX chirps_body_key;
+CREATE UNIQUE INDEX chirps_user_id_body_key ON chirps (user_id, body) WHERE rechirp_of IS NULL AND deleted_at IS NULL;
+
+-- +goose Down
+DELETE FROM chirps newer
+USING chirps older
+WHERE newer.rechirp_of IS NULL AND older.rechirp_of IS NULL
+  AND newer.body = older.body
+  AND (older.created_at, older.id) < (newer.created_at, newer.id);
+DROP INDEX chirps_user_id_body_key;
+CREATE UNIQUE INDEX chirps_body_key ON chirps (body) WHERE rechirp_of IS NULL;
@@ -0,0 +1,18 @@
+-- +goose Up
+-- Every login starts a family of refresh tokens, and each refresh replaces
+-- the token used with a new one in the same family. Tokens issued before
+-- rotation each get a family of their own.
+ALTER TABLE refresh_tokens
+    ADD COLUMN family_id UUID,
+    ADD COLUMN rotated_at TIMESTAMP DEFAULT NULL;
+UPDATE refresh_tokens
+SET family_id = gen_random_uuid();
+ALTER TABLE refresh_tokens
+    ALTER COLUMN family_id SET NOT NULL;
+CREATE INDEX refresh_tokens_family_id_idx ON refresh_tokens (family_id);
+
+-- +goose Down
+DROP INDEX refresh_tokens_family_id_idx;
+ALTER TABLE refresh_tokens
+    DROP COLUMN rotated_at,
+    DROP COLUMN family_id;
@@ -0,0 +1,18 @@
+-- +goose Up
+-- Refresh tokens are now stored as an HMAC keyed with REFRESH_TOKEN_KEY,
+-- which the database never sees, so existing plaintext tokens can't be
+-- upgraded here. They are revoked and overwritten instead, keeping the rows
+-- as session history; their users have to log in again.
+ALTER TABLE refresh_tokens
+    RENAME COLUMN token TO token_hash;
+UPDATE refresh_tokens
+SET token_hash = 'invalidated:' || gen_random_uuid(),
+    revoked_at = coalesce(revoked_at, now()),
+    updated_at = now();
+
+-- +goose Down
+-- The hashes can't be turned back into tokens, so every refresh token is
+-- gone after a downgrade.
+DELETE FROM refresh_tokens;
+ALTER TABLE refresh_tokens
+    RENAME COLUMN token_hash TO token;
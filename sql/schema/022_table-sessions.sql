@@ -0,0 +1,33 @@
+-- +goose Up
+-- A session is one login: the family of refresh tokens it started and the
+-- access tokens issued alongside them. Its ID is the family ID, so every
+-- existing family becomes a session.
+CREATE TABLE sessions (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users ON DELETE CASCADE,
+    user_agent TEXT NOT NULL DEFAULT '',
+    ip TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL,
+    last_used_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP DEFAULT NULL
+);
+CREATE INDEX sessions_user_id_idx ON sessions (user_id);
+DELETE FROM refresh_tokens
+WHERE user_id IS NULL;
+INSERT INTO sessions (id, user_id, created_at, last_used_at, expires_at, revoked_at)
+SELECT family_id,
+       user_id,
+       min(created_at),
+       max(updated_at),
+       max(expires_at),
+       CASE WHEN bool_and(revoked_at IS NOT NULL) THEN max(revoked_at) END
+FROM refresh_tokens
+GROUP BY family_id, user_id;
+ALTER TABLE refresh_tokens
+    ADD CONSTRAINT refresh_tokens_family_id_fkey FOREIGN KEY (family_id) REFERENCES sessions ON DELETE CASCADE;
+
+-- +goose Down
+ALTER TABLE refresh_tokens
+    DROP CONSTRAINT refresh_tokens_family_id_fkey;
+DROP TABLE sessions;
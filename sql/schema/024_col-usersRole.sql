@@ -0,0 +1,9 @@
+-- +goose Up
+-- What a user is trusted with beyond their own account. Access tokens carry
+-- it along with the scopes it grants.
+ALTER TABLE users
+    ADD COLUMN role TEXT NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'admin'));
+
+-- +goose Down
+ALTER TABLE users
+    DROP COLUMN role;
@@ -0,0 +1,8 @@
+// Package schema embeds the goose migrations so the binary can apply them
+// without the sql directory being present at runtime.
+package schema
+
+import "embed"
+
+//go:embed *.sql
+var Migrations embed.FS